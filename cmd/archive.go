@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"nostar/internal/config"
+	"nostar/internal/infrastructure/db"
+	"nostar/internal/relay/domain"
+	"nostar/internal/relay/usecase"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportFilter string
+	exportOutput string
+
+	importSkipVerify bool
+	importBatchSize  int
+	importConfigPath string
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export events as NIP-01 JSONL",
+	Long: `Export stored events as NIP-01 JSONL (one event per line), newest first.
+
+Events hidden by moderation ("nostar mod hide") are exported too, so that a
+backup keeps them. The hides themselves are not exported; importing the file
+into another database makes those events visible unless the same pubkeys are
+hidden there first.
+
+Events can be narrowed down with a REQ filter, e.g.
+  nostar export --filter '{"kinds":[0,3],"authors":["<hex pubkey>"]}' -o backup.jsonl
+
+The database is specified by the DATABASE_URL environment variable.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		filter := domain.Filter{}
+		if exportFilter != "" {
+			filters, err := domain.NewFiltersFromRaw([]json.RawMessage{json.RawMessage(exportFilter)})
+			if err != nil {
+				return fmt.Errorf("invalid filter: %w", err)
+			}
+			filter = filters[0]
+		}
+
+		gormDB, err := openDB(ctx)
+		if err != nil {
+			return err
+		}
+		// モデレーションで非表示のイベントもバックアップに含める
+		svc := usecase.NewArchiveService(db.NewEventStore(gormDB, db.WithHiddenEvents()))
+
+		var out io.Writer = cmd.OutOrStdout()
+		if exportOutput != "" && exportOutput != "-" {
+			f, err := os.Create(exportOutput)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+		w := bufio.NewWriter(out)
+
+		n, err := svc.Export(ctx, filter, w)
+		if err != nil {
+			return err
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "exported %d events\n", n)
+		return nil
+	},
+}
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import [file.jsonl ...]",
+	Short: "Import events from NIP-01 JSONL",
+	Long: `Import events from NIP-01 JSONL files (or stdin when no file or "-" is given).
+
+Signatures are verified unless --skip-verify is set. Replaceable events and
+deletion requests (NIP-09) are applied as if the events were published live.
+Requests to vanish (NIP-62) are imported only when addressed to ALL_RELAYS or
+to [auth] relay_url of the config given with -c; others are skipped as invalid.
+Event policies configured for "nostar serve" are not applied.
+
+The database is specified by the DATABASE_URL environment variable.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+		if len(args) == 0 {
+			args = []string{"-"}
+		}
+
+		// タグの索引（[tags] indexed）と NIP-62 の relay タグと比較する URL（[auth] relay_url）はリレーと同じ設定にする
+		cfg := &config.Config{}
+		if importConfigPath != "" {
+			var err error
+			if cfg, err = config.LoadConfig(importConfigPath); err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+		}
+
+		gormDB, err := openDB(ctx)
+		if err != nil {
+			return err
+		}
+		svc := usecase.NewArchiveService(db.NewEventStore(gormDB, db.WithIndexedTags(domain.NewIndexedTags(cfg.Tags.Indexed))))
+
+		for _, path := range args {
+			if err := importFile(cmd, svc, path, cfg.Auth.RelayURL); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		return nil
+	},
+}
+
+// importFile は1つのファイル（"-" の場合は stdin）を取り込む。ファイルは読み終えたら閉じる
+func importFile(cmd *cobra.Command, svc *usecase.ArchiveService, path, relayURL string) error {
+	var in io.Reader = cmd.InOrStdin()
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	opts := usecase.ImportOptions{
+		SkipVerify: importSkipVerify,
+		BatchSize:  importBatchSize,
+		RelayURL:   relayURL,
+		Progress: func(s usecase.ImportStats) {
+			fmt.Fprintf(cmd.ErrOrStderr(), "%s: %s\n", path, formatImportStats(s))
+		},
+	}
+	stats, err := svc.Import(cmd.Context(), in, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "%s: done: %s\n", path, formatImportStats(stats))
+	return nil
+}
+
+func formatImportStats(s usecase.ImportStats) string {
+	return fmt.Sprintf("read=%d imported=%d duplicate=%d deleted=%d invalid=%d",
+		s.Lines, s.Imported, s.Duplicates, s.Deleted, s.Invalid)
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+
+	exportCmd.Flags().StringVarP(&exportFilter, "filter", "f", "", "REQ filter JSON")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "output file (default: stdout)")
+
+	importCmd.Flags().BoolVar(&importSkipVerify, "skip-verify", false, "skip signature verification (trusted dumps only)")
+	importCmd.Flags().StringVarP(&importConfigPath, "config", "c", "", "config file path (index tags listed in [tags] indexed, match NIP-62 relay tags against [auth] relay_url)")
+	importCmd.Flags().IntVar(&importBatchSize, "batch-size", 1000, "number of events verified, saved in one transaction and reported per batch")
+}
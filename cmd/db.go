@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"nostar/internal/infrastructure/db"
+	"os"
+
+	"gorm.io/gorm"
+)
+
+// openDB は環境変数 DATABASE_URL から DB に接続する（サブコマンド共通）
+func openDB(ctx context.Context) (*gorm.DB, error) {
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		return nil, errors.New("DATABASE_URL is not set")
+	}
+	return db.NewGormDB(ctx, db.Config{DSN: dsn})
+}
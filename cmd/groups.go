@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nostar/internal/config"
+	"nostar/internal/infrastructure/db"
+	"nostar/internal/relay"
+	"nostar/internal/relay/domain"
+	"nostar/internal/relay/usecase"
+
+	"gorm.io/gorm"
+)
+
+// newGroupService は NIP-29 のグループの状態を DB から読み込む（無効の場合は nil）
+// グループの状態はリレーの鍵（relayPubKey）で署名するので、鍵が必要
+func newGroupService(ctx context.Context, gormDB *gorm.DB, events relay.EventStore, relayPubKey string, cfg config.GroupsConfig) (*usecase.GroupService, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if relayPubKey == "" {
+		return nil, errors.New("groups require the relay key ([relay_key] secret_key_file or NOSTAR_SECRET_KEY)")
+	}
+	creators := make([]string, 0, len(cfg.Creators))
+	for _, s := range cfg.Creators {
+		pk, err := domain.ParsePubKey(s)
+		if err != nil {
+			return nil, fmt.Errorf("groups.creators: %w", err)
+		}
+		creators = append(creators, pk)
+	}
+
+	svc := usecase.NewGroupService(events, db.NewGroupStore(gormDB), relayPubKey, domain.NewPubKeySet(creators))
+	if err := svc.Load(ctx); err != nil {
+		return nil, err
+	}
+	return svc, nil
+}
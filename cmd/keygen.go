@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"nostar/internal/infrastructure/keys"
+
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/spf13/cobra"
+)
+
+var keygenOutput string
+
+// keygenCmd represents the keygen command
+var keygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Generate the relay's own keypair",
+	Long: `Generate a keypair the relay uses to sign its own events (NIP-29 group state etc.).
+
+  nostar keygen -o relay.key   # write the secret key to a new file (mode 0600)
+  nostar keygen                # print the secret key (nsec) to stdout
+
+Set the file as [relay_key] secret_key_file in the config,
+or pass the secret key in the NOSTAR_SECRET_KEY environment variable.
+The public key is published as "self" in the NIP-11 relay information.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sk := keys.Generate()
+		signer, err := keys.NewSigner(sk)
+		if err != nil {
+			return err
+		}
+		npub, err := nip19.EncodePublicKey(signer.PubKey())
+		if err != nil {
+			return err
+		}
+
+		if keygenOutput != "" {
+			if err := keys.WriteFile(keygenOutput, sk); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "wrote the secret key to %s\n", keygenOutput)
+		} else {
+			nsec, err := nip19.EncodePrivateKey(sk)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "nsec: %s\n", nsec)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "pubkey: %s\nnpub: %s\n", signer.PubKey(), npub)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(keygenCmd)
+	keygenCmd.Flags().StringVarP(&keygenOutput, "output", "o", "", "write the secret key to this file instead of printing it")
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"nostar/internal/config"
+	"nostar/internal/infrastructure/db"
+	"nostar/internal/relay/domain"
+	"nostar/internal/relay/usecase"
+
+	"gorm.io/gorm"
+)
+
+// newManagementService は NIP-86 の管理状態（ban など）を DB から読み込む
+func newManagementService(ctx context.Context, gormDB *gorm.DB, cfg config.ManagementConfig) (*usecase.ManagementService, error) {
+	admins := make([]string, 0, len(cfg.AdminPubkeys))
+	for _, s := range cfg.AdminPubkeys {
+		pk, err := domain.ParsePubKey(s)
+		if err != nil {
+			return nil, fmt.Errorf("management.admin_pubkeys: %w", err)
+		}
+		admins = append(admins, pk)
+	}
+
+	svc := usecase.NewManagementService(db.NewManagementStore(gormDB), domain.NewPubKeySet(admins))
+	if err := svc.Load(ctx); err != nil {
+		return nil, err
+	}
+	return svc, nil
+}
+
+// newReportService は NIP-56 の報告によるモデレーションキューを組み立てる
+func newReportService(gormDB *gorm.DB, cfg config.ReportsConfig) (*usecase.ReportService, error) {
+	trusted := make([]string, 0, len(cfg.TrustedReporters))
+	for _, s := range cfg.TrustedReporters {
+		pk, err := domain.ParsePubKey(s)
+		if err != nil {
+			return nil, fmt.Errorf("reports.trusted_reporters: %w", err)
+		}
+		trusted = append(trusted, pk)
+	}
+
+	moderationSvc := usecase.NewModerationService(db.NewModerationStore(gormDB))
+	return usecase.NewReportService(db.NewReportStore(gormDB), moderationSvc, domain.NewPubKeySet(trusted), cfg.AutoHideThreshold), nil
+}
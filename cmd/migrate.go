@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"nostar/internal/infrastructure/db"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var migrateBaseline string
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Apply database migrations",
+	Long: `Apply the SQL migrations embedded in the binary and record them in the schema_migrations table.
+
+  nostar migrate up       # apply pending migrations
+  nostar migrate status   # show applied / pending migrations
+
+"nostar serve" refuses to start while migrations are pending.
+For a database whose schema was applied before schema_migrations existed,
+record the applied migrations first: nostar migrate up --baseline 005
+
+The database is specified by the DATABASE_URL environment variable.`,
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Apply pending migrations",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		gormDB, err := openDB(cmd.Context())
+		if err != nil {
+			return err
+		}
+		migrator := db.NewMigrator(gormDB)
+
+		if migrateBaseline != "" {
+			marked, err := migrator.Baseline(cmd.Context(), migrateBaseline)
+			if err != nil {
+				return err
+			}
+			for _, mig := range marked {
+				fmt.Fprintf(cmd.OutOrStdout(), "baseline %s\n", mig.Name)
+			}
+		}
+
+		applied, err := migrator.Up(cmd.Context())
+		for _, mig := range applied {
+			fmt.Fprintf(cmd.OutOrStdout(), "applied %s\n", mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(cmd.OutOrStdout(), "schema is up to date")
+		}
+		return nil
+	},
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show applied and pending migrations",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		gormDB, err := openDB(cmd.Context())
+		if err != nil {
+			return err
+		}
+		statuses, err := db.NewMigrator(gormDB).Status(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return tw.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.AddCommand(migrateUpCmd)
+	migrateCmd.AddCommand(migrateStatusCmd)
+
+	migrateUpCmd.Flags().StringVar(&migrateBaseline, "baseline", "", "record migrations up to this version as applied without running them")
+}
@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nostar/internal/config"
+	"nostar/internal/infrastructure/db"
+	"nostar/internal/relay/domain"
+	"nostar/internal/relay/usecase"
+	"os/user"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	modReason     string
+	modActor      string
+	modShowLog    bool
+	modLogLimit   int
+	modQueueAll   bool
+	modQueueLimit int
+	modConfigPath string
+)
+
+// modCmd represents the mod command
+var modCmd = &cobra.Command{
+	Use:   "mod",
+	Short: "Hide events from clients (moderation)",
+	Long: `Hide individual events or all events by a pubkey.
+
+Hidden events are kept in the database but are not returned to REQ and not delivered live.
+Hiding a pubkey also hides the events it publishes afterwards. Use "unhide" to revert.
+Every change is recorded in the audit log ("nostar mod list --log").
+Reported events / pubkeys (NIP-56, kind 1984) are listed by "nostar mod queue".
+
+  nostar mod hide event <event id> --reason "spam"
+  nostar mod hide pubkey <pubkey|npub> --reason "harassment"
+
+The database is specified by the DATABASE_URL environment variable.`,
+}
+
+var modHideCmd = &cobra.Command{
+	Use:       "hide <event|pubkey> <event id|pubkey|npub>",
+	Short:     "Hide an event or all events by a pubkey",
+	Args:      cobra.ExactArgs(2),
+	ValidArgs: []string{string(domain.HideEvent), string(domain.HidePubKey)},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := newModerationService(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		n, err := svc.Hide(cmd.Context(), args[0], args[1], modReason, moderationActor())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "hidden %s %s (%d events)\n", args[0], args[1], n)
+		return nil
+	},
+}
+
+var modUnhideCmd = &cobra.Command{
+	Use:       "unhide <event|pubkey> <event id|pubkey|npub>",
+	Short:     "Show hidden events again",
+	Args:      cobra.ExactArgs(2),
+	ValidArgs: []string{string(domain.HideEvent), string(domain.HidePubKey)},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := newModerationService(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		n, err := svc.Unhide(cmd.Context(), args[0], args[1], modReason, moderationActor())
+		if errors.Is(err, domain.ErrNotFound) {
+			return fmt.Errorf("%s %s is not hidden", args[0], args[1])
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "unhidden %s %s (%d events)\n", args[0], args[1], n)
+		return nil
+	},
+}
+
+var modListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List hidden events / pubkeys (or the audit log with --log)",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := newModerationService(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+		if modShowLog {
+			entries, err := svc.Log(cmd.Context(), modLogLimit)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(tw, "TIME\tACTION\tTARGET\tVALUE\tACTOR\tREASON")
+			for _, e := range entries {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", e.CreatedAt.Format(time.RFC3339), e.Action, e.Target, e.Value, e.Actor, e.Reason)
+			}
+			return tw.Flush()
+		}
+
+		hides, err := svc.List(cmd.Context())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(tw, "TARGET\tVALUE\tSINCE\tACTOR\tREASON")
+		for _, h := range hides {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", h.Target, h.Value, h.CreatedAt.Format(time.RFC3339), h.Actor, h.Reason)
+		}
+		return tw.Flush()
+	},
+}
+
+var modQueueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "List reported events / pubkeys (NIP-56), most reported by trusted reporters first",
+	Long: `List events and pubkeys reported with kind 1984 (NIP-56).
+
+Entries are sorted by the number of trusted reporters ([reports] trusted_reporters in the config),
+then by the number of all reporters. Hidden targets are omitted unless --all is given.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := &config.Config{}
+		if modConfigPath != "" {
+			var err error
+			if cfg, err = config.LoadConfig(modConfigPath); err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+		}
+		gormDB, err := openDB(cmd.Context())
+		if err != nil {
+			return err
+		}
+		svc, err := newReportService(gormDB, cfg.Reports)
+		if err != nil {
+			return err
+		}
+
+		items, err := svc.Queue(cmd.Context(), modQueueAll, modQueueLimit)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "TARGET\tVALUE\tTRUSTED\tREPORTERS\tTYPES\tLAST REPORTED\tHIDDEN")
+		for _, item := range items {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t%s\t%t\n", item.Target, item.Value, item.TrustedReporters, item.Reporters,
+				strings.Join(item.Types, ","), item.LastReportedAt.Format(time.RFC3339), item.Hidden)
+		}
+		return tw.Flush()
+	},
+}
+
+func newModerationService(ctx context.Context) (*usecase.ModerationService, error) {
+	gormDB, err := openDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return usecase.NewModerationService(db.NewModerationStore(gormDB)), nil
+}
+
+// moderationActor は監査ログに記録する操作者（--actor がなければ OS のユーザ名）
+func moderationActor() string {
+	if modActor != "" {
+		return modActor
+	}
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return "cli"
+}
+
+func init() {
+	rootCmd.AddCommand(modCmd)
+	modCmd.AddCommand(modHideCmd)
+	modCmd.AddCommand(modUnhideCmd)
+	modCmd.AddCommand(modListCmd)
+	modCmd.AddCommand(modQueueCmd)
+
+	for _, c := range []*cobra.Command{modHideCmd, modUnhideCmd} {
+		c.Flags().StringVar(&modReason, "reason", "", "reason recorded in the audit log")
+		c.Flags().StringVar(&modActor, "actor", "", "who made the change (default: current OS user)")
+	}
+	modListCmd.Flags().BoolVar(&modShowLog, "log", false, "show the audit log instead")
+	modListCmd.Flags().IntVar(&modLogLimit, "limit", 50, "number of audit log entries to show")
+	modQueueCmd.Flags().BoolVar(&modQueueAll, "all", false, "include hidden events / pubkeys")
+	modQueueCmd.Flags().IntVar(&modQueueLimit, "limit", 50, "number of entries to show")
+	modQueueCmd.Flags().StringVarP(&modConfigPath, "config", "c", "", "config file path (trusted reporters)")
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"nostar/internal/infrastructure/db"
+	"nostar/internal/relay/domain"
+	"nostar/internal/relay/usecase"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var nip05Relays []string
+
+// nip05Cmd represents the nip05 command
+var nip05Cmd = &cobra.Command{
+	Use:   "nip05",
+	Short: "Manage NIP-05 identities served at /.well-known/nostr.json",
+	Long: `Manage NIP-05 identities (name@<relay domain>) served by "nostar serve".
+
+Names are case-insensitive and may only contain a-z0-9-_.
+The database is specified by the DATABASE_URL environment variable.`,
+}
+
+var nip05AddCmd = &cobra.Command{
+	Use:   "add <name> <pubkey|npub>",
+	Short: "Add or update a NIP-05 identity",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pubkey, err := domain.ParsePubKey(args[1])
+		if err != nil {
+			return err
+		}
+
+		svc, err := newIdentityService(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		identity, err := svc.Register(cmd.Context(), args[0], pubkey, nip05Relays)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "registered %s -> %s\n", identity.Name, identity.PubKey)
+		return nil
+	},
+}
+
+var nip05RemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a NIP-05 identity",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := newIdentityService(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		if err := svc.Remove(cmd.Context(), args[0]); err != nil {
+			return fmt.Errorf("failed to remove %q: %w", args[0], err)
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "removed %s\n", args[0])
+		return nil
+	},
+}
+
+var nip05ListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List NIP-05 identities",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc, err := newIdentityService(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		identities, err := svc.List(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tPUBKEY\tRELAYS")
+		for _, identity := range identities {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", identity.Name, identity.PubKey, strings.Join(identity.Relays, ","))
+		}
+		return tw.Flush()
+	},
+}
+
+func newIdentityService(ctx context.Context) (*usecase.IdentityService, error) {
+	gormDB, err := openDB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return usecase.NewIdentityService(db.NewIdentityStore(gormDB)), nil
+}
+
+func init() {
+	rootCmd.AddCommand(nip05Cmd)
+	nip05Cmd.AddCommand(nip05AddCmd)
+	nip05Cmd.AddCommand(nip05RemoveCmd)
+	nip05Cmd.AddCommand(nip05ListCmd)
+
+	nip05AddCmd.Flags().StringSliceVarP(&nip05Relays, "relay", "r", nil, "relay hint URL (repeatable)")
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"nostar/internal/config"
+	"nostar/internal/infrastructure/file"
+	"nostar/internal/infrastructure/plugin"
+	"nostar/internal/relay/domain"
+	"nostar/internal/relay/policy"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// defaultPluginTimeout は、プラグインが1イベントに応答するまでの待ち時間
+const defaultPluginTimeout = 3 * time.Second
+
+// buildEventPolicies は config から EventPolicy のチェーンを組み立てる
+// [write_policy] と [relay_info.limitation] の created_at の範囲は、[[event_policy]] より先に評価する
+func buildEventPolicies(ctx context.Context, cfg *config.Config) ([]domain.EventPolicy, error) {
+	var policies []domain.EventPolicy
+	interval := time.Duration(cfg.WritePolicy.ReloadIntervalSec) * time.Second
+
+	if wp := cfg.WritePolicy; wp.AllowlistFile != "" || wp.DenylistFile != "" {
+		p, err := newPubKeyListPolicy(ctx, wp.AllowlistFile, wp.DenylistFile, wp.AllowTagged, interval)
+		if err != nil {
+			return nil, fmt.Errorf("write_policy: %w", err)
+		}
+		policies = append(policies, p)
+	}
+
+	// NIP-11 の limitation で公開している created_at の範囲は必ず強制する
+	if l := cfg.RelayInfo.Limitation; l.CreatedAtLowerLimit > 0 || l.CreatedAtUpperLimit > 0 {
+		policies = append(policies, policy.NewCreatedAtWindow(
+			time.Duration(l.CreatedAtUpperLimit)*time.Second,
+			time.Duration(l.CreatedAtLowerLimit)*time.Second,
+			true,
+		))
+	}
+
+	for i, pc := range cfg.EventPolicies {
+		p, err := newEventPolicy(ctx, pc, interval)
+		if err != nil {
+			return nil, fmt.Errorf("event_policy[%d] (%s): %w", i, pc.Type, err)
+		}
+
+		switch pc.Action {
+		case "", "reject":
+		case "shadow_reject":
+			p = policy.NewShadow(p)
+		default:
+			return nil, fmt.Errorf("event_policy[%d] (%s): unknown action %q", i, pc.Type, pc.Action)
+		}
+
+		policies = append(policies, p)
+	}
+
+	for _, p := range policies {
+		zap.S().Infow("event policy enabled", "name", p.Name())
+	}
+	return policies, nil
+}
+
+// closeEventPolicies は、プラグインなど後始末が必要なポリシーを閉じる（終了時に呼ぶ）
+func closeEventPolicies(policies []domain.EventPolicy) {
+	for _, p := range policies {
+		c, ok := p.(io.Closer)
+		if !ok {
+			continue
+		}
+		if err := c.Close(); err != nil {
+			zap.S().Warnw("failed to close event policy", "name", p.Name(), "error", err)
+		}
+	}
+}
+
+func newEventPolicy(ctx context.Context, pc config.EventPolicyConfig, interval time.Duration) (domain.EventPolicy, error) {
+	switch pc.Type {
+	case "kind":
+		return policy.NewKindFilter(pc.AllowKinds, pc.DenyKinds), nil
+	case "max_content_length":
+		if pc.Max <= 0 {
+			return nil, fmt.Errorf("max must be positive")
+		}
+		return policy.NewMaxContentLength(pc.Max), nil
+	case "max_tags":
+		if pc.Max <= 0 {
+			return nil, fmt.Errorf("max must be positive")
+		}
+		return policy.NewMaxTags(pc.Max), nil
+	case "created_at":
+		return policy.NewCreatedAtWindow(time.Duration(pc.MaxFutureSec)*time.Second, time.Duration(pc.MaxPastSec)*time.Second, pc.ExemptReplaceable), nil
+	case "content_regex":
+		return policy.NewContentRegex(pc.Pattern)
+	case "pubkey_list":
+		if pc.AllowlistFile == "" && pc.DenylistFile == "" {
+			return nil, fmt.Errorf("allowlist_file or denylist_file is required")
+		}
+		return newPubKeyListPolicy(ctx, pc.AllowlistFile, pc.DenylistFile, pc.AllowTagged, interval)
+	case "plugin":
+		if pc.Command == "" {
+			return nil, fmt.Errorf("command is required")
+		}
+		timeout := defaultPluginTimeout
+		if pc.TimeoutMs > 0 {
+			timeout = time.Duration(pc.TimeoutMs) * time.Millisecond
+		}
+		return plugin.NewWritePolicy(pc.Command, pc.Args, timeout), nil
+	default:
+		return nil, fmt.Errorf("unknown policy type")
+	}
+}
+
+// newPubKeyListPolicy はリストファイルを読み込み、変更監視を開始する
+func newPubKeyListPolicy(ctx context.Context, allowlistFile, denylistFile string, allowTagged bool, interval time.Duration) (*policy.PubKeyList, error) {
+	p := policy.NewPubKeyList(allowTagged)
+
+	lists := []struct {
+		path  string
+		apply func(domain.PubKeySet)
+	}{
+		{allowlistFile, p.SetAllowlist},
+		{denylistFile, p.SetDenylist},
+	}
+	for _, l := range lists {
+		if l.path == "" {
+			continue
+		}
+		w := file.NewPubKeyListWatcher(l.path, interval, l.apply)
+		if err := w.Load(); err != nil {
+			return nil, err
+		}
+		go w.Run(ctx)
+	}
+	return p, nil
+}
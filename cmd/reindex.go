@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"nostar/internal/config"
+	"nostar/internal/infrastructure/db"
+	"nostar/internal/relay/domain"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	reindexConfigPath string
+	reindexBatchSize  int
+)
+
+// reindexTagsCmd represents the reindex-tags command
+var reindexTagsCmd = &cobra.Command{
+	Use:   "reindex-tags",
+	Short: "Rebuild the tag index of stored events",
+	Long: `Rebuild the tag index (event_tags) of all stored events with the tag names
+listed in [tags] indexed of the config (single-letter tags if not set).
+
+Run this after changing [tags] indexed; new events are indexed on save,
+but events stored before the change keep their old index until then.
+
+  nostar reindex-tags -c config.toml
+
+The database is specified by the DATABASE_URL environment variable.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		cfg := &config.Config{}
+		if reindexConfigPath != "" {
+			var err error
+			if cfg, err = config.LoadConfig(reindexConfigPath); err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+		}
+
+		gormDB, err := openDB(ctx)
+		if err != nil {
+			return err
+		}
+		store := db.NewEventStore(gormDB, db.WithIndexedTags(domain.NewIndexedTags(cfg.Tags.Indexed)))
+
+		done, err := store.ReindexTags(ctx, reindexBatchSize, func(done int) {
+			fmt.Fprintf(cmd.ErrOrStderr(), "reindexed %d events\n", done)
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "done: reindexed %d events\n", done)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(reindexTagsCmd)
+
+	reindexTagsCmd.Flags().StringVarP(&reindexConfigPath, "config", "c", "", "config file path ([tags] indexed)")
+	reindexTagsCmd.Flags().IntVar(&reindexBatchSize, "batch-size", 1000, "number of events reindexed in one transaction")
+}
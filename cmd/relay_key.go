@@ -0,0 +1,17 @@
+package cmd
+
+import (
+	"nostar/internal/config"
+	"nostar/internal/infrastructure/keys"
+	"nostar/internal/relay"
+)
+
+// loadRelaySigner はリレー自身の鍵を $NOSTAR_SECRET_KEY か鍵ファイルから読み込む（どちらもない場合は nil）
+func loadRelaySigner(cfg config.RelayKeyConfig) (relay.EventSigner, error) {
+	signer, err := keys.Load(cfg.SecretKeyFile)
+	if err != nil || signer == nil {
+		// nil の *keys.Signer を interface に入れると nil にならないため、ここで nil を返す
+		return nil, err
+	}
+	return signer, nil
+}
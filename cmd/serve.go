@@ -9,6 +9,9 @@ import (
 	"nostar/internal/relay/usecase"
 	"nostar/internal/transport/websocket"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
@@ -32,21 +35,22 @@ to quickly create a Cobra application.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		zap.S().Infow("serve called", "port", servePort)
 
-		ctx := context.Background()
+		// SIGINT / SIGTERM で接続の受け付けを止め、後始末をしてから終了する
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
 
 		// DB connection (check at startup)
-		dsn := os.Getenv("DATABASE_URL")
-		if dsn == "" {
-			zap.S().Error("DATABASE_URL is not set")
+		gormDB, err := openDB(ctx)
+		if err != nil {
+			zap.S().Errorw("failed to connect database", "error", err)
 			os.Exit(1)
 			return
 		}
 
-		gormDB, err := db.NewGormDB(ctx, db.Config{DSN: dsn})
-		if err != nil {
-			zap.S().Errorw("failed to connect database", "error", err)
+		// スキーマが古い場合は起動しない（nostar migrate up で更新する）
+		if err := db.NewMigrator(gormDB).CheckCurrent(ctx); err != nil {
+			zap.S().Errorw("database schema check failed; run `nostar migrate up`", "error", err)
 			os.Exit(1)
-			return
 		}
 
 		// Config
@@ -57,21 +61,94 @@ to quickly create a Cobra application.`,
 		}
 		zap.S().Infow("load config", "path", configPath)
 
-		// EventStore
-		eventStore := db.NewEventStore(gormDB)
+		// EventStore（同時に届いたイベントはまとめてコミットする）
+		indexedTags := domain.NewIndexedTags(cfg.Tags.Indexed)
+		eventStore := db.NewEventStore(gormDB,
+			db.WithWriteBatch(cfg.WriteBatch.MaxEvents, time.Duration(cfg.WriteBatch.MaxDelayMs)*time.Millisecond),
+			db.WithIndexedTags(indexedTags),
+		)
 
 		// ConnectionPool 作成
 		connPool := domain.NewConnectionPool()
 
+		// Event acceptance policies
+		policies, err := buildEventPolicies(ctx, cfg)
+		if err != nil {
+			zap.S().Errorw("failed to build event policies", "error", err)
+			os.Exit(1)
+		}
+
+		// NIP-86: ban はポリシーチェーンの先頭で評価する
+		managementSvc, err := newManagementService(ctx, gormDB, cfg.Management)
+		if err != nil {
+			zap.S().Errorw("failed to load management state", "error", err)
+			os.Exit(1)
+		}
+		policies = append([]domain.EventPolicy{managementSvc}, policies...)
+
+		// NIP-56: 報告のモデレーションキューと自動非表示
+		reportSvc, err := newReportService(gormDB, cfg.Reports)
+		if err != nil {
+			zap.S().Errorw("failed to build report service", "error", err)
+			os.Exit(1)
+		}
+
+		// リレー自身の鍵（NIP-11 の self。グループの状態などに署名する）
+		signer, err := loadRelaySigner(cfg.RelayKey)
+		if err != nil {
+			zap.S().Errorw("failed to load relay key", "error", err)
+			os.Exit(1)
+		}
+		relayPubKey := ""
+		if signer != nil {
+			relayPubKey = signer.PubKey()
+			zap.S().Infow("relay key loaded", "pubkey", relayPubKey)
+		}
+
+		// NIP-29: グループ
+		groupSvc, err := newGroupService(ctx, gormDB, eventStore, relayPubKey, cfg.Groups)
+		if err != nil {
+			zap.S().Errorw("failed to load groups", "error", err)
+			os.Exit(1)
+		}
+
 		// RelayService
-		relaySvc := usecase.NewRelayService(eventStore, connPool)
+		relaySvc := usecase.NewRelayService(eventStore, connPool,
+			usecase.WithEventPolicies(policies...),
+			usecase.WithReportService(reportSvc),
+			usecase.WithGroupService(groupSvc),
+			usecase.WithSigner(signer),
+			usecase.WithRelayURL(cfg.Auth.RelayURL),
+			usecase.WithQueryLimits(domain.QueryLimits{
+				DefaultLimit:    cfg.Query.DefaultLimit,
+				MaxLimit:        cfg.Query.MaxLimit,
+				RejectUnbounded: cfg.Query.RejectUnbounded,
+				Timeout:         time.Duration(cfg.Query.TimeoutMs) * time.Millisecond,
+				SlowQuery:       time.Duration(cfg.Query.SlowQueryMs) * time.Millisecond,
+				PrefixMatch:     cfg.Query.PrefixMatch,
+				MinPrefixLength: cfg.Query.MinPrefixLength,
+				IndexedTags:     indexedTags,
+			}),
+		)
+
+		// NIP-05
+		identitySvc := usecase.NewIdentityService(db.NewIdentityStore(gormDB))
+
+		// NIP-77
+		negentropySvc := usecase.NewNegentropyService(relaySvc, cfg.Negentropy.MaxRecords, cfg.Negentropy.MaxSessions)
 
 		// Server
 		addr := fmt.Sprintf("0.0.0.0:%d", servePort)
-
-		Srv := websocket.NewServer(addr, relaySvc, connPool, &cfg.RelayInfo)
+		Srv := websocket.NewServer(addr, relaySvc, connPool, &cfg.RelayInfo, identitySvc, negentropySvc, managementSvc, reportSvc, cfg.Management.URL)
 
 		_ = Srv.Run(ctx)
+
+		// 溜まっている書き込みを保存し、プラグインのプロセスを残さない
+		if err := eventStore.Close(); err != nil {
+			zap.S().Errorw("failed to close event store", "error", err)
+		}
+		closeEventPolicies(policies)
+		zap.S().Infow("serve stopped")
 	},
 }
 
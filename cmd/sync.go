@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"nostar/internal/config"
+	"nostar/internal/infrastructure/db"
+	"nostar/internal/infrastructure/upstream"
+	"nostar/internal/relay/domain"
+	"nostar/internal/relay/usecase"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	syncFilter         string
+	syncPageSize       int
+	syncFull           bool
+	syncAllowProtected bool
+	syncConfigPath     string
+)
+
+// syncCmd represents the sync command
+var syncCmd = &cobra.Command{
+	Use:   "sync <relay url> [relay url ...]",
+	Short: "Backfill events from other relays",
+	Long: `Connect to upstream relays as a client and store the events matching a filter.
+
+Events are fetched newest first, paginating with "until" until the upstream is exhausted.
+Each event is verified and stored as if it was published to this relay.
+Progress is saved per upstream (and filter), so re-runs only fetch newer events.
+
+  nostar sync wss://relay.example.com --filter '{"authors":["<hex pubkey>"]}'
+
+Pubkeys and events banned through the management API (NIP-86) are not stored.
+When --config is given, the event policies of "nostar serve" are applied as well.
+The database is specified by the DATABASE_URL environment variable.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx := cmd.Context()
+
+		filter := domain.Filter{}
+		if syncFilter != "" {
+			filters, err := domain.NewFiltersFromRaw([]json.RawMessage{json.RawMessage(syncFilter)})
+			if err != nil {
+				return fmt.Errorf("invalid filter: %w", err)
+			}
+			filter = filters[0]
+		}
+
+		gormDB, err := openDB(ctx)
+		if err != nil {
+			return err
+		}
+
+		// NIP-86 で ban した pubkey / イベントは取り込まない
+		cfg := &config.Config{}
+		if syncConfigPath != "" {
+			if cfg, err = config.LoadConfig(syncConfigPath); err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+		}
+		managementSvc, err := newManagementService(ctx, gormDB, cfg.Management)
+		if err != nil {
+			return err
+		}
+		policies := []domain.EventPolicy{managementSvc}
+		if syncConfigPath != "" {
+			p, err := buildEventPolicies(ctx, cfg)
+			if err != nil {
+				return err
+			}
+			policies = append(policies, p...)
+		}
+		defer closeEventPolicies(policies)
+
+		// NIP-56: 取り込んだ報告でも、serve と同じく自動で非表示にする
+		reportSvc, err := newReportService(gormDB, cfg.Reports)
+		if err != nil {
+			return err
+		}
+
+		// このプロセスにはクライアント接続がないので、ライブ配信は行われない
+		relaySvc := usecase.NewRelayService(db.NewEventStore(gormDB, db.WithIndexedTags(domain.NewIndexedTags(cfg.Tags.Indexed))), domain.NewConnectionPool(),
+			usecase.WithEventPolicies(policies...),
+			usecase.WithReportService(reportSvc),
+		)
+		svc := usecase.NewSyncService(relaySvc, upstream.Dial, db.NewSyncCheckpointStore(gormDB))
+
+		for _, url := range args {
+			opts := usecase.SyncOptions{
+				PageSize:       syncPageSize,
+				Full:           syncFull,
+				AllowProtected: syncAllowProtected,
+				Progress: func(s usecase.SyncStats) {
+					fmt.Fprintf(cmd.ErrOrStderr(), "%s: %s\n", url, formatSyncStats(s))
+				},
+			}
+			stats, err := svc.Sync(ctx, url, filter, opts)
+			if err != nil {
+				return fmt.Errorf("%s: %w", url, err)
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "%s: done: %s\n", url, formatSyncStats(stats))
+		}
+		return nil
+	},
+}
+
+func formatSyncStats(s usecase.SyncStats) string {
+	return fmt.Sprintf("fetched=%d stored=%d duplicate=%d rejected=%d newest=%d",
+		s.Fetched, s.Stored, s.Duplicates, s.Rejected, s.Newest)
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+
+	syncCmd.Flags().StringVarP(&syncFilter, "filter", "f", "", "REQ filter JSON")
+	syncCmd.Flags().IntVar(&syncPageSize, "page-size", 500, "limit of each REQ sent to the upstream")
+	syncCmd.Flags().BoolVar(&syncFull, "full", false, "ignore the saved checkpoint and sync from the beginning")
+	syncCmd.Flags().BoolVar(&syncAllowProtected, "allow-protected", false, "also store NIP-70 protected events (which are rejected without the author's auth)")
+	syncCmd.Flags().StringVarP(&syncConfigPath, "config", "c", "", "config file path (apply event policies)")
+}
@@ -10,34 +10,164 @@ const softwareSrcURL = "https://github.com/azuki774/nostar"
 type Config struct {
 	// Database  DatabaseConfig  `toml:"database"`
 	// Server    ServerConfig    `toml:"server"`
-	RelayInfo RelayInfoConfig `toml:"relay_info"`
+	RelayInfo     RelayInfoConfig     `toml:"relay_info"`
+	WritePolicy   WritePolicyConfig   `toml:"write_policy"`
+	EventPolicies []EventPolicyConfig `toml:"event_policy"` // 記述順に評価する
+	Negentropy    NegentropyConfig    `toml:"negentropy"`
+	Management    ManagementConfig    `toml:"management"`
+	Reports       ReportsConfig       `toml:"reports"`
+	WriteBatch    WriteBatchConfig    `toml:"write_batch"`
+	Query         QueryConfig         `toml:"query"`
+	Tags          TagsConfig          `toml:"tags"`
+	Auth          AuthConfig          `toml:"auth"`
+	Groups        GroupsConfig        `toml:"groups"`
+	RelayKey      RelayKeyConfig      `toml:"relay_key"`
 }
 
 type RelayInfoConfig struct {
-	Name          string `toml:"name"`
-	Description   string `toml:"description"`
-	Pubkey        string `toml:"pubkey"`
-	Contact       string `toml:"contact"`
-	Software      string `toml:"software"`
-	Version       string `toml:"version"`
-	SupportedNIPs []int  `toml:"supported_nips"`
-	// Limitations    LimitationsConfig    `toml:"limitation"`
-	RelayCountries []string `toml:"relay_countries"`
-	LanguageTags   []string `toml:"language_tags"`
+	Name           string           `toml:"name"`
+	Description    string           `toml:"description"`
+	Pubkey         string           `toml:"pubkey"`
+	Contact        string           `toml:"contact"`
+	Software       string           `toml:"software"`
+	Version        string           `toml:"version"`
+	SupportedNIPs  []int            `toml:"supported_nips"`
+	Limitation     LimitationConfig `toml:"limitation"`
+	RelayCountries []string         `toml:"relay_countries"`
+	LanguageTags   []string         `toml:"language_tags"`
 	// Tags           TagsConfig           `toml:"tags"`
 	PostingPolicy string `toml:"posting_policy"`
 }
 
-// type LimitationsConfig struct {
-// 	MaxMessageLength int  `toml:"max_message_length"`
-// 	MaxSubscriptions int  `toml:"max_subscriptions"`
-// 	MaxFilters       int  `toml:"max_filters"`
-// 	MaxLimit         int  `toml:"max_limit"`
-// 	MaxSubIDLength   int  `toml:"max_subid_length"`
-// 	MinPowDifficulty int  `toml:"min_pow_difficulty"`
-// 	AuthRequired     bool `toml:"auth_required"`
-// 	PaymentRequired  bool `toml:"payment_required"`
-// }
+// WritePolicyConfig restricts who may publish events.
+// ファイルを指定しない場合、そのリストは無効になる
+type WritePolicyConfig struct {
+	AllowlistFile     string `toml:"allowlist_file"`      // 書き込みを許可する pubkey の一覧
+	DenylistFile      string `toml:"denylist_file"`       // 書き込みを拒否する pubkey の一覧
+	AllowTagged       bool   `toml:"allow_tagged"`        // allowlist のメンバーを p タグで指すイベントは許可する
+	ReloadIntervalSec int    `toml:"reload_interval_sec"` // リストファイルの変更チェック間隔
+}
+
+const defaultReloadIntervalSec = 10
+
+// EventPolicyConfig is one entry of the [[event_policy]] chain.
+// type ごとに使うフィールドが異なる
+type EventPolicyConfig struct {
+	Type   string `toml:"type"`   // kind / max_content_length / max_tags / created_at / content_regex / pubkey_list / plugin
+	Action string `toml:"action"` // 拒否時の動作: reject（デフォルト）/ shadow_reject
+
+	// type = "kind"
+	AllowKinds []int `toml:"allow_kinds"`
+	DenyKinds  []int `toml:"deny_kinds"`
+
+	// type = "max_content_length" / "max_tags"
+	Max int `toml:"max"`
+
+	// type = "created_at"（0 はチェックしない）
+	MaxFutureSec      int64 `toml:"max_future_sec"`
+	MaxPastSec        int64 `toml:"max_past_sec"`
+	ExemptReplaceable bool  `toml:"exempt_replaceable"` // replaceable / addressable な kind は max_past_sec の対象外
+
+	// type = "content_regex"
+	Pattern string `toml:"pattern"`
+
+	// type = "pubkey_list"
+	AllowlistFile string `toml:"allowlist_file"`
+	DenylistFile  string `toml:"denylist_file"`
+	AllowTagged   bool   `toml:"allow_tagged"`
+
+	// type = "plugin"（strfry 互換の write policy プラグイン）
+	Command   string   `toml:"command"`
+	Args      []string `toml:"args"`
+	TimeoutMs int      `toml:"timeout_ms"`
+}
+
+// NegentropyConfig configures NIP-77 set reconciliation.
+type NegentropyConfig struct {
+	MaxRecords  int `toml:"max_records"`  // 1つの NEG-OPEN で扱う最大イベント数（0 はデフォルト）
+	MaxSessions int `toml:"max_sessions"` // 1つの接続で同時に開けるセッション数（0 はデフォルト）
+}
+
+// ManagementConfig configures the NIP-86 relay management API.
+type ManagementConfig struct {
+	AdminPubkeys []string `toml:"admin_pubkeys"` // 管理 API を呼び出せる pubkey（hex / npub）。空の場合は誰も呼び出せない
+	URL          string   `toml:"url"`           // NIP-98 の u タグと比較するリレーの URL（リバースプロキシ配下の場合に指定）
+}
+
+// ReportsConfig configures the moderation queue built from NIP-56 reports.
+type ReportsConfig struct {
+	TrustedReporters  []string `toml:"trusted_reporters"`   // 信頼できる報告者（hex / npub）。キューはこの報告者の数が多い順に並ぶ
+	AutoHideThreshold int      `toml:"auto_hide_threshold"` // この数の信頼できる報告者が報告した対象を自動で非表示にする（0 は無効）
+}
+
+// WriteBatchConfig configures group commit of incoming events.
+type WriteBatchConfig struct {
+	MaxEvents  int `toml:"max_events"`   // 1つのトランザクションで保存する最大イベント数（1 でまとめない）
+	MaxDelayMs int `toml:"max_delay_ms"` // 最初のイベントから後続のイベントを待つ時間（0 は待たずに溜まっている分だけまとめる）
+}
+
+const defaultWriteBatchMaxEvents = 100
+
+// QueryConfig configures the cost limits of REQ.
+// 0 の項目はデフォルト値、負の値は制限しない
+type QueryConfig struct {
+	DefaultLimit    int  `toml:"default_limit"`    // limit のないフィルタに適用する件数
+	MaxLimit        int  `toml:"max_limit"`        // limit の上限（超える場合は切り詰める）
+	RejectUnbounded bool `toml:"reject_unbounded"` // ids / authors / タグ / since のいずれもないフィルタを CLOSED で拒否する
+	TimeoutMs       int  `toml:"timeout_ms"`       // 1つの REQ のクエリのタイムアウト
+	SlowQueryMs     int  `toml:"slow_query_ms"`    // これより遅いクエリをフィルタとともにログに出す
+
+	PrefixMatch     bool `toml:"prefix_match"`      // ids / authors の前方一致（64 文字未満の値）を許可する
+	MinPrefixLength int  `toml:"min_prefix_length"` // 前方一致に使える最短の長さ（NIP-11 の limitation.min_prefix）
+}
+
+const (
+	defaultQueryDefaultLimit = 500
+	defaultQueryMaxLimit     = 5000
+	defaultQueryTimeoutMs    = 10_000
+	defaultQuerySlowQueryMs  = 1000
+	defaultQueryMinPrefix    = 8
+)
+
+// AuthConfig configures NIP-42 client authentication.
+type AuthConfig struct {
+	RelayURL string `toml:"relay_url"` // AUTH イベント・request to vanish の relay タグと比較する URL（空の場合は接続先の Host。ホスト名だけを比較する）
+}
+
+// GroupsConfig configures NIP-29 groups hosted on this relay.
+// グループの状態（kind 39000-39003）はリレーの鍵（[relay_key]）で署名する
+type GroupsConfig struct {
+	Enabled  bool     `toml:"enabled"`
+	Creators []string `toml:"creators"` // グループを作成できる pubkey（hex / npub）。空の場合は誰でも作成できる
+}
+
+// RelayKeyConfig configures the relay's own keypair (nostar keygen で作成する).
+// 環境変数 NOSTAR_SECRET_KEY（hex / nsec）がある場合はそちらを優先する
+type RelayKeyConfig struct {
+	SecretKeyFile string `toml:"secret_key_file"` // 秘密鍵（hex / nsec）を書いたファイル
+}
+
+// TagsConfig configures which tag names are indexed for tag filters of REQ.
+type TagsConfig struct {
+	Indexed []string `toml:"indexed"` // 索引付けするタグ名（大文字・小文字を区別する。空の場合は1文字の英字）
+}
+
+// LimitationConfig is the NIP-11 "limitation" object. 設定した値は実際にリレーで強制される
+type LimitationConfig struct {
+	// created_at の許容範囲（秒）。0 はチェックしない
+	// replaceable / addressable な kind は lower limit の対象外
+	CreatedAtLowerLimit int64 `toml:"created_at_lower_limit"` // 現在時刻からどれだけ過去まで許容するか
+	CreatedAtUpperLimit int64 `toml:"created_at_upper_limit"` // 現在時刻からどれだけ未来まで許容するか
+
+	// MaxMessageLength int  `toml:"max_message_length"`
+	// MaxSubscriptions int  `toml:"max_subscriptions"`
+	// MaxFilters       int  `toml:"max_filters"`
+	// MaxLimit         int  `toml:"max_limit"`
+	// MaxSubIDLength   int  `toml:"max_subid_length"`
+	// MinPowDifficulty int  `toml:"min_pow_difficulty"`
+	// AuthRequired     bool `toml:"auth_required"`
+	// PaymentRequired  bool `toml:"payment_required"`
+}
 
 func LoadConfig(path string) (*Config, error) {
 	var config Config
@@ -46,6 +176,27 @@ func LoadConfig(path string) (*Config, error) {
 	}
 
 	config.RelayInfo.Software = softwareSrcURL
+	if config.WritePolicy.ReloadIntervalSec <= 0 {
+		config.WritePolicy.ReloadIntervalSec = defaultReloadIntervalSec
+	}
+	if config.WriteBatch.MaxEvents <= 0 {
+		config.WriteBatch.MaxEvents = defaultWriteBatchMaxEvents
+	}
+	if config.Query.DefaultLimit == 0 {
+		config.Query.DefaultLimit = defaultQueryDefaultLimit
+	}
+	if config.Query.MaxLimit == 0 {
+		config.Query.MaxLimit = defaultQueryMaxLimit
+	}
+	if config.Query.TimeoutMs == 0 {
+		config.Query.TimeoutMs = defaultQueryTimeoutMs
+	}
+	if config.Query.SlowQueryMs == 0 {
+		config.Query.SlowQueryMs = defaultQuerySlowQueryMs
+	}
+	if config.Query.MinPrefixLength <= 0 {
+		config.Query.MinPrefixLength = defaultQueryMinPrefix
+	}
 	// TODO: version を自動で設定
 	return &config, nil
 }
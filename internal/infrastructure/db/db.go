@@ -6,10 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"nostar/internal/relay/domain"
+	"strings"
+	"time"
 
 	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Config struct {
@@ -43,14 +46,19 @@ func NewGormDB(ctx context.Context, cfg Config) (*gorm.DB, error) {
 }
 
 // EventModel is the GORM model for storing Nostr events
+// スキーマは migrations/ の SQL が正（TestModels_MatchSchema で一致を確認する）
 type EventModel struct {
-	ID        string `gorm:"primaryKey;size:64"`
-	Pubkey    string `gorm:"index;size:64;not null"`
-	Sig       string `gorm:"size:128;not null"`
-	CreatedAt int64  `gorm:"index;not null"`
-	Kind      int    `gorm:"index;not null"`
-	Tags      string `gorm:"type:jsonb"`
-	Content   string `gorm:"type:text"`
+	ID         string    `gorm:"primaryKey;type:char(64)"`
+	Pubkey     string    `gorm:"type:char(64);not null"`
+	Sig        string    `gorm:"type:char(128);not null"`
+	CreatedAt  int64     `gorm:"not null"`
+	Kind       int       `gorm:"type:integer;not null"`
+	Tags       string    `gorm:"type:jsonb;not null"`
+	Content    string    `gorm:"type:text;not null"`
+	ReceivedAt time.Time `gorm:"type:timestamptz;not null"` // リレーが受信した時刻
+	Deleted    bool      `gorm:"not null;default:false"`    // NIP-09 等で無効化済みか（現在は行ごと削除している）
+	Hidden     bool      `gorm:"not null;default:false"`    // モデレーションで非表示
+	ReplacedBy *string   `gorm:"type:char(64)"`             // 置き換えられた先のID（未使用）
 }
 
 func (EventModel) TableName() string {
@@ -65,13 +73,14 @@ func toModel(evt domain.Event) (EventModel, error) {
 	}
 
 	return EventModel{
-		ID:        evt.ID,
-		Pubkey:    evt.PubKey,
-		Sig:       evt.Signature,
-		CreatedAt: evt.CreatedAt,
-		Kind:      evt.Kind,
-		Tags:      string(tagsJSON),
-		Content:   evt.Content,
+		ID:         evt.ID,
+		Pubkey:     evt.PubKey,
+		Sig:        evt.Signature,
+		CreatedAt:  evt.CreatedAt,
+		Kind:       evt.Kind,
+		Tags:       string(tagsJSON),
+		Content:    evt.Content,
+		ReceivedAt: time.Now(),
 	}, nil
 }
 
@@ -95,80 +104,424 @@ func toDomain(model EventModel) (domain.Event, error) {
 }
 
 type EventStore struct {
-	db *gorm.DB
+	db     *gorm.DB
+	writes *writeBatcher      // nil の場合は Save ごとにトランザクションを作る
+	tags   domain.IndexedTags // event_tags に索引付けするタグ名（nil の場合は1文字の英字）
+	hidden bool               // Query でモデレーションで非表示にしたイベントも返す（export 用）
 }
 
-func NewEventStore(db *gorm.DB) *EventStore {
-	return &EventStore{
+// writeBatchTimeout は、まとめた書き込みの1つのトランザクションにかける時間の上限
+const writeBatchTimeout = 30 * time.Second
+
+// EventStoreOption configures optional behaviour of EventStore.
+type EventStoreOption func(*EventStore)
+
+// WithWriteBatch funnels Save calls into a group commit of up to maxEvents events per transaction.
+// 最初のイベントから maxDelay の間（0 の場合は待たずに、その時点で溜まっている分だけ）まとめる
+// maxEvents が 1 以下の場合はまとめない
+func WithWriteBatch(maxEvents int, maxDelay time.Duration) EventStoreOption {
+	return func(e *EventStore) {
+		if maxEvents > 1 {
+			e.writes = newWriteBatcher(e.SaveBatch, maxEvents, maxDelay, writeBatchTimeout)
+		}
+	}
+}
+
+// WithIndexedTags sets the tag names stored in event_tags (nil の場合は1文字の英字タグ).
+// 変更前に保存したイベントの索引は ReindexTags で作り直す
+func WithIndexedTags(tags domain.IndexedTags) EventStoreOption {
+	return func(e *EventStore) {
+		e.tags = tags
+	}
+}
+
+// WithHiddenEvents makes Query and QueryStream also return events hidden by moderation.
+// バックアップ（nostar export）でモデレーション中のイベントを失わないために使う。リレーでは使わない
+func WithHiddenEvents() EventStoreOption {
+	return func(e *EventStore) {
+		e.hidden = true
+	}
+}
+
+func NewEventStore(db *gorm.DB, opts ...EventStoreOption) *EventStore {
+	e := &EventStore{
 		db: db,
 	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Close commits the events queued by WithWriteBatch and stops accepting saves.
+// serve の終了時に呼ぶ。以後の Save はエラーになる
+func (e *EventStore) Close() error {
+	if e.writes != nil {
+		e.writes.close()
+	}
+	return nil
 }
 
+// Save stores an event, applying NIP-01 replaceable and NIP-09 deletion semantics.
+// - 同じ ID が既に存在する / より新しい replaceable イベントが存在する場合は domain.ErrDuplicate
+// - 作者によって削除済みのイベント、request to vanish（NIP-62）より前のイベントの場合は domain.ErrDeleted
+// - 非表示の pubkey のイベントは非表示の状態で保存し、domain.ErrHidden
+// - 索引付けするタグ（WithIndexedTags）は event_tags に、kind 1984（NIP-56 の報告）は報告対象を reports に索引付けする
 func (e *EventStore) Save(ctx context.Context, evt domain.Event) error {
+	if e.writes != nil {
+		return e.writes.save(ctx, evt)
+	}
+	return e.SaveBatch(ctx, []domain.Event{evt})[0]
+}
+
+// SaveBatch stores events in order in one transaction and returns the result of Save for each event.
+// イベントごとに SAVEPOINT を使うので、1件の失敗（重複など）で他のイベントは巻き戻らない
+// コミットに失敗した場合は、保存できたはずのイベントもそのエラーになる
+func (e *EventStore) SaveBatch(ctx context.Context, evts []domain.Event) []error {
+	errs := make([]error, len(evts))
+	hidden := make([]bool, len(evts))
+
+	err := e.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i, evt := range evts {
+			errs[i] = tx.Transaction(func(sp *gorm.DB) error {
+				var err error
+				hidden[i], err = e.saveEvent(sp, evt)
+				return err
+			})
+		}
+		return nil
+	})
+
+	for i := range errs {
+		switch {
+		case errs[i] != nil:
+		case err != nil:
+			errs[i] = err
+		case hidden[i]:
+			// トランザクション内でエラーを返すとロールバックされるため、コミット後に返す
+			errs[i] = domain.ErrHidden
+		}
+	}
+	return errs
+}
+
+// saveEvent は1件のイベントを保存し、非表示の状態で保存したかを返す
+func (e *EventStore) saveEvent(tx *gorm.DB, evt domain.Event) (bool, error) {
 	model, err := toModel(evt) // domain -> DBモデルに変換
 	if err != nil {
-		return fmt.Errorf("failed to convert to model: %w", err)
+		return false, fmt.Errorf("failed to convert to model: %w", err)
+	}
+
+	deleted, err := isDeletedByAuthor(tx, evt)
+	if err != nil {
+		return false, err
+	}
+	if !deleted {
+		if deleted, err = isVanished(tx, evt); err != nil {
+			return false, err
+		}
+	}
+	if deleted {
+		return false, domain.ErrDeleted
+	}
+
+	if domain.IsReplaceableKind(evt.Kind) || domain.IsAddressableKind(evt.Kind) {
+		if err := replaceOlder(tx, evt); err != nil {
+			return false, err
+		}
+	}
+
+	if model.Hidden, err = isHidden(tx, evt); err != nil {
+		return false, err
+	}
+
+	res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model)
+	if res.Error != nil {
+		return false, fmt.Errorf("failed to save event: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return false, domain.ErrDuplicate
+	}
+	if err := indexTags(tx, evt, e.tags); err != nil {
+		return false, err
+	}
+
+	switch evt.Kind {
+	case domain.KindDeletion:
+		if err := deleteTargets(tx, evt); err != nil {
+			return false, err
+		}
+	case domain.KindReport:
+		if err := indexReports(tx, evt); err != nil {
+			return false, err
+		}
+	case domain.KindVanish:
+		if err := applyVanish(tx, evt); err != nil {
+			return false, err
+		}
+	}
+	return model.Hidden, nil
+}
+
+// tagsContain は tags に指定したタグが含まれる行を絞り込む条件（GIN インデックスが効く）
+func tagsContain(tx *gorm.DB, tag []string) *gorm.DB {
+	b, _ := json.Marshal([][]string{tag})
+	return tx.Where("tags @> ?::jsonb", string(b))
+}
+
+// isDeletedByAuthor は evt を対象とする kind 5 が作者本人から既に届いているかを返す
+func isDeletedByAuthor(tx *gorm.DB, evt domain.Event) (bool, error) {
+	if evt.Kind == domain.KindDeletion {
+		return false, nil // 削除リクエスト自体は削除できない
+	}
+
+	var count int64
+	q := tagsContain(tx.Model(&EventModel{}).Where("kind = ? AND pubkey = ?", domain.KindDeletion, evt.PubKey), []string{"e", evt.ID})
+	if err := q.Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check deletion: %w", err)
+	}
+	if count > 0 {
+		return true, nil
+	}
+
+	if domain.IsAddressableKind(evt.Kind) {
+		// a タグによる削除は、削除リクエストより前のバージョンにのみ効く
+		q := tagsContain(tx.Model(&EventModel{}).Where("kind = ? AND pubkey = ? AND created_at >= ?", domain.KindDeletion, evt.PubKey, evt.CreatedAt), []string{"a", evt.Address()})
+		if err := q.Count(&count).Error; err != nil {
+			return false, fmt.Errorf("failed to check deletion: %w", err)
+		}
+	}
+	return count > 0, nil
+}
+
+// replaceOlder は同じ replaceable スロットの古いイベントを削除する
+// より新しいイベントが既にある場合は domain.ErrDuplicate を返す
+func replaceOlder(tx *gorm.DB, evt domain.Event) error {
+	q := tx.Where("pubkey = ? AND kind = ?", evt.PubKey, evt.Kind)
+	dTag := evt.DTag()
+	if domain.IsAddressableKind(evt.Kind) && dTag != "" {
+		q = tagsContain(q, []string{"d", dTag})
+	}
+
+	var models []EventModel
+	if err := q.Find(&models).Error; err != nil {
+		return fmt.Errorf("failed to find replaceable events: %w", err)
+	}
+
+	var olderIDs []string
+	for _, m := range models {
+		existing, err := toDomain(m)
+		if err != nil {
+			return err
+		}
+		if domain.IsAddressableKind(evt.Kind) && existing.DTag() != dTag {
+			continue // d タグが空の場合は、ここで絞り込む
+		}
+		if existing.ID == evt.ID || !evt.Supersedes(existing) {
+			return domain.ErrDuplicate
+		}
+		olderIDs = append(olderIDs, existing.ID)
 	}
 
-	if err := e.db.WithContext(ctx).Create(&model).Error; err != nil {
-		return fmt.Errorf("failed to save event: %w", err)
+	if len(olderIDs) > 0 {
+		if err := tx.Where("id IN ?", olderIDs).Delete(&EventModel{}).Error; err != nil {
+			return fmt.Errorf("failed to delete replaced events: %w", err)
+		}
+	}
+	return nil
+}
+
+// deleteTargets は kind 5 が指すイベントのうち、作者本人のものを削除する
+func deleteTargets(tx *gorm.DB, evt domain.Event) error {
+	ids, addrs := evt.DeletionTargets()
+	if len(ids) > 0 {
+		err := tx.Where("id IN ? AND pubkey = ? AND kind <> ?", ids, evt.PubKey, domain.KindDeletion).Delete(&EventModel{}).Error
+		if err != nil {
+			return fmt.Errorf("failed to delete events: %w", err)
+		}
 	}
 
+	for _, addr := range addrs {
+		q := tx.Where("pubkey = ? AND kind = ? AND created_at <= ?", addr.PubKey, addr.Kind, evt.CreatedAt)
+		if addr.DTag != "" {
+			q = tagsContain(q, []string{"d", addr.DTag})
+		}
+		var models []EventModel
+		if err := q.Find(&models).Error; err != nil {
+			return fmt.Errorf("failed to find addressable events: %w", err)
+		}
+		var targetIDs []string
+		for _, m := range models {
+			target, err := toDomain(m)
+			if err != nil {
+				return err
+			}
+			if target.DTag() == addr.DTag {
+				targetIDs = append(targetIDs, target.ID)
+			}
+		}
+		if len(targetIDs) > 0 {
+			if err := tx.Where("id IN ?", targetIDs).Delete(&EventModel{}).Error; err != nil {
+				return fmt.Errorf("failed to delete addressable events: %w", err)
+			}
+		}
+	}
 	return nil
 }
 
 func (e *EventStore) Query(ctx context.Context, sub domain.Subscription) ([]domain.Event, error) {
-	// TODO: タグ検索を実装する
 	var results []domain.Event
+	err := e.QueryStream(ctx, sub, func(evt domain.Event) error {
+		results = append(results, evt)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
 
+// QueryStream reads the events matching each filter in turn (newest first) and passes them to fn as they are read.
+// 複数のフィルタに一致するイベントは最初の1回だけ渡す（OR条件のため）
+func (e *EventStore) QueryStream(ctx context.Context, sub domain.Subscription, fn func(domain.Event) error) error {
+	seen := make(map[string]struct{})
 	for _, filter := range sub.Filters {
-		var models []EventModel
-		query := e.db.WithContext(ctx).Model(&EventModel{})
-
-		// IDs filter
-		if len(filter.IDs) > 0 {
-			query = query.Where("id IN ?", filter.IDs)
+		if err := e.streamFilter(ctx, filter, seen, fn); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		// Authors filter
-		if len(filter.Authors) > 0 {
-			query = query.Where("pubkey IN ?", filter.Authors)
-		}
+func (e *EventStore) streamFilter(ctx context.Context, filter domain.Filter, seen map[string]struct{}, fn func(domain.Event) error) error {
+	rows, err := filterQuery(e.db.WithContext(ctx), filter, e.hidden).Rows()
+	if err != nil {
+		return fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
 
-		// Kinds filter
-		if len(filter.Kinds) > 0 {
-			query = query.Where("kind IN ?", filter.Kinds)
+	for rows.Next() {
+		var model EventModel
+		if err := e.db.ScanRows(rows, &model); err != nil {
+			return fmt.Errorf("failed to scan event: %w", err)
 		}
+		if _, ok := seen[model.ID]; ok {
+			continue
+		}
+		seen[model.ID] = struct{}{}
 
-		// Time range filters
-		if filter.Since != nil {
-			query = query.Where("created_at >= ?", *filter.Since)
+		evt, err := toDomain(model)
+		if err != nil {
+			return fmt.Errorf("failed to load events: %w", err)
 		}
-		if filter.Until != nil {
-			query = query.Where("created_at <= ?", *filter.Until)
+		if err := fn(evt); err != nil {
+			return err
 		}
+	}
+	return rows.Err()
+}
 
-		// Limit (各フィルタに適用)
-		if filter.Limit != nil {
-			query = query.Limit(*filter.Limit)
+// QueryRefs reads the events matching each filter (newest first) without their content and sig.
+// NIP-42 の配信制限の確認に pubkey・kind・tags は読む。本文を読み込まないので、NEG-OPEN で大量のイベントを扱ってもメモリを使わない
+func (e *EventStore) QueryRefs(ctx context.Context, sub domain.Subscription, fn func(domain.Event) error) error {
+	seen := make(map[string]struct{})
+	for _, filter := range sub.Filters {
+		if err := e.streamRefs(ctx, filter, seen, fn); err != nil {
+			return err
 		}
+	}
+	return nil
+}
+
+func (e *EventStore) streamRefs(ctx context.Context, filter domain.Filter, seen map[string]struct{}, fn func(domain.Event) error) error {
+	rows, err := filterQuery(e.db.WithContext(ctx), filter, e.hidden).Select("id", "created_at", "pubkey", "kind", "tags").Rows()
+	if err != nil {
+		return fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
 
-		if err := query.Find(&models).Error; err != nil {
-			return nil, err
+	for rows.Next() {
+		var model EventModel
+		if err := rows.Scan(&model.ID, &model.CreatedAt, &model.Pubkey, &model.Kind, &model.Tags); err != nil {
+			return fmt.Errorf("failed to scan event: %w", err)
 		}
+		if _, ok := seen[model.ID]; ok {
+			continue
+		}
+		seen[model.ID] = struct{}{}
 
-		// Domain Eventに変換してマージ
-		for _, model := range models {
-			if evt, err := toDomain(model); err == nil {
-				results = append(results, evt)
-			} else {
-				return []domain.Event{}, fmt.Errorf("failed to load events: %w", err)
-			}
+		evt, err := toDomain(model)
+		if err != nil {
+			return fmt.Errorf("failed to load events: %w", err)
 		}
+		if err := fn(evt); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// filterQuery はフィルタに一致するイベントを新しい順に引くクエリ（hidden が false の場合は非表示のイベントを除く）
+func filterQuery(tx *gorm.DB, filter domain.Filter, hidden bool) *gorm.DB {
+	query := tx.Model(&EventModel{})
+	if !hidden {
+		query = query.Where("hidden = ?", false)
 	}
 
-	// IDで重複除去（OR条件のため）
-	results = domain.DedupeByID(results)
+	// IDs filter（64 文字未満は前方一致）
+	if len(filter.IDs) > 0 {
+		query = query.Where(hexMatchCondition("id", filter.IDs))
+	}
 
-	return results, nil
+	// Authors filter
+	if len(filter.Authors) > 0 {
+		query = query.Where(hexMatchCondition("pubkey", filter.Authors))
+	}
+
+	// Kinds filter
+	if len(filter.Kinds) > 0 {
+		query = query.Where("kind IN ?", filter.Kinds)
+	}
+
+	// Tag filters（#e, #p など。event_tags を引く）
+	for name, values := range filter.Tags {
+		query = query.Where("id IN (?)", tagFilterQuery(query, name, values, filter))
+	}
+
+	// Time range filters
+	if filter.Since != nil {
+		query = query.Where("created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("created_at <= ?", *filter.Until)
+	}
+
+	// Limit (各フィルタに適用)
+	if filter.Limit != nil {
+		query = query.Limit(*filter.Limit)
+	}
+
+	// 新しい順（NIP-01: limit は新しいものから数える）
+	return query.Order("created_at DESC").Order("id")
+}
+
+// hexMatchCondition は ids / authors の値を、完全一致（IN）と前方一致（LIKE 'prefix%'）の OR 条件にする
+// 値は domain で hex に検証済みのため、LIKE のメタ文字は含まれない
+func hexMatchCondition(column string, values []string) clause.Expr {
+	var full []string
+	var conds []string
+	var args []any
+	for _, v := range values {
+		if len(v) == 64 {
+			full = append(full, v)
+			continue
+		}
+		conds = append(conds, column+" LIKE ?")
+		args = append(args, v+"%")
+	}
+	if len(full) > 0 {
+		conds = append([]string{column + " IN ?"}, conds...)
+		args = append([]any{full}, args...)
+	}
+	return gorm.Expr("("+strings.Join(conds, " OR ")+")", args...)
 }
@@ -1 +1,45 @@
 package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHexMatchCondition(t *testing.T) {
+	full := strings.Repeat("a", 64)
+
+	tests := []struct {
+		name     string
+		values   []string
+		wantSQL  string
+		wantVars []any
+	}{
+		{
+			name:     "full ids",
+			values:   []string{full},
+			wantSQL:  "(id IN ?)",
+			wantVars: []any{[]string{full}},
+		},
+		{
+			name:     "prefixes",
+			values:   []string{"abcd", "ef01"},
+			wantSQL:  "(id LIKE ? OR id LIKE ?)",
+			wantVars: []any{"abcd%", "ef01%"},
+		},
+		{
+			name:     "full ids and prefixes",
+			values:   []string{"abcd", full},
+			wantSQL:  "(id IN ? OR id LIKE ?)",
+			wantVars: []any{[]string{full}, "abcd%"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := hexMatchCondition("id", tt.values)
+			if got.SQL != tt.wantSQL || !reflect.DeepEqual(got.Vars, tt.wantVars) {
+				t.Errorf("hexMatchCondition() = %q %v, want %q %v", got.SQL, got.Vars, tt.wantSQL, tt.wantVars)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"nostar/internal/relay/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxIndexedTagValueLength はタグの値を索引付けする最大バイト数（B-tree の行サイズの上限に収めるため）
+// これより長い値のタグはタグフィルタで検索できない
+const maxIndexedTagValueLength = 1024
+
+// EventTagModel is the GORM model for the tag index (domain.IndexedTags のタグ名)
+// イベントの削除とともに ON DELETE CASCADE で消える
+type EventTagModel struct {
+	EventID   string `gorm:"primaryKey;type:char(64)"`
+	Name      string `gorm:"primaryKey"`
+	Value     string `gorm:"primaryKey"`
+	CreatedAt int64  `gorm:"not null"`
+	Kind      int    `gorm:"type:integer;not null"`
+}
+
+func (EventTagModel) TableName() string {
+	return "event_tags"
+}
+
+// eventTagModels は evt の索引付けするタグ（tags に含まれる名前のタグ）を返す
+func eventTagModels(evt domain.Event, tags domain.IndexedTags) []EventTagModel {
+	var models []EventTagModel
+	seen := make(map[[2]string]bool)
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || !tags.Contains(tag[0]) || len(tag[1]) > maxIndexedTagValueLength {
+			continue
+		}
+		key := [2]string{tag[0], tag[1]}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		models = append(models, EventTagModel{
+			EventID:   evt.ID,
+			Name:      tag[0],
+			Value:     tag[1],
+			CreatedAt: evt.CreatedAt,
+			Kind:      evt.Kind,
+		})
+	}
+	return models
+}
+
+// indexTags は evt のタグを event_tags に記録する
+func indexTags(tx *gorm.DB, evt domain.Event, tags domain.IndexedTags) error {
+	models := eventTagModels(evt, tags)
+	if len(models) == 0 {
+		return nil
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models).Error; err != nil {
+		return fmt.Errorf("failed to index tags: %w", err)
+	}
+	return nil
+}
+
+// ReindexTags rebuilds event_tags of every stored event with the indexed tag names (WithIndexedTags).
+// [tags] indexed を変更した後に、既存のイベントへ反映するために使う
+// batchSize 件ずつ id 順に1つのトランザクションで処理し、各バッチの後に処理済みの件数で progress を呼ぶ
+func (e *EventStore) ReindexTags(ctx context.Context, batchSize int, progress func(done int)) (int, error) {
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	done := 0
+	after := ""
+	for {
+		var models []EventModel
+		if err := e.db.WithContext(ctx).Where("id > ?", after).Order("id").Limit(batchSize).Find(&models).Error; err != nil {
+			return done, fmt.Errorf("failed to load events: %w", err)
+		}
+		if len(models) == 0 {
+			return done, nil
+		}
+
+		err := e.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			ids := make([]string, len(models))
+			for i, m := range models {
+				ids[i] = m.ID
+			}
+			if err := tx.Where("event_id IN ?", ids).Delete(&EventTagModel{}).Error; err != nil {
+				return fmt.Errorf("failed to delete tags: %w", err)
+			}
+			for _, m := range models {
+				evt, err := toDomain(m)
+				if err != nil {
+					return fmt.Errorf("failed to load event %s: %w", m.ID, err)
+				}
+				if err := indexTags(tx, evt, e.tags); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return done, err
+		}
+
+		done += len(models)
+		after = models[len(models)-1].ID
+		if progress != nil {
+			progress(done)
+		}
+	}
+}
+
+// tagFilterQuery は filter のタグ条件（#e など）に一致するイベント ID のサブクエリ
+// since / until / kinds も event_tags 側で絞り込み、(name, value, created_at) のインデックスで範囲スキャンする
+func tagFilterQuery(tx *gorm.DB, name string, values []string, filter domain.Filter) *gorm.DB {
+	q := tx.Session(&gorm.Session{NewDB: true}).Model(&EventTagModel{}).
+		Select("event_id").
+		Where("name = ? AND value IN ?", name, values)
+	if len(filter.Kinds) > 0 {
+		q = q.Where("kind IN ?", filter.Kinds)
+	}
+	if filter.Since != nil {
+		q = q.Where("created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		q = q.Where("created_at <= ?", *filter.Until)
+	}
+	return q
+}
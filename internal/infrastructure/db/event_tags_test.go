@@ -0,0 +1,62 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"nostar/internal/relay/domain"
+)
+
+func TestEventTagModels(t *testing.T) {
+	long := strings.Repeat("x", maxIndexedTagValueLength+1)
+
+	tests := []struct {
+		name    string
+		indexed domain.IndexedTags
+		tags    [][]string
+		want    [][2]string
+	}{
+		{
+			name: "single-letter tags",
+			tags: [][]string{{"e", "id1", "wss://relay"}, {"p", "pk1"}, {"t", "nostr"}},
+			want: [][2]string{{"e", "id1"}, {"p", "pk1"}, {"t", "nostr"}},
+		},
+		{
+			name: "multi-letter and valueless tags are skipped",
+			tags: [][]string{{"emoji", "x", "url"}, {"p"}, {"d", ""}},
+			want: [][2]string{{"d", ""}},
+		},
+		{
+			name:    "configured tag names",
+			indexed: domain.NewIndexedTags([]string{"e", "emoji"}),
+			tags:    [][]string{{"emoji", "cat", "url"}, {"e", "id1"}, {"t", "nostr"}},
+			want:    [][2]string{{"emoji", "cat"}, {"e", "id1"}},
+		},
+		{
+			name: "duplicates are indexed once",
+			tags: [][]string{{"t", "nostr"}, {"t", "nostr"}, {"t", "go"}},
+			want: [][2]string{{"t", "nostr"}, {"t", "go"}},
+		},
+		{
+			name: "too long values are skipped",
+			tags: [][]string{{"r", long}, {"r", "short"}},
+			want: [][2]string{{"r", "short"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evt := domain.Event{ID: "id", CreatedAt: 1000, Kind: 1, Tags: tt.tags}
+			var got [][2]string
+			for _, m := range eventTagModels(evt, tt.indexed) {
+				if m.EventID != evt.ID || m.CreatedAt != evt.CreatedAt || m.Kind != evt.Kind {
+					t.Errorf("eventTagModels() = %+v, want event fields copied", m)
+				}
+				got = append(got, [2]string{m.Name, m.Value})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("eventTagModels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"nostar/internal/relay/domain"
+
+	"gorm.io/gorm"
+)
+
+// GroupStore deletes events of NIP-29 groups from the events table.
+type GroupStore struct {
+	db *gorm.DB
+}
+
+func NewGroupStore(db *gorm.DB) *GroupStore {
+	return &GroupStore{
+		db: db,
+	}
+}
+
+func (s *GroupStore) DeleteGroupEvents(ctx context.Context, groupID string, ids []string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	res := tagsContain(s.db.WithContext(ctx).Where("id IN ?", ids), []string{"h", groupID}).Delete(&EventModel{})
+	if res.Error != nil {
+		return 0, fmt.Errorf("failed to delete group events: %w", res.Error)
+	}
+	return res.RowsAffected, nil
+}
+
+func (s *GroupStore) DeleteGroup(ctx context.Context, groupID string) (int64, error) {
+	var deleted int64
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tagsContain(tx, []string{"h", groupID}).Delete(&EventModel{})
+		if res.Error != nil {
+			return fmt.Errorf("failed to delete group events: %w", res.Error)
+		}
+		deleted = res.RowsAffected
+
+		q := tx.Where("kind BETWEEN ? AND ?", domain.KindGroupMetadata, domain.KindGroupRoles)
+		res = tagsContain(q, []string{"d", groupID}).Delete(&EventModel{})
+		if res.Error != nil {
+			return fmt.Errorf("failed to delete group state: %w", res.Error)
+		}
+		deleted += res.RowsAffected
+		return nil
+	})
+	return deleted, err
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"nostar/internal/relay/domain"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdentityModel is the GORM model for NIP-05 identities
+type IdentityModel struct {
+	Name   string `gorm:"primaryKey;size:64"`
+	Pubkey string `gorm:"index;type:char(64);not null"`
+	Relays string `gorm:"type:jsonb;not null"`
+}
+
+func (IdentityModel) TableName() string {
+	return "nip05_identities"
+}
+
+func toIdentityModel(identity domain.Identity) (IdentityModel, error) {
+	relays := identity.Relays
+	if relays == nil {
+		relays = []string{}
+	}
+	relaysJSON, err := json.Marshal(relays)
+	if err != nil {
+		return IdentityModel{}, fmt.Errorf("failed to marshal relays: %w", err)
+	}
+
+	return IdentityModel{
+		Name:   identity.Name,
+		Pubkey: identity.PubKey,
+		Relays: string(relaysJSON),
+	}, nil
+}
+
+func toIdentityDomain(model IdentityModel) (domain.Identity, error) {
+	var relays []string
+	if model.Relays != "" {
+		if err := json.Unmarshal([]byte(model.Relays), &relays); err != nil {
+			return domain.Identity{}, fmt.Errorf("failed to unmarshal relays: %w", err)
+		}
+	}
+
+	return domain.Identity{
+		Name:   model.Name,
+		PubKey: model.Pubkey,
+		Relays: relays,
+	}, nil
+}
+
+type IdentityStore struct {
+	db *gorm.DB
+}
+
+func NewIdentityStore(db *gorm.DB) *IdentityStore {
+	return &IdentityStore{
+		db: db,
+	}
+}
+
+// SaveIdentity は同名の identity が存在する場合は上書きする
+func (s *IdentityStore) SaveIdentity(ctx context.Context, identity domain.Identity) error {
+	model, err := toIdentityModel(identity)
+	if err != nil {
+		return fmt.Errorf("failed to convert to model: %w", err)
+	}
+
+	err = s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"pubkey", "relays"}),
+	}).Create(&model).Error
+	if err != nil {
+		return fmt.Errorf("failed to save identity: %w", err)
+	}
+	return nil
+}
+
+func (s *IdentityStore) DeleteIdentity(ctx context.Context, name string) error {
+	res := s.db.WithContext(ctx).Where("name = ?", name).Delete(&IdentityModel{})
+	if res.Error != nil {
+		return fmt.Errorf("failed to delete identity: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (s *IdentityStore) FindIdentity(ctx context.Context, name string) (domain.Identity, error) {
+	var model IdentityModel
+	err := s.db.WithContext(ctx).Where("name = ?", name).First(&model).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return domain.Identity{}, domain.ErrNotFound
+	}
+	if err != nil {
+		return domain.Identity{}, fmt.Errorf("failed to find identity: %w", err)
+	}
+	return toIdentityDomain(model)
+}
+
+func (s *IdentityStore) ListIdentities(ctx context.Context) ([]domain.Identity, error) {
+	var models []IdentityModel
+	if err := s.db.WithContext(ctx).Order("name").Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+
+	identities := make([]domain.Identity, 0, len(models))
+	for _, model := range models {
+		identity, err := toIdentityDomain(model)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+	return identities, nil
+}
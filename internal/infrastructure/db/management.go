@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"nostar/internal/relay/domain"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BanModel is the GORM model for NIP-86 bans (pubkey / event / ip)
+type BanModel struct {
+	Type      string    `gorm:"primaryKey;type:text"`
+	Value     string    `gorm:"primaryKey"`
+	Reason    string    `gorm:"not null"`
+	CreatedAt time.Time `gorm:"type:timestamptz;not null"`
+}
+
+func (BanModel) TableName() string {
+	return "management_bans"
+}
+
+// RelaySettingModel is the GORM model for NIP-11 fields changed through NIP-86
+type RelaySettingModel struct {
+	Key       string    `gorm:"primaryKey"`
+	Value     string    `gorm:"not null"`
+	UpdatedAt time.Time `gorm:"type:timestamptz;not null"`
+}
+
+func (RelaySettingModel) TableName() string {
+	return "relay_settings"
+}
+
+type ManagementStore struct {
+	db *gorm.DB
+}
+
+func NewManagementStore(db *gorm.DB) *ManagementStore {
+	return &ManagementStore{
+		db: db,
+	}
+}
+
+func (s *ManagementStore) AddBan(ctx context.Context, ban domain.Ban) error {
+	model := BanModel{
+		Type:      string(ban.Type),
+		Value:     ban.Value,
+		Reason:    ban.Reason,
+		CreatedAt: ban.CreatedAt,
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "type"}, {Name: "value"}},
+			DoUpdates: clause.AssignmentColumns([]string{"reason"}),
+		}).Create(&model).Error
+		if err != nil {
+			return fmt.Errorf("failed to save ban: %w", err)
+		}
+
+		if ban.Type == domain.BanEvent {
+			if err := tx.Where("id = ?", ban.Value).Delete(&EventModel{}).Error; err != nil {
+				return fmt.Errorf("failed to delete banned event: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *ManagementStore) RemoveBan(ctx context.Context, banType domain.BanType, value string) error {
+	err := s.db.WithContext(ctx).Where("type = ? AND value = ?", string(banType), value).Delete(&BanModel{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to delete ban: %w", err)
+	}
+	return nil
+}
+
+func (s *ManagementStore) ListBans(ctx context.Context) ([]domain.Ban, error) {
+	var models []BanModel
+	if err := s.db.WithContext(ctx).Order("created_at").Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("failed to list bans: %w", err)
+	}
+
+	bans := make([]domain.Ban, 0, len(models))
+	for _, model := range models {
+		bans = append(bans, domain.Ban{
+			Type:      domain.BanType(model.Type),
+			Value:     model.Value,
+			Reason:    model.Reason,
+			CreatedAt: model.CreatedAt,
+		})
+	}
+	return bans, nil
+}
+
+func (s *ManagementStore) SaveRelaySetting(ctx context.Context, key, value string) error {
+	model := RelaySettingModel{
+		Key:   key,
+		Value: value,
+	}
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&model).Error
+	if err != nil {
+		return fmt.Errorf("failed to save relay setting: %w", err)
+	}
+	return nil
+}
+
+func (s *ManagementStore) RelaySettings(ctx context.Context) (map[string]string, error) {
+	var models []RelaySettingModel
+	if err := s.db.WithContext(ctx).Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("failed to list relay settings: %w", err)
+	}
+
+	settings := make(map[string]string, len(models))
+	for _, model := range models {
+		settings[model.Key] = model.Value
+	}
+	return settings, nil
+}
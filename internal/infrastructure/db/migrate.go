@@ -0,0 +1,247 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// migrationFS はバイナリに埋め込んだマイグレーション SQL（NNN_name.sql）
+//
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// migrationLockID は複数のプロセスが同時にマイグレーションを実行しないための advisory lock の ID
+const migrationLockID = 7_415_029_001
+
+// ErrSchemaOutdated is returned when the database has pending migrations.
+var ErrSchemaOutdated = errors.New("database schema is outdated")
+
+// Migration is one embedded SQL migration.
+type Migration struct {
+	Version string // ファイル名の先頭の番号（例: 001）
+	Name    string // ファイル名（例: 001_init.sql）
+	SQL     string
+}
+
+// MigrationStatus is a migration and whether it has been applied.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time // 未適用の場合は nil
+}
+
+// SchemaMigrationModel is the GORM model for the applied migrations
+type SchemaMigrationModel struct {
+	Version   string    `gorm:"primaryKey;size:128"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+func (SchemaMigrationModel) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrations returns the embedded migrations ordered by version.
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFS, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	migrations := make([]Migration, 0, len(entries))
+	seen := make(map[string]string, len(entries))
+	for _, e := range entries {
+		version, _, ok := strings.Cut(e.Name(), "_")
+		if !ok || version == "" {
+			return nil, fmt.Errorf("invalid migration file name: %s", e.Name())
+		}
+		if other, dup := seen[version]; dup {
+			return nil, fmt.Errorf("duplicate migration version %s: %s, %s", version, other, e.Name())
+		}
+		seen[version] = e.Name()
+
+		b, err := migrationFS.ReadFile(path.Join("migrations", e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", e.Name(), err)
+		}
+		migrations = append(migrations, Migration{Version: version, Name: e.Name(), SQL: string(b)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies the embedded migrations and records them in schema_migrations.
+type Migrator struct {
+	db *gorm.DB
+}
+
+func NewMigrator(db *gorm.DB) *Migrator {
+	return &Migrator{
+		db: db,
+	}
+}
+
+// Status returns every embedded migration with the time it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, mig := range migrations {
+		s := MigrationStatus{Migration: mig}
+		if t, ok := applied[mig.Version]; ok {
+			s.AppliedAt = &t
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// Up applies the pending migrations in order and returns the applied ones.
+// 各マイグレーションは記録と同じトランザクションで実行する（失敗したものはロールバックされる）
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(applied) == 0 && m.db.WithContext(ctx).Migrator().HasTable(&EventModel{}) {
+		// 履歴がないまま 001_init を実行すると失敗するため、先に Baseline を求める
+		return nil, errors.New("database has tables but no migration history; record the applied migrations with baseline first")
+	}
+
+	var done []Migration
+	for _, mig := range migrations {
+		ran := false
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockID).Error; err != nil {
+				return fmt.Errorf("failed to lock schema_migrations: %w", err)
+			}
+			// ロックを待つ間に他のプロセスが適用している場合がある
+			var count int64
+			if err := tx.Model(&SchemaMigrationModel{}).Where("version = ?", mig.Version).Count(&count).Error; err != nil {
+				return fmt.Errorf("failed to read schema_migrations: %w", err)
+			}
+			if count > 0 {
+				return nil
+			}
+
+			if err := tx.Exec(mig.SQL).Error; err != nil {
+				return fmt.Errorf("failed to apply migration %s: %w", mig.Name, err)
+			}
+			if err := tx.Create(&SchemaMigrationModel{Version: mig.Version, AppliedAt: time.Now()}).Error; err != nil {
+				return fmt.Errorf("failed to record migration %s: %w", mig.Name, err)
+			}
+			ran = true
+			return nil
+		})
+		if err != nil {
+			return done, err
+		}
+		if ran {
+			done = append(done, mig)
+		}
+	}
+	return done, nil
+}
+
+// Baseline records the migrations up to version as applied without running them.
+// schema_migrations を導入する前に SQL を直接適用していたデータベース向け
+func (m *Migrator) Baseline(ctx context.Context, version string) ([]Migration, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var marked []Migration
+	for _, mig := range migrations {
+		if mig.Version > version {
+			break
+		}
+		marked = append(marked, mig)
+	}
+	if len(marked) == 0 || marked[len(marked)-1].Version != version {
+		return nil, fmt.Errorf("unknown migration version: %s", version)
+	}
+
+	now := time.Now()
+	models := make([]SchemaMigrationModel, 0, len(marked))
+	for _, mig := range marked {
+		models = append(models, SchemaMigrationModel{Version: mig.Version, AppliedAt: now})
+	}
+	if err := m.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&models).Error; err != nil {
+		return nil, fmt.Errorf("failed to record baseline: %w", err)
+	}
+	return marked, nil
+}
+
+// CheckCurrent returns ErrSchemaOutdated when some embedded migrations have not been applied.
+func (m *Migrator) CheckCurrent(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	var pending []string
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			pending = append(pending, s.Name)
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: pending migrations: %s", ErrSchemaOutdated, strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// applied は適用済みのバージョンと適用時刻を返す（schema_migrations がない場合は空）
+func (m *Migrator) applied(ctx context.Context) (map[string]time.Time, error) {
+	if !m.db.WithContext(ctx).Migrator().HasTable(&SchemaMigrationModel{}) {
+		return map[string]time.Time{}, nil
+	}
+
+	var models []SchemaMigrationModel
+	if err := m.db.WithContext(ctx).Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	applied := make(map[string]time.Time, len(models))
+	for _, model := range models {
+		applied[model.Version] = model.AppliedAt
+	}
+	return applied, nil
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	err := m.db.WithContext(ctx).Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+  version     VARCHAR(128) PRIMARY KEY,
+  applied_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+)`).Error
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
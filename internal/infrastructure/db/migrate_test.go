@@ -0,0 +1,171 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("Migrations() failed: %v", err)
+	}
+	if len(migrations) == 0 || migrations[0].Name != "001_init.sql" {
+		t.Fatalf("Migrations() = %+v, want 001_init.sql first", migrations)
+	}
+
+	version := regexp.MustCompile(`^[0-9]{3}$`)
+	for i, mig := range migrations {
+		if !version.MatchString(mig.Version) {
+			t.Errorf("%s: version %q is not 3 digits", mig.Name, mig.Version)
+		}
+		if i > 0 && mig.Version <= migrations[i-1].Version {
+			t.Errorf("%s: not ordered after %s", mig.Name, migrations[i-1].Name)
+		}
+		if strings.TrimSpace(mig.SQL) == "" {
+			t.Errorf("%s: empty", mig.Name)
+		}
+	}
+}
+
+// TestModels_MatchSchema applies the migrations to a temporary schema and compares the tables with the GORM models.
+// マイグレーションで作るテーブルのモデルはすべてここに加える
+// TEST_DATABASE_URL（PostgreSQL）が設定されていない場合はスキップする
+func TestModels_MatchSchema(t *testing.T) {
+	gormDB := newTestDB(t)
+	models := []any{
+		&EventModel{},
+		&EventTagModel{},
+		&VanishModel{},
+		&IdentityModel{},
+		&SyncCheckpointModel{},
+		&BanModel{},
+		&RelaySettingModel{},
+		&HideModel{},
+		&ModerationLogModel{},
+		&ReportModel{},
+		&SchemaMigrationModel{},
+	}
+	for _, model := range models {
+		assertModelMatchesTable(t, gormDB, model)
+	}
+}
+
+// newTestDB はマイグレーションを適用した一時的なスキーマに接続する（テストの終了時に削除する）
+// TEST_DATABASE_URL（PostgreSQL）が設定されていない場合はスキップする
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+
+	admin, err := NewGormDB(ctx, Config{DSN: dsn})
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	schemaName := fmt.Sprintf("nostar_test_%d", time.Now().UnixNano())
+	if err := admin.Exec("CREATE SCHEMA " + schemaName).Error; err != nil {
+		t.Fatalf("failed to create schema: %v", err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schemaName + " CASCADE") })
+
+	gormDB, err := NewGormDB(ctx, Config{DSN: withSearchPath(dsn, schemaName)})
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	if _, err := NewMigrator(gormDB).Up(ctx); err != nil {
+		t.Fatalf("Up() failed: %v", err)
+	}
+	if err := NewMigrator(gormDB).CheckCurrent(ctx); err != nil {
+		t.Fatalf("CheckCurrent() after Up() = %v", err)
+	}
+	return gormDB
+}
+
+// assertModelMatchesTable はモデルのフィールドとテーブルのカラム（名前・型・NOT NULL）が一致することを確認する
+func assertModelMatchesTable(t *testing.T, gormDB *gorm.DB, model any) {
+	t.Helper()
+
+	s, err := schema.Parse(model, &sync.Map{}, gormDB.NamingStrategy)
+	if err != nil {
+		t.Fatalf("failed to parse %T: %v", model, err)
+	}
+
+	type column struct {
+		Name    string
+		Type    string
+		NotNull bool
+	}
+	var rows []column
+	err = gormDB.Raw(`SELECT a.attname AS name, format_type(a.atttypid, a.atttypmod) AS type, a.attnotnull AS not_null
+		FROM pg_attribute a
+		WHERE a.attrelid = ?::regclass AND a.attnum > 0 AND NOT a.attisdropped`, s.Table).Scan(&rows).Error
+	if err != nil {
+		t.Fatalf("failed to read %s columns: %v", s.Table, err)
+	}
+	columns := make(map[string]column, len(rows))
+	for _, c := range rows {
+		columns[c.Name] = c
+	}
+
+	for _, f := range s.Fields {
+		if f.DBName == "" {
+			continue
+		}
+		c, ok := columns[f.DBName]
+		if !ok {
+			t.Errorf("%s.%s: column %s does not exist", s.Name, f.Name, f.DBName)
+			continue
+		}
+		delete(columns, f.DBName)
+
+		if got, want := normalizeColumnType(gormDB.Dialector.DataTypeOf(f)), c.Type; got != want {
+			t.Errorf("%s.%s: type = %s, want %s", s.Name, f.Name, got, want)
+		}
+		if got, want := f.NotNull || f.PrimaryKey, c.NotNull; got != want {
+			t.Errorf("%s.%s: not null = %t, want %t", s.Name, f.Name, got, want)
+		}
+	}
+	for name := range columns {
+		t.Errorf("column %s.%s is missing in %s", s.Table, name, s.Name)
+	}
+}
+
+// withSearchPath は DSN に search_path を追加する（URL 形式 / key=value 形式）
+func withSearchPath(dsn, schemaName string) string {
+	if strings.Contains(dsn, "://") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		return dsn + sep + "search_path=" + schemaName
+	}
+	return dsn + " search_path=" + schemaName
+}
+
+// normalizeColumnType は GORM の型名を PostgreSQL の format_type の表記に揃える
+func normalizeColumnType(t string) string {
+	t = strings.ToLower(t)
+	switch {
+	case strings.HasPrefix(t, "char("):
+		return "character" + strings.TrimPrefix(t, "char")
+	case strings.HasPrefix(t, "varchar("):
+		return "character varying" + strings.TrimPrefix(t, "varchar")
+	case t == "timestamptz":
+		return "timestamp with time zone"
+	case t == "bigserial": // 自動採番のカラムは bigint + シーケンス
+		return "bigint"
+	}
+	return t
+}
@@ -0,0 +1,10 @@
+-- NIP-05: name@domain -> pubkey の対応表
+CREATE TABLE nip05_identities (
+  name        VARCHAR(64) PRIMARY KEY,             -- local-part（小文字で正規化済み）
+  pubkey      CHAR(64) NOT NULL,                   -- 対応する pubkey（hex）
+  relays      JSONB   NOT NULL DEFAULT '[]'::jsonb -- リレーヒント ["wss://...", ...]
+);
+
+-- pubkey からの逆引き（relays の応答を組み立てる用）
+CREATE INDEX idx_nip05_identities_pubkey
+  ON nip05_identities (pubkey);
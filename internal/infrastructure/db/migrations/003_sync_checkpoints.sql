@@ -0,0 +1,9 @@
+-- nostar sync: upstream ごとの同期の進捗
+CREATE TABLE sync_checkpoints (
+  relay_url        TEXT    NOT NULL,                    -- upstream リレーの URL
+  filter           TEXT    NOT NULL,                    -- 同期に使ったフィルタ（since/until/limit を除いた JSON）
+  last_created_at  BIGINT  NOT NULL,                    -- 取得済みの最新 created_at
+  updated_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  PRIMARY KEY (relay_url, filter)
+);
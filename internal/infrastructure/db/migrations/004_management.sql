@@ -0,0 +1,16 @@
+-- NIP-86 relay management
+CREATE TABLE management_bans (
+  type        TEXT NOT NULL,                        -- pubkey / event / ip
+  value       TEXT NOT NULL,                        -- pubkey（hex）/ イベントID / IP アドレス
+  reason      TEXT NOT NULL DEFAULT '',
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  PRIMARY KEY (type, value)
+);
+
+-- NIP-86 で変更した NIP-11 の項目（name / description）
+CREATE TABLE relay_settings (
+  key         TEXT PRIMARY KEY,
+  value       TEXT NOT NULL,
+  updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
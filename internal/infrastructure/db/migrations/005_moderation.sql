@@ -0,0 +1,21 @@
+-- モデレーション: events.hidden を設定する対象
+CREATE TABLE moderation_hides (
+  target      TEXT NOT NULL,                        -- event / pubkey
+  value       CHAR(64) NOT NULL,                    -- イベントID / pubkey（hex）
+  reason      TEXT NOT NULL DEFAULT '',
+  actor       TEXT NOT NULL DEFAULT '',             -- 操作した人
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  PRIMARY KEY (target, value)
+);
+
+-- モデレーションの監査ログ（hide / unhide の履歴）
+CREATE TABLE moderation_log (
+  id          BIGSERIAL PRIMARY KEY,
+  action      TEXT NOT NULL,                        -- hide / unhide
+  target      TEXT NOT NULL,
+  value       CHAR(64) NOT NULL,
+  reason      TEXT NOT NULL DEFAULT '',
+  actor       TEXT NOT NULL DEFAULT '',
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,32 @@
+-- NIP-56 の報告（kind 1984）の索引。報告イベントが削除されると一緒に消える
+CREATE TABLE reports (
+  report_id   CHAR(64) NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+  target      TEXT NOT NULL,                        -- event / pubkey
+  value       CHAR(64) NOT NULL,                    -- 報告されたイベントID / pubkey（hex）
+  reporter    CHAR(64) NOT NULL,                    -- 報告者の pubkey
+  type        TEXT NOT NULL DEFAULT '',             -- nudity / malware / profanity / illegal / spam / impersonation / other
+  created_at  BIGINT NOT NULL,
+
+  PRIMARY KEY (report_id, target, value)
+);
+
+CREATE INDEX idx_reports_target ON reports (target, value);
+CREATE INDEX idx_reports_reporter ON reports (reporter);
+
+-- 既存の報告を索引付けする（e タグがあればイベントへの報告、なければ p タグの pubkey への報告）
+INSERT INTO reports (report_id, target, value, reporter, type, created_at)
+SELECT e.id, 'event', t->>1, e.pubkey,
+       COALESCE(NULLIF(t->>2, ''),
+                (SELECT p->>2 FROM jsonb_array_elements(e.tags) p WHERE p->>0 = 'p' AND p->>2 IS NOT NULL LIMIT 1),
+                ''),
+       e.created_at
+FROM events e, jsonb_array_elements(e.tags) t
+WHERE e.kind = 1984 AND t->>0 = 'e' AND t->>1 ~ '^[0-9a-f]{64}$'
+ON CONFLICT DO NOTHING;
+
+INSERT INTO reports (report_id, target, value, reporter, type, created_at)
+SELECT e.id, 'pubkey', t->>1, e.pubkey, COALESCE(t->>2, ''), e.created_at
+FROM events e, jsonb_array_elements(e.tags) t
+WHERE e.kind = 1984 AND t->>0 = 'p' AND t->>1 ~ '^[0-9a-f]{64}$'
+  AND NOT EXISTS (SELECT 1 FROM jsonb_array_elements(e.tags) x WHERE x->>0 = 'e' AND x->>1 ~ '^[0-9a-f]{64}$')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,23 @@
+-- 1文字のタグ（#e / #p / #t / #a / #d など）の索引
+-- tags の GIN インデックスでは created_at 順の範囲スキャンができないため、正規化して持つ
+CREATE TABLE event_tags (
+  event_id    CHAR(64) NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+  name        TEXT    NOT NULL,                     -- タグ名（1文字）
+  value       TEXT    NOT NULL,                     -- タグの値（tag[1]）
+  created_at  BIGINT  NOT NULL,                     -- events.created_at と同じ
+  kind        INTEGER NOT NULL,                     -- events.kind と同じ
+
+  PRIMARY KEY (event_id, name, value)
+);
+
+-- #e / #p / #t / #a / #d などの検索を新しい順に引く
+CREATE INDEX idx_event_tags_name_value_created_at
+  ON event_tags (name, value, created_at DESC);
+
+-- 既存のイベントを索引付けする（1文字の英字のタグ名だけ。長すぎる値は索引付けしない）
+INSERT INTO event_tags (event_id, name, value, created_at, kind)
+SELECT e.id, t->>0, t->>1, e.created_at, e.kind
+FROM events e, jsonb_array_elements(e.tags) t
+WHERE jsonb_typeof(t) = 'array' AND jsonb_array_length(t) >= 2
+  AND t->>0 ~ '^[A-Za-z]$' AND t->>1 IS NOT NULL AND octet_length(t->>1) <= 1024
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,3 @@
+-- authors + kinds のフィルタ（プロフィール・コンタクトリストなど）を新しい順に引く
+CREATE INDEX idx_events_pubkey_kind_created_at
+  ON events (pubkey, kind, created_at DESC);
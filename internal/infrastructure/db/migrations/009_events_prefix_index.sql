@@ -0,0 +1,8 @@
+-- ids / authors の前方一致（LIKE 'prefix%'）をインデックスで引く
+-- id / pubkey は CHAR(64) のため、text_pattern_ops の CHAR 版の bpchar_pattern_ops を使う
+-- （照合順序が C 以外のデータベースでは、既存の btree インデックスを LIKE に使えない）
+CREATE INDEX idx_events_id_pattern
+  ON events (id bpchar_pattern_ops);
+
+CREATE INDEX idx_events_pubkey_pattern
+  ON events (pubkey bpchar_pattern_ops);
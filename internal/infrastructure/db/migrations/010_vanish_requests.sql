@@ -0,0 +1,8 @@
+-- NIP-62 の request to vanish。vanished_at 以前のその pubkey のイベント（と宛先の gift wrap）を再び保存しない
+-- 要求のイベント自体は events に残すが、管理 API などで消されても拒否し続けるよう別に持つ
+CREATE TABLE vanish_requests (
+  pubkey       CHAR(64) PRIMARY KEY,
+  event_id     CHAR(64) NOT NULL,                   -- 最も新しい request to vanish のイベントID
+  vanished_at  BIGINT NOT NULL,                     -- その created_at
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,3 @@
+-- 報告による自動非表示の前に、対象が非表示・再表示されたことがあるかを引く
+CREATE INDEX idx_moderation_log_target_value
+  ON moderation_log (target, value);
@@ -0,0 +1,198 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"nostar/internal/relay/domain"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// HideModel is the GORM model for moderation hides (event / pubkey)
+type HideModel struct {
+	Target    string    `gorm:"primaryKey;type:text"`
+	Value     string    `gorm:"primaryKey;type:char(64)"`
+	Reason    string    `gorm:"not null"`
+	Actor     string    `gorm:"not null"`
+	CreatedAt time.Time `gorm:"type:timestamptz;not null"`
+}
+
+func (HideModel) TableName() string {
+	return "moderation_hides"
+}
+
+// ModerationLogModel is the GORM model for the moderation audit log
+type ModerationLogModel struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	Action    string    `gorm:"type:text;not null"`
+	Target    string    `gorm:"type:text;not null"`
+	Value     string    `gorm:"type:char(64);not null"`
+	Reason    string    `gorm:"not null"`
+	Actor     string    `gorm:"not null"`
+	CreatedAt time.Time `gorm:"type:timestamptz;not null"`
+}
+
+func (ModerationLogModel) TableName() string {
+	return "moderation_log"
+}
+
+type ModerationStore struct {
+	db *gorm.DB
+}
+
+func NewModerationStore(db *gorm.DB) *ModerationStore {
+	return &ModerationStore{
+		db: db,
+	}
+}
+
+func (s *ModerationStore) Hide(ctx context.Context, hide domain.Hide) (int64, error) {
+	var affected int64
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		model := HideModel{
+			Target:    string(hide.Target),
+			Value:     hide.Value,
+			Reason:    hide.Reason,
+			Actor:     hide.Actor,
+			CreatedAt: hide.CreatedAt,
+		}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "target"}, {Name: "value"}},
+			DoUpdates: clause.AssignmentColumns([]string{"reason", "actor", "created_at"}),
+		}).Create(&model).Error
+		if err != nil {
+			return fmt.Errorf("failed to save hide: %w", err)
+		}
+
+		if err := writeModerationLog(tx, domain.ActionHide, hide.Target, hide.Value, hide.Actor, hide.Reason); err != nil {
+			return err
+		}
+
+		res := tx.Model(&EventModel{}).Where(hideColumn(hide.Target)+" = ? AND hidden = ?", hide.Value, false).Update("hidden", true)
+		if res.Error != nil {
+			return fmt.Errorf("failed to hide events: %w", res.Error)
+		}
+		affected = res.RowsAffected
+		return nil
+	})
+	return affected, err
+}
+
+func (s *ModerationStore) Unhide(ctx context.Context, target domain.HideTarget, value, actor, reason string) (int64, error) {
+	var affected int64
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("target = ? AND value = ?", string(target), value).Delete(&HideModel{})
+		if res.Error != nil {
+			return fmt.Errorf("failed to delete hide: %w", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return domain.ErrNotFound
+		}
+
+		if err := writeModerationLog(tx, domain.ActionUnhide, target, value, actor, reason); err != nil {
+			return err
+		}
+
+		// 別の理由（イベント単位 / pubkey 単位）でも非表示にしているものは、そのままにする
+		q := tx.Model(&EventModel{}).Where(hideColumn(target)+" = ? AND hidden = ?", value, true)
+		switch target {
+		case domain.HideEvent:
+			q = q.Where("pubkey NOT IN (?)", tx.Model(&HideModel{}).Select("value").Where("target = ?", string(domain.HidePubKey)))
+		case domain.HidePubKey:
+			q = q.Where("id NOT IN (?)", tx.Model(&HideModel{}).Select("value").Where("target = ?", string(domain.HideEvent)))
+		}
+		res = q.Update("hidden", false)
+		if res.Error != nil {
+			return fmt.Errorf("failed to unhide events: %w", res.Error)
+		}
+		affected = res.RowsAffected
+		return nil
+	})
+	return affected, err
+}
+
+func (s *ModerationStore) ListHides(ctx context.Context) ([]domain.Hide, error) {
+	var models []HideModel
+	if err := s.db.WithContext(ctx).Order("created_at").Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("failed to list hides: %w", err)
+	}
+
+	hides := make([]domain.Hide, 0, len(models))
+	for _, m := range models {
+		hides = append(hides, domain.Hide{
+			Target:    domain.HideTarget(m.Target),
+			Value:     m.Value,
+			Reason:    m.Reason,
+			Actor:     m.Actor,
+			CreatedAt: m.CreatedAt,
+		})
+	}
+	return hides, nil
+}
+
+func (s *ModerationStore) ModerationLog(ctx context.Context, limit int) ([]domain.ModerationLogEntry, error) {
+	var models []ModerationLogModel
+	if err := s.db.WithContext(ctx).Order("id DESC").Limit(limit).Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("failed to read moderation log: %w", err)
+	}
+
+	entries := make([]domain.ModerationLogEntry, 0, len(models))
+	for _, m := range models {
+		entries = append(entries, domain.ModerationLogEntry{
+			ID:        m.ID,
+			Action:    domain.ModerationAction(m.Action),
+			Target:    domain.HideTarget(m.Target),
+			Value:     m.Value,
+			Reason:    m.Reason,
+			Actor:     m.Actor,
+			CreatedAt: m.CreatedAt,
+		})
+	}
+	return entries, nil
+}
+
+func (s *ModerationStore) Moderated(ctx context.Context, target domain.HideTarget, value string) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&ModerationLogModel{}).Where("target = ? AND value = ?", string(target), value).Limit(1).Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to read moderation log: %w", err)
+	}
+	return count > 0, nil
+}
+
+func writeModerationLog(tx *gorm.DB, action domain.ModerationAction, target domain.HideTarget, value, actor, reason string) error {
+	entry := ModerationLogModel{
+		Action: string(action),
+		Target: string(target),
+		Value:  value,
+		Reason: reason,
+		Actor:  actor,
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		return fmt.Errorf("failed to write moderation log: %w", err)
+	}
+	return nil
+}
+
+// hideColumn は非表示の対象に対応する events のカラム
+func hideColumn(target domain.HideTarget) string {
+	if target == domain.HidePubKey {
+		return "pubkey"
+	}
+	return "id"
+}
+
+// isHidden は evt がイベント単位または pubkey 単位で非表示に設定されているかを返す
+// イベントが届く前に非表示にした場合（報告による自動非表示など）も、保存時に非表示にする
+func isHidden(tx *gorm.DB, evt domain.Event) (bool, error) {
+	var count int64
+	err := tx.Model(&HideModel{}).
+		Where("(target = ? AND value = ?) OR (target = ? AND value = ?)", string(domain.HideEvent), evt.ID, string(domain.HidePubKey), evt.PubKey).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check hides: %w", err)
+	}
+	return count > 0, nil
+}
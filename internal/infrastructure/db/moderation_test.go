@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"nostar/internal/relay/domain"
+	"nostar/internal/relay/usecase"
+)
+
+// TestEventStore_Save_HiddenBeforeStored は、保存前に非表示にしたイベントが、届いたときに非表示で保存されることを確認する
+// TEST_DATABASE_URL（PostgreSQL）が設定されていない場合はスキップする
+func TestEventStore_Save_HiddenBeforeStored(t *testing.T) {
+	gormDB := newTestDB(t)
+	ctx := context.Background()
+	events := NewEventStore(gormDB)
+	moderation := NewModerationStore(gormDB)
+
+	hiddenEvent := domain.Event{ID: strings.Repeat("a", 64), PubKey: strings.Repeat("1", 64), Signature: strings.Repeat("0", 128), CreatedAt: 1000, Kind: 1, Tags: [][]string{}}
+	hiddenAuthor := domain.Event{ID: strings.Repeat("b", 64), PubKey: strings.Repeat("2", 64), Signature: strings.Repeat("0", 128), CreatedAt: 1000, Kind: 1, Tags: [][]string{}}
+	visible := domain.Event{ID: strings.Repeat("c", 64), PubKey: strings.Repeat("1", 64), Signature: strings.Repeat("0", 128), CreatedAt: 1000, Kind: 1, Tags: [][]string{}}
+
+	for _, hide := range []domain.Hide{
+		{Target: domain.HideEvent, Value: hiddenEvent.ID, Reason: "spam", Actor: "test", CreatedAt: time.Now()},
+		{Target: domain.HidePubKey, Value: hiddenAuthor.PubKey, Reason: "spam", Actor: "test", CreatedAt: time.Now()},
+	} {
+		if _, err := moderation.Hide(ctx, hide); err != nil {
+			t.Fatalf("Hide(%s) failed: %v", hide.Target, err)
+		}
+	}
+
+	for _, evt := range []domain.Event{hiddenEvent, hiddenAuthor} {
+		if err := events.Save(ctx, evt); !errors.Is(err, domain.ErrHidden) {
+			t.Errorf("Save(%s) = %v, want ErrHidden", evt.ID, err)
+		}
+	}
+	if err := events.Save(ctx, visible); err != nil {
+		t.Fatalf("Save(visible) failed: %v", err)
+	}
+
+	got, err := events.Query(ctx, domain.Subscription{Filters: []domain.Filter{{}}})
+	if err != nil {
+		t.Fatalf("Query() failed: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != visible.ID {
+		t.Errorf("Query() = %v, want only %s", got, visible.ID)
+	}
+}
+
+// TestReportService_AutoHide_BeforeNoteStored は、報告が報告対象のノートより先に届いた場合でも、
+// 後から保存されたノートが自動非表示になることを確認する（同期の順序や別リレーからの報告）
+// TEST_DATABASE_URL（PostgreSQL）が設定されていない場合はスキップする
+func TestReportService_AutoHide_BeforeNoteStored(t *testing.T) {
+	gormDB := newTestDB(t)
+	ctx := context.Background()
+	events := NewEventStore(gormDB)
+	reporter := strings.Repeat("3", 64)
+	reports := usecase.NewReportService(NewReportStore(gormDB), usecase.NewModerationService(NewModerationStore(gormDB)), domain.NewPubKeySet([]string{reporter}), 1)
+
+	note := domain.Event{ID: strings.Repeat("d", 64), PubKey: strings.Repeat("4", 64), Signature: strings.Repeat("0", 128), CreatedAt: 1000, Kind: 1, Tags: [][]string{}}
+	report := domain.Event{
+		ID:        strings.Repeat("e", 64),
+		PubKey:    reporter,
+		Signature: strings.Repeat("0", 128),
+		CreatedAt: 1001,
+		Kind:      domain.KindReport,
+		Tags:      [][]string{{"e", note.ID, "spam"}, {"p", note.PubKey}},
+	}
+
+	if err := events.Save(ctx, report); err != nil {
+		t.Fatalf("Save(report) failed: %v", err)
+	}
+	if err := reports.HandleReport(ctx, report); err != nil {
+		t.Fatalf("HandleReport() failed: %v", err)
+	}
+	if err := events.Save(ctx, note); !errors.Is(err, domain.ErrHidden) {
+		t.Fatalf("Save(note) = %v, want ErrHidden", err)
+	}
+
+	got, err := events.Query(ctx, domain.Subscription{Filters: []domain.Filter{{Kinds: []int{1}}}})
+	if err != nil {
+		t.Fatalf("Query() failed: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("Query() = %v, want the reported note hidden", got)
+	}
+}
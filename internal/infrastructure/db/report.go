@@ -0,0 +1,134 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"nostar/internal/relay/domain"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReportModel is the GORM model for the NIP-56 report index
+// 報告イベント（events）の削除とともに ON DELETE CASCADE で消える
+type ReportModel struct {
+	ReportID  string `gorm:"primaryKey;type:char(64)"`
+	Target    string `gorm:"primaryKey;type:text"`
+	Value     string `gorm:"primaryKey;type:char(64)"`
+	Reporter  string `gorm:"type:char(64);not null"`
+	Type      string `gorm:"not null"`
+	CreatedAt int64  `gorm:"not null"`
+}
+
+func (ReportModel) TableName() string {
+	return "reports"
+}
+
+type ReportStore struct {
+	db *gorm.DB
+}
+
+func NewReportStore(db *gorm.DB) *ReportStore {
+	return &ReportStore{
+		db: db,
+	}
+}
+
+// reportQueueRow is one row of the moderation queue query
+type reportQueueRow struct {
+	Target           string
+	Value            string
+	TrustedReporters int
+	Reporters        int
+	Types            string
+	LastReportedAt   int64
+	Hidden           bool
+}
+
+func (s *ReportStore) ReportQueue(ctx context.Context, opts domain.ReportQueueOptions) ([]domain.ReportQueueItem, error) {
+	trusted := opts.TrustedReporters
+	if len(trusted) == 0 {
+		trusted = []string{""} // IN () は構文エラーになるため、一致しない値を入れる
+	}
+
+	q := s.db.WithContext(ctx).Table("reports r").
+		Select(`r.target, r.value,
+			COUNT(DISTINCT r.reporter) FILTER (WHERE r.reporter IN ?) AS trusted_reporters,
+			COUNT(DISTINCT r.reporter) AS reporters,
+			string_agg(DISTINCT r.type, ',') FILTER (WHERE r.type <> '') AS types,
+			MAX(r.created_at) AS last_reported_at,
+			EXISTS (SELECT 1 FROM moderation_hides h WHERE h.target = r.target AND h.value = r.value) AS hidden`, trusted).
+		Group("r.target, r.value").
+		Order("trusted_reporters DESC, reporters DESC, last_reported_at DESC")
+	if !opts.IncludeHidden {
+		q = q.Where("NOT EXISTS (SELECT 1 FROM moderation_hides h WHERE h.target = r.target AND h.value = r.value)")
+	}
+	if opts.Limit > 0 {
+		q = q.Limit(opts.Limit)
+	}
+
+	var rows []reportQueueRow
+	if err := q.Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read report queue: %w", err)
+	}
+
+	items := make([]domain.ReportQueueItem, 0, len(rows))
+	for _, r := range rows {
+		var types []string
+		if r.Types != "" {
+			types = strings.Split(r.Types, ",")
+		}
+		items = append(items, domain.ReportQueueItem{
+			Target:           domain.HideTarget(r.Target),
+			Value:            r.Value,
+			TrustedReporters: r.TrustedReporters,
+			Reporters:        r.Reporters,
+			Types:            types,
+			LastReportedAt:   time.Unix(r.LastReportedAt, 0),
+			Hidden:           r.Hidden,
+		})
+	}
+	return items, nil
+}
+
+func (s *ReportStore) CountReporters(ctx context.Context, target domain.HideTarget, value string, reporters []string) (int, error) {
+	if len(reporters) == 0 {
+		return 0, nil
+	}
+
+	var count int64
+	err := s.db.WithContext(ctx).Model(&ReportModel{}).
+		Where("target = ? AND value = ? AND reporter IN ?", string(target), value, reporters).
+		Distinct("reporter").
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count reporters: %w", err)
+	}
+	return int(count), nil
+}
+
+// indexReports は kind 1984 の報告対象を reports に記録する
+func indexReports(tx *gorm.DB, evt domain.Event) error {
+	reports := evt.Reports()
+	if len(reports) == 0 {
+		return nil
+	}
+
+	models := make([]ReportModel, 0, len(reports))
+	for _, r := range reports {
+		models = append(models, ReportModel{
+			ReportID:  r.ReportID,
+			Target:    string(r.Target),
+			Value:     r.Value,
+			Reporter:  r.Reporter,
+			Type:      r.Type,
+			CreatedAt: r.CreatedAt,
+		})
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models).Error; err != nil {
+		return fmt.Errorf("failed to index reports: %w", err)
+	}
+	return nil
+}
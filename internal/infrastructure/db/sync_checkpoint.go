@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"nostar/internal/relay/domain"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SyncCheckpointModel is the GORM model for per-upstream sync progress
+type SyncCheckpointModel struct {
+	RelayURL      string    `gorm:"primaryKey"`
+	Filter        string    `gorm:"primaryKey"`
+	LastCreatedAt int64     `gorm:"not null"`
+	UpdatedAt     time.Time `gorm:"type:timestamptz;not null"`
+}
+
+func (SyncCheckpointModel) TableName() string {
+	return "sync_checkpoints"
+}
+
+type SyncCheckpointStore struct {
+	db *gorm.DB
+}
+
+func NewSyncCheckpointStore(db *gorm.DB) *SyncCheckpointStore {
+	return &SyncCheckpointStore{
+		db: db,
+	}
+}
+
+func (s *SyncCheckpointStore) GetCheckpoint(ctx context.Context, relayURL, filterKey string) (int64, error) {
+	var model SyncCheckpointModel
+	err := s.db.WithContext(ctx).Where("relay_url = ? AND filter = ?", relayURL, filterKey).First(&model).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, domain.ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get checkpoint: %w", err)
+	}
+	return model.LastCreatedAt, nil
+}
+
+func (s *SyncCheckpointStore) SaveCheckpoint(ctx context.Context, relayURL, filterKey string, createdAt int64) error {
+	model := SyncCheckpointModel{
+		RelayURL:      relayURL,
+		Filter:        filterKey,
+		LastCreatedAt: createdAt,
+	}
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "relay_url"}, {Name: "filter"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_created_at", "updated_at"}),
+	}).Create(&model).Error
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return nil
+}
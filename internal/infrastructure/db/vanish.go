@@ -0,0 +1,82 @@
+package db
+
+import (
+	"fmt"
+	"nostar/internal/relay/domain"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// VanishModel is the GORM model for NIP-62 requests to vanish (pubkey ごとに最新の1件)
+type VanishModel struct {
+	Pubkey     string    `gorm:"primaryKey;type:char(64)"`
+	EventID    string    `gorm:"type:char(64);not null"`
+	VanishedAt int64     `gorm:"not null"`
+	CreatedAt  time.Time `gorm:"type:timestamptz;not null"`
+}
+
+func (VanishModel) TableName() string {
+	return "vanish_requests"
+}
+
+// isVanished は evt が request to vanish より前のイベントかを返す
+// 作者のイベントに加えて、vanish した pubkey 宛ての gift wrap も対象にする
+func isVanished(tx *gorm.DB, evt domain.Event) (bool, error) {
+	pubkeys := []string{evt.PubKey}
+	if evt.Kind == domain.KindGiftWrap {
+		pubkeys = append(pubkeys, evt.Recipients()...)
+	}
+
+	var count int64
+	err := tx.Model(&VanishModel{}).
+		Where("pubkey IN ? AND vanished_at >= ? AND event_id <> ?", pubkeys, evt.CreatedAt, evt.ID).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check vanish request: %w", err)
+	}
+	return count > 0, nil
+}
+
+// applyVanish は request to vanish（kind 62）を記録し、それ以前の作者のイベントと宛先の gift wrap を削除する
+// 要求のイベント自体は残す。対象のリレーかどうかは呼び出し側（RelayService）で確認する
+func applyVanish(tx *gorm.DB, evt domain.Event) error {
+	model := VanishModel{
+		Pubkey:     evt.PubKey,
+		EventID:    evt.ID,
+		VanishedAt: evt.CreatedAt,
+		CreatedAt:  time.Now(),
+	}
+	err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "pubkey"}},
+		DoUpdates: clause.AssignmentColumns([]string{"event_id", "vanished_at", "created_at"}),
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "vanish_requests.vanished_at < excluded.vanished_at"}}},
+	}).Create(&model).Error
+	if err != nil {
+		return fmt.Errorf("failed to save vanish request: %w", err)
+	}
+
+	res := tx.Where("pubkey = ? AND created_at <= ? AND id <> ?", evt.PubKey, evt.CreatedAt, evt.ID).Delete(&EventModel{})
+	if res.Error != nil {
+		return fmt.Errorf("failed to delete vanished events: %w", res.Error)
+	}
+	deleted := res.RowsAffected
+
+	q := tagsContain(tx.Where("kind = ? AND created_at <= ?", domain.KindGiftWrap, evt.CreatedAt), []string{"p", evt.PubKey})
+	res = q.Delete(&EventModel{})
+	if res.Error != nil {
+		return fmt.Errorf("failed to delete gift wraps: %w", res.Error)
+	}
+
+	reason := fmt.Sprintf("request to vanish %s: deleted %d events and %d gift wraps", evt.ID, deleted, res.RowsAffected)
+	if evt.Content != "" {
+		reason += " (" + evt.Content + ")"
+	}
+	if err := writeModerationLog(tx, domain.ActionVanish, domain.HidePubKey, evt.PubKey, evt.PubKey, reason); err != nil {
+		return err
+	}
+	zap.S().Infow("request to vanish", "pubkey", evt.PubKey, "event_id", evt.ID, "deleted", deleted, "gift_wraps", res.RowsAffected)
+	return nil
+}
@@ -0,0 +1,164 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"nostar/internal/relay/domain"
+)
+
+// errWriteBatcherClosed は Close の後に Save した場合のエラー
+var errWriteBatcherClosed = errors.New("event store is closed")
+
+// writeBatcher funnels concurrent saves into one goroutine that commits them together (group commit).
+// 1つのトランザクションにまとめることで、コミットの往復を減らす
+type writeBatcher struct {
+	saveBatch func(ctx context.Context, evts []domain.Event) []error
+	maxEvents int
+	maxDelay  time.Duration
+	timeout   time.Duration // 1つのバッチの保存にかける時間の上限（詰まった文で後続の書き込みが止まり続けないように）
+	requests  chan *writeRequest
+
+	closeOnce sync.Once
+	quit      chan struct{} // close で閉じる
+	done      chan struct{} // run の終了時に閉じる
+}
+
+type writeRequest struct {
+	ctx    context.Context
+	evt    domain.Event
+	result chan error
+}
+
+func newWriteBatcher(saveBatch func(ctx context.Context, evts []domain.Event) []error, maxEvents int, maxDelay, timeout time.Duration) *writeBatcher {
+	b := &writeBatcher{
+		saveBatch: saveBatch,
+		maxEvents: maxEvents,
+		maxDelay:  maxDelay,
+		timeout:   timeout,
+		requests:  make(chan *writeRequest, maxEvents),
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// save queues evt and waits for the result of its batch.
+func (b *writeBatcher) save(ctx context.Context, evt domain.Event) error {
+	req := &writeRequest{ctx: ctx, evt: evt, result: make(chan error, 1)}
+	select {
+	case b.requests <- req:
+	case <-b.quit:
+		return errWriteBatcherClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-req.result:
+		return err
+	case <-b.done:
+		// close と同時に積んだリクエストは、保存されずに残ることがある
+		select {
+		case err := <-req.result:
+			return err
+		default:
+			return errWriteBatcherClosed
+		}
+	case <-ctx.Done():
+		return ctx.Err() // 保存されたかどうかは分からない
+	}
+}
+
+// close stops accepting saves, commits the queued events and waits for the goroutine to finish.
+func (b *writeBatcher) close() {
+	b.closeOnce.Do(func() { close(b.quit) })
+	<-b.done
+}
+
+func (b *writeBatcher) run() {
+	defer close(b.done)
+	for {
+		select {
+		case req := <-b.requests:
+			b.commit(b.collect(req))
+		case <-b.quit:
+			// 溜まっているリクエストを保存してから終了する
+			for {
+				select {
+				case req := <-b.requests:
+					b.commit(b.collect(req))
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// collect は first から始めて、maxEvents 件または maxDelay が経過するまでリクエストを集める
+func (b *writeBatcher) collect(first *writeRequest) []*writeRequest {
+	batch := []*writeRequest{first}
+
+	var timeout <-chan time.Time
+	if b.maxDelay > 0 {
+		timer := time.NewTimer(b.maxDelay)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for len(batch) < b.maxEvents {
+		if timeout == nil {
+			// 待たずに、既に溜まっている分だけ取り出す
+			select {
+			case req := <-b.requests:
+				batch = append(batch, req)
+			default:
+				return batch
+			}
+			continue
+		}
+
+		select {
+		case req := <-b.requests:
+			batch = append(batch, req)
+		case <-timeout:
+			return batch
+		}
+	}
+	return batch
+}
+
+func (b *writeBatcher) commit(batch []*writeRequest) {
+	// 既に諦めた（コンテキストが終了した）リクエストは保存しない
+	pending := batch[:0]
+	for _, req := range batch {
+		if err := req.ctx.Err(); err != nil {
+			req.result <- err
+			continue
+		}
+		pending = append(pending, req)
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	evts := make([]domain.Event, len(pending))
+	for i, req := range pending {
+		evts[i] = req.evt
+	}
+	// 複数の呼び出し元のイベントをまとめて保存するため、個々のコンテキストには従わない
+	ctx := context.Background()
+	if b.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.timeout)
+		defer cancel()
+	}
+	errs := b.saveBatch(ctx, evts)
+	for i, req := range pending {
+		req.result <- errs[i]
+	}
+}
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"nostar/internal/relay/domain"
+)
+
+// recordingSaveBatch records the batch sizes and fails events whose ID starts with "dup".
+type recordingSaveBatch struct {
+	mu      sync.Mutex
+	batches []int
+	saved   map[string]bool
+}
+
+func (r *recordingSaveBatch) saveBatch(ctx context.Context, evts []domain.Event) []error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, len(evts))
+	errs := make([]error, len(evts))
+	for i, evt := range evts {
+		if strings.HasPrefix(evt.ID, "dup") {
+			errs[i] = domain.ErrDuplicate
+			continue
+		}
+		r.saved[evt.ID] = true
+	}
+	return errs
+}
+
+func TestWriteBatcher_Save(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxEvents int
+		maxDelay  time.Duration
+	}{
+		{name: "time window", maxEvents: 10, maxDelay: 20 * time.Millisecond},
+		{name: "no delay", maxEvents: 10, maxDelay: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recordingSaveBatch{saved: map[string]bool{}}
+			b := newWriteBatcher(rec.saveBatch, tt.maxEvents, tt.maxDelay, time.Second)
+
+			const n = 50
+			errs := make([]error, n)
+			var wg sync.WaitGroup
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					id := fmt.Sprintf("evt%d", i)
+					if i%5 == 0 {
+						id = fmt.Sprintf("dup%d", i)
+					}
+					errs[i] = b.save(context.Background(), domain.Event{ID: id})
+				}(i)
+			}
+			wg.Wait()
+
+			// イベントごとの結果が呼び出し元に返る
+			for i, err := range errs {
+				if i%5 == 0 {
+					if !errors.Is(err, domain.ErrDuplicate) {
+						t.Errorf("save(dup%d) = %v, want ErrDuplicate", i, err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Errorf("save(evt%d) = %v, want nil", i, err)
+				}
+				if !rec.saved[fmt.Sprintf("evt%d", i)] {
+					t.Errorf("evt%d was not saved", i)
+				}
+			}
+
+			total := 0
+			for _, size := range rec.batches {
+				if size > tt.maxEvents {
+					t.Errorf("batch size = %d, want <= %d", size, tt.maxEvents)
+				}
+				total += size
+			}
+			if total != n {
+				t.Errorf("saved %d events in batches %v, want %d", total, rec.batches, n)
+			}
+			if tt.maxDelay > 0 && len(rec.batches) == n {
+				t.Errorf("batches = %v, want events committed together", rec.batches)
+			}
+		})
+	}
+}
+
+func TestWriteBatcher_Save_Canceled(t *testing.T) {
+	rec := &recordingSaveBatch{saved: map[string]bool{}}
+	b := newWriteBatcher(rec.saveBatch, 10, 0, time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := b.save(ctx, domain.Event{ID: "evt"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("save() = %v, want context.Canceled", err)
+	}
+	if rec.saved["evt"] {
+		t.Error("canceled event was saved")
+	}
+}
+
+func TestWriteBatcher_Timeout(t *testing.T) {
+	// 応答しない文はタイムアウトさせ、後続の書き込みを止めない
+	hang := func(ctx context.Context, evts []domain.Event) []error {
+		<-ctx.Done()
+		errs := make([]error, len(evts))
+		for i := range errs {
+			errs[i] = ctx.Err()
+		}
+		return errs
+	}
+	b := newWriteBatcher(hang, 10, 0, 50*time.Millisecond)
+	defer b.close()
+
+	for i := 0; i < 2; i++ {
+		if err := b.save(context.Background(), domain.Event{ID: "evt"}); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("save() = %v, want context.DeadlineExceeded", err)
+		}
+	}
+}
+
+func TestWriteBatcher_Close(t *testing.T) {
+	rec := &recordingSaveBatch{saved: map[string]bool{}}
+	b := newWriteBatcher(rec.saveBatch, 10, 50*time.Millisecond, time.Second)
+
+	// まとめるのを待っている間に close しても、受け付けたイベントは保存する
+	result := make(chan error, 1)
+	go func() { result <- b.save(context.Background(), domain.Event{ID: "evt"}) }()
+	time.Sleep(10 * time.Millisecond)
+	b.close()
+
+	if err := <-result; err != nil {
+		t.Errorf("save() before close = %v, want nil", err)
+	}
+	if !rec.saved["evt"] {
+		t.Error("queued event was not saved on close")
+	}
+	if err := b.save(context.Background(), domain.Event{ID: "late"}); !errors.Is(err, errWriteBatcherClosed) {
+		t.Errorf("save() after close = %v, want errWriteBatcherClosed", err)
+	}
+	b.close() // 2回目の close は何もしない
+}
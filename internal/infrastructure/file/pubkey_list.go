@@ -0,0 +1,114 @@
+package file
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"nostar/internal/relay/domain"
+
+	"go.uber.org/zap"
+)
+
+// LoadPubKeyList reads a pubkey list file.
+// 1行に1つの pubkey（hex もしくは npub）を書く。空行と # 以降はコメントとして無視する
+func LoadPubKeyList(path string) (domain.PubKeySet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var pubkeys []string
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		pk, err := domain.ParsePubKey(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		pubkeys = append(pubkeys, pk)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return domain.NewPubKeySet(pubkeys), nil
+}
+
+// PubKeyListWatcher reloads a pubkey list file when it changes on disk.
+// 更新の検知は mtime / size のポーリングで行う
+type PubKeyListWatcher struct {
+	path     string
+	interval time.Duration
+	onLoad   func(domain.PubKeySet)
+
+	modTime time.Time
+	size    int64
+}
+
+func NewPubKeyListWatcher(path string, interval time.Duration, onLoad func(domain.PubKeySet)) *PubKeyListWatcher {
+	return &PubKeyListWatcher{
+		path:     path,
+		interval: interval,
+		onLoad:   onLoad,
+	}
+}
+
+// Load reads the file once and passes the result to onLoad.
+func (w *PubKeyListWatcher) Load() error {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return err
+	}
+
+	set, err := LoadPubKeyList(w.path)
+	if err != nil {
+		return err
+	}
+
+	w.modTime = info.ModTime()
+	w.size = info.Size()
+	w.onLoad(set)
+	zap.S().Infow("pubkey list loaded", "path", w.path, "count", len(set))
+	return nil
+}
+
+// Run polls the file until ctx is cancelled.
+// 読み込みに失敗した場合は、直前のリストを使い続ける
+func (w *PubKeyListWatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(w.path)
+			if err != nil {
+				zap.S().Warnw("failed to stat pubkey list", "path", w.path, "error", err)
+				continue
+			}
+			if info.ModTime().Equal(w.modTime) && info.Size() == w.size {
+				continue
+			}
+			if err := w.Load(); err != nil {
+				zap.S().Errorw("failed to reload pubkey list", "path", w.path, "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package file_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nostar/internal/infrastructure/file"
+	"nostar/internal/relay/domain"
+)
+
+const (
+	testPubKey1 = "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+	testNpub1   = "npub180cvv07tjdrrgpa0j7j7tmnyl2yr6yr7l8j4s3evf6u64th6gkwsyjh6w6"
+	testPubKey2 = "82341f882b6eabcd2ba7f1ef90aad961cf074af15b9ef44a09f9d2a8fbfbe6a2"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadPubKeyList(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "hex and comments",
+			content: "# team members\n" + testPubKey1 + " # alice\n\n" + testPubKey2 + "\n",
+			want:    []string{testPubKey1, testPubKey2},
+		},
+		{
+			name:    "npub is decoded",
+			content: testNpub1 + "\n",
+			want:    []string{testPubKey1},
+		},
+		{
+			name:    "empty file",
+			content: "",
+			want:    []string{},
+		},
+		{
+			name:    "invalid line",
+			content: testPubKey1 + "\nnot-a-pubkey\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "list.txt")
+			writeFile(t, path, tt.content)
+
+			got, gotErr := file.LoadPubKeyList(path)
+			if (gotErr != nil) != tt.wantErr {
+				t.Fatalf("LoadPubKeyList() error = %v, wantErr %v", gotErr, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("LoadPubKeyList() returned %d pubkeys, want %d", len(got), len(tt.want))
+			}
+			for _, pk := range tt.want {
+				if !got.Contains(pk) {
+					t.Errorf("LoadPubKeyList() does not contain %s", pk)
+				}
+			}
+		})
+	}
+}
+
+func TestPubKeyListWatcher_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "list.txt")
+	writeFile(t, path, testPubKey1+"\n")
+
+	loaded := make(chan domain.PubKeySet, 4)
+	w := file.NewPubKeyListWatcher(path, 10*time.Millisecond, func(set domain.PubKeySet) {
+		loaded <- set
+	})
+	if err := w.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if set := <-loaded; !set.Contains(testPubKey1) {
+		t.Fatalf("initial load does not contain %s", testPubKey1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	// サイズが変わるので mtime の粒度に関わらず検知される
+	writeFile(t, path, testPubKey1+"\n"+testPubKey2+"\n")
+
+	select {
+	case set := <-loaded:
+		if !set.Contains(testPubKey2) {
+			t.Errorf("reloaded list does not contain %s", testPubKey2)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("list was not reloaded")
+	}
+}
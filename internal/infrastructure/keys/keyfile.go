@@ -0,0 +1,56 @@
+package keys
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// EnvSecretKey is the environment variable holding the relay's secret key (hex / nsec). 鍵ファイルより優先する
+const EnvSecretKey = "NOSTAR_SECRET_KEY"
+
+// Load returns the relay's signer from $NOSTAR_SECRET_KEY or the key file.
+// どちらも指定されていない場合は nil（リレーの鍵を使う機能は無効になる）
+func Load(path string) (*Signer, error) {
+	if sk := os.Getenv(EnvSecretKey); sk != "" {
+		signer, err := NewSigner(sk)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", EnvSecretKey, err)
+		}
+		return signer, nil
+	}
+	if path == "" {
+		return nil, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	signer, err := NewSigner(strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return signer, nil
+}
+
+// Generate returns a new hex encoded secret key.
+func Generate() string {
+	return nostr.GeneratePrivateKey()
+}
+
+// WriteFile writes the secret key to a new file readable only by the owner.
+// 既存の鍵を上書きしないよう、ファイルが既にある場合はエラーにする
+func WriteFile(path, secretKey string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create key file: %w", err)
+	}
+	if _, err := fmt.Fprintln(f, secretKey); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	return f.Close()
+}
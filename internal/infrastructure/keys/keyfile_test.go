@@ -0,0 +1,68 @@
+package keys_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"nostar/internal/infrastructure/keys"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestLoad(t *testing.T) {
+	fileSK := keys.Generate()
+	filePK, _ := nostr.GetPublicKey(fileSK)
+	envSK := keys.Generate()
+	envPK, _ := nostr.GetPublicKey(envSK)
+
+	path := filepath.Join(t.TempDir(), "relay.key")
+	if err := keys.WriteFile(path, fileSK); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
+	if err := keys.WriteFile(path, envSK); err == nil {
+		t.Fatal("WriteFile() overwrote an existing key")
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("key file mode = %v (%v), want 0600", info.Mode().Perm(), err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		env     string
+		wantPK  string // 空の場合は nil
+		wantErr bool
+	}{
+		{name: "file", path: path, wantPK: filePK},
+		{name: "env overrides file", path: path, env: envSK, wantPK: envPK},
+		{name: "none", wantPK: ""},
+		{name: "missing file", path: filepath.Join(t.TempDir(), "missing"), wantErr: true},
+		{name: "invalid env", path: path, env: "xyz", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(keys.EnvSecretKey, tt.env)
+
+			signer, err := keys.Load(tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Load() succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			if tt.wantPK == "" {
+				if signer != nil {
+					t.Errorf("Load() = %s, want nil", signer.PubKey())
+				}
+				return
+			}
+			if signer == nil || signer.PubKey() != tt.wantPK {
+				t.Errorf("Load() = %v, want %s", signer, tt.wantPK)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package keys
+
+import (
+	"fmt"
+	"strings"
+
+	"nostar/internal/relay/domain"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+// Signer signs events with a secret key held in memory (relay.EventSigner の実装).
+type Signer struct {
+	secretKey string
+	pubKey    string
+}
+
+// NewSigner accepts a hex or nsec encoded secret key.
+func NewSigner(secretKey string) (*Signer, error) {
+	sk := strings.TrimSpace(secretKey)
+	if strings.HasPrefix(sk, "nsec1") {
+		prefix, value, err := nip19.Decode(sk)
+		if err != nil || prefix != "nsec" {
+			return nil, fmt.Errorf("invalid nsec")
+		}
+		sk = value.(string)
+	}
+	sk = strings.ToLower(sk)
+	if len(sk) != 64 {
+		return nil, fmt.Errorf("secret key must be 64 hex characters or nsec")
+	}
+	pk, err := nostr.GetPublicKey(sk)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret key: %w", err)
+	}
+	return &Signer{secretKey: sk, pubKey: pk}, nil
+}
+
+func (s *Signer) PubKey() string {
+	return s.pubKey
+}
+
+func (s *Signer) Sign(evt domain.Event) (domain.Event, error) {
+	tags := make(nostr.Tags, len(evt.Tags))
+	for i, tag := range evt.Tags {
+		tags[i] = nostr.Tag(tag)
+	}
+	ne := nostr.Event{
+		CreatedAt: nostr.Timestamp(evt.CreatedAt),
+		Kind:      evt.Kind,
+		Tags:      tags,
+		Content:   evt.Content,
+	}
+	if err := ne.Sign(s.secretKey); err != nil {
+		return domain.Event{}, fmt.Errorf("failed to sign event: %w", err)
+	}
+	evt.ID = ne.ID
+	evt.PubKey = ne.PubKey
+	evt.Signature = ne.Sig
+	return evt, nil
+}
@@ -0,0 +1,56 @@
+package keys_test
+
+import (
+	"testing"
+
+	"nostar/internal/infrastructure/keys"
+	"nostar/internal/relay/domain"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+func TestSigner(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	nsec, _ := nip19.EncodePrivateKey(sk)
+
+	tests := []struct {
+		name    string
+		key     string
+		wantErr bool
+	}{
+		{name: "hex", key: sk},
+		{name: "nsec", key: nsec},
+		{name: "too short", key: sk[:63], wantErr: true},
+		{name: "npub", key: "npub1xyz", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := keys.NewSigner(tt.key)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("NewSigner() succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewSigner() failed: %v", err)
+			}
+			if signer.PubKey() != pk {
+				t.Errorf("PubKey() = %s, want %s", signer.PubKey(), pk)
+			}
+
+			evt, err := signer.Sign(domain.Event{Kind: 1, CreatedAt: 1700000000, Tags: [][]string{{"d", "x"}}, Content: "hello"})
+			if err != nil {
+				t.Fatalf("Sign() failed: %v", err)
+			}
+			if ok, err := evt.CheckSignature(); !ok {
+				t.Errorf("signed event does not verify: %v", err)
+			}
+			if evt.PubKey != pk {
+				t.Errorf("signed pubkey = %s, want %s", evt.PubKey, pk)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"nostar/internal/relay/domain"
+
+	"go.uber.org/zap"
+)
+
+// minRestartInterval は、プラグインが落ち続ける場合に再起動を連打しないための間隔
+const minRestartInterval = time.Second
+
+// maxResponseSize はプラグインの応答1行の上限
+const maxResponseSize = 1024 * 1024
+
+// request is one line sent to the plugin (strfry write policy plugin protocol).
+type request struct {
+	Type       string       `json:"type"` // 常に "new"
+	Event      domain.Event `json:"event"`
+	ReceivedAt int64        `json:"receivedAt"`
+	SourceType string       `json:"sourceType"`
+	SourceInfo string       `json:"sourceInfo"`
+	Authed     string       `json:"authed,omitempty"`
+}
+
+// response is one line returned by the plugin.
+type response struct {
+	ID     string `json:"id"`
+	Action string `json:"action"` // accept / reject / shadowReject
+	Msg    string `json:"msg"`
+}
+
+// WritePolicy is an EventPolicy that delegates the decision to an external program.
+// イベントごとに JSON を1行 stdin に書き、stdout から1行の JSON を読む（strfry 互換）
+// プロセスは1つだけ起動し、リクエストは直列に処理する
+type WritePolicy struct {
+	command string
+	args    []string
+	timeout time.Duration
+
+	mu        sync.Mutex
+	proc      *process // nil の場合は未起動（次の Check で起動する）
+	lastStart time.Time
+	closed    bool // Close 後は再起動しない
+}
+
+type process struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	lines  chan []byte   // stdout の各行
+	exited chan struct{} // プロセス終了時に close される
+	quit   chan struct{} // stop 時に close される
+
+	pending []string // 呼び出し元がキャンセルされ、まだ応答を読んでいないリクエストのイベント ID（送信順）
+}
+
+func NewWritePolicy(command string, args []string, timeout time.Duration) *WritePolicy {
+	return &WritePolicy{
+		command: command,
+		args:    args,
+		timeout: timeout,
+	}
+}
+
+func (p *WritePolicy) Name() string { return "plugin" }
+
+// Check sends the event to the plugin and converts its answer into a decision.
+// プラグインが応答しない・落ちた場合は error: で拒否し、次回の呼び出しで再起動する
+// ctx がキャンセルされた場合はそのイベントだけを拒否し、プロセスは止めない（応答は次の呼び出しで読み捨てる）
+func (p *WritePolicy) Check(ctx context.Context, in domain.PolicyInput) domain.PolicyDecision {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	res, err := p.call(ctx, in)
+	if err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		zap.S().Debugw("write policy plugin call canceled", "command", p.command, "event_id", in.Event.ID, "error", err)
+		return domain.Reject(domain.ReasonError, "write policy check was canceled")
+	}
+	if err != nil {
+		zap.S().Errorw("write policy plugin failed", "command", p.command, "event_id", in.Event.ID, "error", err)
+		p.stop()
+		return domain.Reject(domain.ReasonError, "write policy plugin is unavailable")
+	}
+
+	switch res.Action {
+	case "accept":
+		return domain.Accept()
+	case "reject", "shadowReject":
+		d := decisionFromMsg(res.Msg)
+		if res.Action == "shadowReject" {
+			d.Action = domain.PolicyShadowReject
+		}
+		return d
+	default:
+		zap.S().Errorw("write policy plugin returned unknown action", "command", p.command, "action", res.Action)
+		return domain.Reject(domain.ReasonError, "write policy plugin returned an invalid response")
+	}
+}
+
+// Close stops the plugin process. 以後の Check は再起動せずに error: で拒否する（serve の終了時に呼ぶ）
+func (p *WritePolicy) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	p.stop()
+	return nil
+}
+
+func (p *WritePolicy) call(ctx context.Context, in domain.PolicyInput) (response, error) {
+	if err := p.ensureStarted(); err != nil {
+		return response{}, err
+	}
+
+	// プラグインは送信順に応答するため、待つ時間には先に送ったリクエストの応答を含める
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+
+	for len(p.proc.pending) > 0 {
+		if _, err := p.receive(ctx, timer, p.proc.pending[0]); err != nil {
+			return response{}, err
+		}
+		p.proc.pending = p.proc.pending[1:]
+	}
+
+	line, err := json.Marshal(request{
+		Type:       "new",
+		Event:      in.Event,
+		ReceivedAt: in.ReceivedAt,
+		SourceType: in.SourceType,
+		SourceInfo: in.SourceInfo,
+		Authed:     in.AuthedPubKey,
+	})
+	if err != nil {
+		return response{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	if _, err := p.proc.stdin.Write(append(line, '\n')); err != nil {
+		return response{}, fmt.Errorf("failed to write to plugin: %w", err)
+	}
+
+	res, err := p.receive(ctx, timer, in.Event.ID)
+	if err != nil && errors.Is(err, ctx.Err()) {
+		// 応答はまだ届くので、次の呼び出しで読み捨てる
+		p.proc.pending = append(p.proc.pending, in.Event.ID)
+	}
+	return res, err
+}
+
+// receive reads the response for the event id.
+// ctx がキャンセルされた場合は ctx.Err() を返す（応答は未読のまま残る）
+func (p *WritePolicy) receive(ctx context.Context, timer *time.Timer, id string) (response, error) {
+	select {
+	case b := <-p.proc.lines:
+		var res response
+		if err := json.Unmarshal(b, &res); err != nil {
+			return response{}, fmt.Errorf("invalid response %q: %w", string(b), err)
+		}
+		if res.ID != id {
+			// 応答がずれている場合は、以降の応答も信用できない
+			return response{}, fmt.Errorf("response id mismatch: got %s, want %s", res.ID, id)
+		}
+		return res, nil
+	case <-p.proc.exited:
+		return response{}, errors.New("plugin exited")
+	case <-timer.C:
+		return response{}, fmt.Errorf("plugin did not respond within %s", p.timeout)
+	case <-ctx.Done():
+		return response{}, ctx.Err()
+	}
+}
+
+func (p *WritePolicy) ensureStarted() error {
+	if p.closed {
+		return errors.New("plugin is closed")
+	}
+	if p.proc != nil {
+		select {
+		case <-p.proc.exited:
+			p.stop()
+		default:
+			return nil
+		}
+	}
+
+	if since := time.Since(p.lastStart); since < minRestartInterval {
+		return fmt.Errorf("plugin restarted %s ago, waiting before restarting again", since.Round(time.Millisecond))
+	}
+	p.lastStart = time.Now()
+
+	cmd := exec.Command(p.command, p.args...)
+	cmd.Stderr = os.Stderr // プラグインのログはそのまま流す
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdoutR, stdoutW := io.Pipe()
+	cmd.Stdout = stdoutW
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start plugin: %w", err)
+	}
+	zap.S().Infow("write policy plugin started", "command", p.command, "pid", cmd.Process.Pid)
+
+	proc := &process{
+		cmd:    cmd,
+		stdin:  stdin,
+		lines:  make(chan []byte),
+		exited: make(chan struct{}),
+		quit:   make(chan struct{}),
+	}
+
+	go func() {
+		err := cmd.Wait()
+		zap.S().Infow("write policy plugin exited", "command", p.command, "error", err)
+		stdoutW.Close()
+		close(proc.exited)
+	}()
+
+	go func() {
+		scanner := bufio.NewScanner(stdoutR)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxResponseSize)
+		for scanner.Scan() {
+			b := append([]byte(nil), scanner.Bytes()...)
+			select {
+			case proc.lines <- b:
+			case <-proc.quit:
+				return
+			}
+		}
+	}()
+
+	p.proc = proc
+	return nil
+}
+
+// stop kills the running process. 呼び出し側で mu を保持していること
+func (p *WritePolicy) stop() {
+	if p.proc == nil {
+		return
+	}
+	close(p.proc.quit)
+	_ = p.proc.stdin.Close()
+	_ = p.proc.cmd.Process.Kill()
+	p.proc = nil
+}
+
+// decisionFromMsg splits "<prefix>: <message>". 既知の prefix がない場合は blocked: とする
+func decisionFromMsg(msg string) domain.PolicyDecision {
+	if prefix, rest, ok := strings.Cut(msg, ": "); ok {
+		switch prefix {
+		case domain.ReasonBlocked, domain.ReasonInvalid, domain.ReasonRateLimited,
+			domain.ReasonPoW, domain.ReasonRestricted, domain.ReasonAuthRequired, domain.ReasonError:
+			return domain.Reject(prefix, "%s", rest)
+		}
+	}
+	if msg == "" {
+		msg = "rejected by write policy"
+	}
+	return domain.Reject(domain.ReasonBlocked, "%s", msg)
+}
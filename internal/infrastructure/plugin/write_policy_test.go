@@ -0,0 +1,202 @@
+package plugin_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"nostar/internal/infrastructure/plugin"
+	"nostar/internal/relay/domain"
+)
+
+// TestHelperProcess is not a real test. テストバイナリ自身をプラグインとして起動する
+// content に応じて応答を変える:
+//   - "spam"   .. reject
+//   - "shh"    .. shadowReject
+//   - "slow"   .. 応答しない
+//   - "delay"  .. 300ms 後に accept
+//   - "crash"  .. 応答せずに終了する
+//   - "authed" .. authed が設定されていれば accept
+//   - その他   .. accept
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("NOSTAR_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req struct {
+			Type       string       `json:"type"`
+			Event      domain.Event `json:"event"`
+			SourceType string       `json:"sourceType"`
+			SourceInfo string       `json:"sourceInfo"`
+			Authed     string       `json:"authed"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			os.Exit(2)
+		}
+
+		action, msg := "accept", ""
+		switch req.Event.Content {
+		case "spam":
+			action, msg = "reject", "blocked: spam from "+req.SourceInfo
+		case "shh":
+			action, msg = "shadowReject", "quiet"
+		case "slow":
+			time.Sleep(time.Hour)
+		case "delay":
+			time.Sleep(300 * time.Millisecond)
+		case "crash":
+			os.Exit(1)
+		case "authed":
+			if req.Authed == "" {
+				action, msg = "reject", "auth-required: login first"
+			}
+		}
+
+		b, _ := json.Marshal(map[string]string{"id": req.Event.ID, "action": action, "msg": msg})
+		fmt.Println(string(b))
+	}
+	os.Exit(0)
+}
+
+func newHelperPolicy(t *testing.T, timeout time.Duration) *plugin.WritePolicy {
+	t.Helper()
+	t.Setenv("NOSTAR_WANT_HELPER_PROCESS", "1")
+	p := plugin.NewWritePolicy(os.Args[0], []string{"-test.run=^TestHelperProcess$"}, timeout)
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func TestWritePolicy_Check(t *testing.T) {
+	p := newHelperPolicy(t, 5*time.Second)
+
+	tests := []struct {
+		name       string
+		in         domain.PolicyInput
+		wantAction domain.PolicyAction
+		wantReason string
+	}{
+		{
+			name:       "accept",
+			in:         domain.PolicyInput{Event: domain.Event{ID: "id1", Content: "gm"}},
+			wantAction: domain.PolicyAccept,
+		},
+		{
+			name:       "reject with prefixed message",
+			in:         domain.PolicyInput{Event: domain.Event{ID: "id2", Content: "spam"}, SourceType: domain.SourceIP4, SourceInfo: "192.0.2.1"},
+			wantAction: domain.PolicyReject,
+			wantReason: "blocked: spam from 192.0.2.1",
+		},
+		{
+			name:       "shadowReject without prefix",
+			in:         domain.PolicyInput{Event: domain.Event{ID: "id3", Content: "shh"}},
+			wantAction: domain.PolicyShadowReject,
+			wantReason: "blocked: quiet",
+		},
+		{
+			name:       "authed pubkey is passed",
+			in:         domain.PolicyInput{Event: domain.Event{ID: "id4", Content: "authed"}, AuthedPubKey: "pubkey1"},
+			wantAction: domain.PolicyAccept,
+		},
+		{
+			name:       "not authed",
+			in:         domain.PolicyInput{Event: domain.Event{ID: "id5", Content: "authed"}},
+			wantAction: domain.PolicyReject,
+			wantReason: "auth-required: login first",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := p.Check(context.Background(), tt.in)
+			if d.Action != tt.wantAction {
+				t.Fatalf("Check() action = %v (%s), want %v", d.Action, d.Reason(), tt.wantAction)
+			}
+			if tt.wantReason != "" && d.Reason() != tt.wantReason {
+				t.Errorf("Check() reason = %q, want %q", d.Reason(), tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestWritePolicy_TimeoutAndRestart(t *testing.T) {
+	p := newHelperPolicy(t, 200*time.Millisecond)
+
+	d := p.Check(context.Background(), domain.PolicyInput{Event: domain.Event{ID: "slow", Content: "slow"}})
+	if d.Action != domain.PolicyReject || d.Prefix != domain.ReasonError {
+		t.Fatalf("Check() = %v (%s), want error: reject on timeout", d.Action, d.Reason())
+	}
+
+	// 再起動の間隔を空けてから、新しいプロセスで処理されることを確認
+	time.Sleep(1100 * time.Millisecond)
+	d = p.Check(context.Background(), domain.PolicyInput{Event: domain.Event{ID: "after", Content: "gm"}})
+	if d.Action != domain.PolicyAccept {
+		t.Fatalf("Check() after restart = %v (%s), want accept", d.Action, d.Reason())
+	}
+}
+
+// TestWritePolicy_Canceled は、呼び出し元のキャンセルでプロセスを止めず、次のイベントを同じプロセスで処理することを確認する
+func TestWritePolicy_Canceled(t *testing.T) {
+	p := newHelperPolicy(t, 5*time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	d := p.Check(ctx, domain.PolicyInput{Event: domain.Event{ID: "delayed", Content: "delay"}})
+	if d.Action != domain.PolicyReject || d.Prefix != domain.ReasonError {
+		t.Fatalf("Check() = %v (%s), want error: reject on cancel", d.Action, d.Reason())
+	}
+
+	// 再起動の間隔を空けずに呼ぶ。プロセスを止めていれば再起動できずに拒否される
+	for _, id := range []string{"next1", "next2"} {
+		d = p.Check(context.Background(), domain.PolicyInput{Event: domain.Event{ID: id, Content: "gm"}})
+		if d.Action != domain.PolicyAccept {
+			t.Fatalf("Check(%s) after cancel = %v (%s), want accept", id, d.Action, d.Reason())
+		}
+	}
+}
+
+func TestWritePolicy_Crash(t *testing.T) {
+	p := newHelperPolicy(t, 5*time.Second)
+
+	d := p.Check(context.Background(), domain.PolicyInput{Event: domain.Event{ID: "crash", Content: "crash"}})
+	if d.Action != domain.PolicyReject || d.Prefix != domain.ReasonError {
+		t.Fatalf("Check() = %v (%s), want error: reject on crash", d.Action, d.Reason())
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	d = p.Check(context.Background(), domain.PolicyInput{Event: domain.Event{ID: "after", Content: "gm"}})
+	if d.Action != domain.PolicyAccept {
+		t.Fatalf("Check() after crash = %v (%s), want accept", d.Action, d.Reason())
+	}
+}
+
+func TestWritePolicy_CommandNotFound(t *testing.T) {
+	p := plugin.NewWritePolicy("/nonexistent/plugin", nil, time.Second)
+	d := p.Check(context.Background(), domain.PolicyInput{Event: domain.Event{ID: "id1"}})
+	if d.Action != domain.PolicyReject || d.Prefix != domain.ReasonError {
+		t.Fatalf("Check() = %v (%s), want error: reject", d.Action, d.Reason())
+	}
+}
+
+func TestWritePolicy_Close(t *testing.T) {
+	p := newHelperPolicy(t, 5*time.Second)
+
+	d := p.Check(context.Background(), domain.PolicyInput{Event: domain.Event{ID: "id1", Content: "gm"}})
+	if d.Action != domain.PolicyAccept {
+		t.Fatalf("Check() = %v (%s), want accept", d.Action, d.Reason())
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+
+	// 終了後はプラグインを再起動しない
+	time.Sleep(1100 * time.Millisecond)
+	d = p.Check(context.Background(), domain.PolicyInput{Event: domain.Event{ID: "id2", Content: "gm"}})
+	if d.Action != domain.PolicyReject || d.Prefix != domain.ReasonError {
+		t.Fatalf("Check() after Close = %v (%s), want error: reject", d.Action, d.Reason())
+	}
+}
@@ -0,0 +1,89 @@
+package upstream
+
+import (
+	"context"
+	"time"
+
+	"nostar/internal/relay"
+	"nostar/internal/relay/domain"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// queryTimeout は1回の REQ で EOSE を待つ上限
+const queryTimeout = 30 * time.Second
+
+// Client is a relay.Upstream backed by a go-nostr relay connection.
+type Client struct {
+	relay *nostr.Relay
+}
+
+// Dial connects to the relay. relay.UpstreamDialer として使う
+func Dial(ctx context.Context, url string) (relay.Upstream, error) {
+	r, err := nostr.RelayConnect(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{relay: r}, nil
+}
+
+// Query sends a REQ and collects events until EOSE (or CLOSED / timeout).
+func (c *Client) Query(ctx context.Context, filter domain.Filter) ([]domain.Event, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	events, err := c.relay.QuerySync(ctx, toNostrFilter(filter))
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]domain.Event, 0, len(events))
+	for _, evt := range events {
+		results = append(results, toDomainEvent(evt))
+	}
+	return results, nil
+}
+
+func (c *Client) Close() error {
+	return c.relay.Close()
+}
+
+func toNostrFilter(f domain.Filter) nostr.Filter {
+	nf := nostr.Filter{
+		IDs:     f.IDs,
+		Authors: f.Authors,
+		Kinds:   f.Kinds,
+	}
+	if len(f.Tags) > 0 {
+		nf.Tags = nostr.TagMap(f.Tags)
+	}
+	if f.Since != nil {
+		since := nostr.Timestamp(*f.Since)
+		nf.Since = &since
+	}
+	if f.Until != nil {
+		until := nostr.Timestamp(*f.Until)
+		nf.Until = &until
+	}
+	if f.Limit != nil {
+		nf.Limit = *f.Limit
+		nf.LimitZero = *f.Limit == 0
+	}
+	return nf
+}
+
+func toDomainEvent(evt *nostr.Event) domain.Event {
+	tags := make([][]string, len(evt.Tags))
+	for i, tag := range evt.Tags {
+		tags[i] = tag
+	}
+	return domain.Event{
+		ID:        evt.ID,
+		PubKey:    evt.PubKey,
+		Signature: evt.Sig,
+		CreatedAt: int64(evt.CreatedAt),
+		Kind:      evt.Kind,
+		Tags:      tags,
+		Content:   evt.Content,
+	}
+}
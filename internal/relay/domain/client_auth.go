@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// KindClientAuth is the NIP-42 client authentication event kind.
+// AUTH メッセージでのみ使い、保存・配信はしない
+const KindClientAuth = 22242
+
+// clientAuthWindow は NIP-42 イベントの created_at として許容する現在時刻とのずれ
+const clientAuthWindow = 10 * time.Minute
+
+// VerifyClientAuth checks a NIP-42 AUTH event against the challenge sent on the connection.
+// relay タグはホスト名だけを比較する（TLS を終端するリバースプロキシ配下でも一致するように）
+func VerifyClientAuth(evt Event, challenge, relayURL string, now time.Time) error {
+	if evt.Kind != KindClientAuth {
+		return fmt.Errorf("kind must be %d", KindClientAuth)
+	}
+	if d := now.Sub(time.Unix(evt.CreatedAt, 0)); d > clientAuthWindow || d < -clientAuthWindow {
+		return errors.New("created_at is too far from the current time")
+	}
+	if c, _ := evt.TagValue("challenge"); challenge == "" || c != challenge {
+		return errors.New("challenge tag does not match")
+	}
+	if r, _ := evt.TagValue("relay"); !sameRelayHost(r, relayURL) {
+		return fmt.Errorf("relay tag does not match %s", relayURL)
+	}
+	if ok, err := evt.CheckSignature(); !ok || err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	return nil
+}
+
+func sameRelayHost(a, b string) bool {
+	ua, err := url.Parse(strings.TrimSpace(a))
+	if err != nil || ua.Hostname() == "" {
+		return false
+	}
+	ub, err := url.Parse(strings.TrimSpace(b))
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(ua.Hostname(), ub.Hostname())
+}
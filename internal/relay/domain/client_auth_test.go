@@ -0,0 +1,101 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"nostar/internal/relay/domain"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestVerifyClientAuth(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	sk := nostr.GeneratePrivateKey()
+
+	sign := func(kind int, createdAt time.Time, relay, challenge string) domain.Event {
+		evt := nostr.Event{
+			Kind:      kind,
+			CreatedAt: nostr.Timestamp(createdAt.Unix()),
+			Tags:      nostr.Tags{{"relay", relay}, {"challenge", challenge}},
+		}
+		if err := evt.Sign(sk); err != nil {
+			t.Fatal(err)
+		}
+		return domain.Event{
+			ID:        evt.ID,
+			PubKey:    evt.PubKey,
+			Signature: evt.Sig,
+			CreatedAt: int64(evt.CreatedAt),
+			Kind:      evt.Kind,
+			Tags:      [][]string{evt.Tags[0], evt.Tags[1]},
+		}
+	}
+
+	tampered := sign(domain.KindClientAuth, now, "wss://relay.example.com", "c1")
+	tampered.Tags = [][]string{{"relay", "wss://relay.example.com"}, {"challenge", "c2"}}
+
+	tests := []struct {
+		name      string
+		event     domain.Event
+		challenge string
+		relayURL  string
+		wantErr   bool
+	}{
+		{
+			name:      "valid",
+			event:     sign(domain.KindClientAuth, now, "wss://relay.example.com/", "c1"),
+			challenge: "c1",
+			relayURL:  "wss://relay.example.com",
+		},
+		{
+			name:      "only the host is compared",
+			event:     sign(domain.KindClientAuth, now.Add(-5*time.Minute), "wss://Relay.Example.com/nostr", "c1"),
+			challenge: "c1",
+			relayURL:  "ws://relay.example.com",
+		},
+		{
+			name:      "wrong kind",
+			event:     sign(1, now, "wss://relay.example.com", "c1"),
+			challenge: "c1",
+			relayURL:  "wss://relay.example.com",
+			wantErr:   true,
+		},
+		{
+			name:      "too old",
+			event:     sign(domain.KindClientAuth, now.Add(-11*time.Minute), "wss://relay.example.com", "c1"),
+			challenge: "c1",
+			relayURL:  "wss://relay.example.com",
+			wantErr:   true,
+		},
+		{
+			name:      "challenge mismatch",
+			event:     sign(domain.KindClientAuth, now, "wss://relay.example.com", "c1"),
+			challenge: "c2",
+			relayURL:  "wss://relay.example.com",
+			wantErr:   true,
+		},
+		{
+			name:      "relay mismatch",
+			event:     sign(domain.KindClientAuth, now, "wss://other.example.com", "c1"),
+			challenge: "c1",
+			relayURL:  "wss://relay.example.com",
+			wantErr:   true,
+		},
+		{
+			name:      "tampered",
+			event:     tampered,
+			challenge: "c2",
+			relayURL:  "wss://relay.example.com",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := domain.VerifyClientAuth(tt.event, tt.challenge, tt.relayURL, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyClientAuth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
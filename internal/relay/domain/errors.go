@@ -0,0 +1,6 @@
+package domain
+
+import "errors"
+
+// ErrNotFound は、ストアに対象のレコードが存在しない場合に返す
+var ErrNotFound = errors.New("not found")
@@ -1,9 +1,13 @@
 package domain
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 type Filter struct {
@@ -11,80 +15,163 @@ type Filter struct {
 	Authors []string `json:"authors,omitempty"`
 	Kinds   []int    `json:"kinds,omitempty"`
 
-	Tags map[string][]string `json:"-"` // tag filters: "#e", "#p", "#t" など
+	Tags map[string][]string `json:"-"` // tag filters: タグ名（"#" を除いた "e", "p", "t" など） -> 値
 
 	Since *int64 `json:"since,omitempty"`
 	Until *int64 `json:"until,omitempty"`
 	Limit *int   `json:"limit,omitempty"`
 
-	Raw map[string]any `json:"-"` // for unknown key
+	Raw map[string]any `json:"-"` // 受け取ったフィルタのオブジェクト全体
 }
 
+// NewFilterFromRaw parses a decoded filter object with the same rules as ParseFilter.
+// Raw には渡された map をそのまま保持する
 func NewFilterFromRaw(raw map[string]any) (Filter, error) {
-	var f Filter
 	b, err := json.Marshal(raw)
 	if err != nil {
-		return f, err
+		return Filter{}, fmt.Errorf("filter must be a JSON object: %w", err)
 	}
+	f, err := ParseFilter(b)
+	if err != nil {
+		return Filter{}, err
+	}
+	f.Raw = raw
+	return f, nil
+}
 
-	// IDs, Authors, Kinds, Since, Until, Limit はこれで定義する
-	if err := json.Unmarshal(b, &f); err != nil {
-		return f, err
+// ParseFilter parses one REQ filter object strictly (NIP-01).
+//
+//   - ids / authors: 64 文字以下の小文字 hex の配列（64 文字未満は前方一致。許可するかは QueryLimits で決める）
+//   - kinds: 0〜65535 の整数の配列
+//   - since / until / limit: 0 以上の整数
+//   - #<タグ名>: 文字列の配列（タグ名は 1〜64 バイト。検索できるかは QueryLimits.IndexedTags で決める）
+//
+// 型が違う値・上記以外のキー（未対応の拡張を含む）はエラーにする（黙って無視すると、意図より広い検索になるため）
+func ParseFilter(data []byte) (Filter, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+		return Filter{}, errors.New("filter must be a JSON object")
 	}
 
-	// タグフィルター (#e, #p, #t など) をパース
-	f.Tags = make(map[string][]string)
-	for key, value := range raw {
-		if len(key) > 0 && key[0] == '#' {
-			tagName := key[1:] // "#e" -> "e"
+	// エラーメッセージが毎回同じになるように、キーの順に検査する
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
 
-			// 値を []string に変換
-			if arr, ok := value.([]any); ok {
-				strArr := make([]string, 0, len(arr))
-				for _, v := range arr {
-					if str, ok := v.(string); ok {
-						strArr = append(strArr, str)
-					}
-				}
-				f.Tags[tagName] = strArr
+	f := Filter{Tags: make(map[string][]string)}
+	for _, key := range keys {
+		value := fields[key]
+		var err error
+		switch {
+		case key == "ids":
+			f.IDs, err = parseHexList(value)
+		case key == "authors":
+			f.Authors, err = parseHexList(value)
+		case key == "kinds":
+			f.Kinds, err = parseKinds(value)
+		case key == "since":
+			f.Since, err = parseNonNegative(value)
+		case key == "until":
+			f.Until, err = parseNonNegative(value)
+		case key == "limit":
+			var limit *int64
+			if limit, err = parseNonNegative(value); err == nil {
+				n := int(*limit)
+				f.Limit = &n
 			}
+		case strings.HasPrefix(key, "#"):
+			name := key[1:]
+			if name == "" || len(name) > maxTagNameLength {
+				err = fmt.Errorf("tag filter must be # followed by a tag name of up to %d bytes", maxTagNameLength)
+				break
+			}
+			f.Tags[name], err = parseStringList(value)
+		default:
+			err = errors.New("unknown filter field")
+		}
+		if err != nil {
+			return Filter{}, fmt.Errorf("%s: %w", key, err)
 		}
 	}
 
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Filter{}, errors.New("filter must be a JSON object")
+	}
 	f.Raw = raw
 	return f, nil
 }
 
-// NewFiltersFromRaw parses multiple raw filter JSON messages into domain Filters.
-// Returns successfully parsed filters and an error if any parsing failed.
-// If some filters fail to parse, successfully parsed filters are still returned,
-// but an error containing all parsing errors is also returned.
+// NewFiltersFromRaw parses the filters of a REQ (or COUNT / NEG-OPEN) with ParseFilter.
+// 1つでも不正なフィルタがあれば、フィルタを返さずに最初のエラーを返す（一部だけで検索しない）
 func NewFiltersFromRaw(rawFilters []json.RawMessage) ([]Filter, error) {
-	var filters []Filter
-	var parseErrors []error
-
+	filters := make([]Filter, 0, len(rawFilters))
 	for i, raw := range rawFilters {
-		var m map[string]any
-		if err := json.Unmarshal(raw, &m); err != nil {
-			parseErrors = append(parseErrors, fmt.Errorf("filter %d: invalid JSON: %w", i, err))
-			continue
+		f, err := ParseFilter(raw)
+		if err != nil {
+			return nil, fmt.Errorf("filter %d: %w", i, err)
 		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
 
-		f, err := NewFilterFromRaw(m)
-		if err != nil {
-			parseErrors = append(parseErrors, fmt.Errorf("filter %d: %w", i, err))
-			continue
+func parseStringList(value json.RawMessage) ([]string, error) {
+	var list []string
+	if err := json.Unmarshal(value, &list); err != nil || list == nil {
+		return nil, errors.New("must be an array of strings")
+	}
+	return list, nil
+}
+
+func parseHexList(value json.RawMessage) ([]string, error) {
+	list, err := parseStringList(value)
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range list {
+		if !isLowerHexPrefix(v, 64) {
+			return nil, fmt.Errorf("value %d must be lowercase hex of at most 64 characters", i)
 		}
+	}
+	return list, nil
+}
 
-		filters = append(filters, f)
+func parseKinds(value json.RawMessage) ([]int, error) {
+	var list []json.RawMessage
+	if err := json.Unmarshal(value, &list); err != nil || list == nil {
+		return nil, errors.New("must be an array of integers")
 	}
+	kinds := make([]int, 0, len(list))
+	for i, v := range list {
+		n, ok := parseInteger(v)
+		if !ok || n < 0 || n > 65535 {
+			return nil, fmt.Errorf("value %d must be an integer between 0 and 65535", i)
+		}
+		kinds = append(kinds, int(n))
+	}
+	return kinds, nil
+}
 
-	// 複数エラーを結合
-	if len(parseErrors) > 0 {
-		return filters, errors.Join(parseErrors...)
+func parseNonNegative(value json.RawMessage) (*int64, error) {
+	n, ok := parseInteger(value)
+	if !ok || n < 0 {
+		return nil, errors.New("must be a non-negative integer")
 	}
+	return &n, nil
+}
 
-	return filters, nil
+// parseInteger は JSON の数値リテラルを整数として読む
+// 文字列（"1"）・小数（1.5）・指数表記（1e3）は整数として扱わない
+func parseInteger(value json.RawMessage) (int64, bool) {
+	v := bytes.TrimSpace(value)
+	if len(v) == 0 || (v[0] != '-' && (v[0] < '0' || v[0] > '9')) {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(string(v), 10, 64)
+	return n, err == nil
 }
 
 // Matches returns whether the event satisfies this filter.
@@ -94,7 +181,7 @@ func (f Filter) Matches(evt Event) bool {
 	if len(f.IDs) > 0 {
 		found := false
 		for _, id := range f.IDs {
-			if evt.ID == id {
+			if strings.HasPrefix(evt.ID, id) { // 64 文字未満は前方一致
 				found = true
 				break
 			}
@@ -108,7 +195,7 @@ func (f Filter) Matches(evt Event) bool {
 	if len(f.Authors) > 0 {
 		found := false
 		for _, author := range f.Authors {
-			if evt.PubKey == author {
+			if strings.HasPrefix(evt.PubKey, author) {
 				found = true
 				break
 			}
@@ -142,31 +229,28 @@ func (f Filter) Matches(evt Event) bool {
 		return false // until より新しい場合
 	}
 
-	// Tags filter (#e, #p, #t, etc.)
-	for fillterTagName, filterTagValues := range f.Tags {
-		// ex. tagName = "e" .. #e
-		// ex. tagValues = ["event1", "event2"]
-		found := false
-		for _, tag := range evt.Tags {
-			evtTagName := tag[0]
-			evtTagValue := tag[1]
-			if len(tag) >= 2 && evtTagName == fillterTagName {
-				// Check if any of the tag values match
-				for _, value := range filterTagValues {
-					if evtTagValue == value {
-						found = true
-						break
-					}
-				}
-				if found {
-					break
-				}
-			}
-		}
-		if !found {
+	// Tags filter (#e, #p, #t, #emoji など。名前は大文字・小文字を区別する)
+	for name, values := range f.Tags {
+		if !evt.hasTagValue(name, values) {
 			return false
 		}
 	}
 
 	return true
 }
+
+// hasTagValue は e に名前が name で、値（tag[1]）が values のいずれかのタグがあるかを返す
+// 値のないタグ（["t"] など）は一致しない
+func (e *Event) hasTagValue(name string, values []string) bool {
+	for _, tag := range e.Tags {
+		if len(tag) < 2 || tag[0] != name {
+			continue
+		}
+		for _, v := range values {
+			if tag[1] == v {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -4,9 +4,17 @@ import (
 	"encoding/json"
 	"nostar/internal/relay/domain"
 	"reflect"
+	"strings"
 	"testing"
 )
 
+var (
+	testID1     = strings.Repeat("1", 64)
+	testID2     = strings.Repeat("2", 64)
+	testAuthor1 = strings.Repeat("a", 64)
+	testAuthor2 = strings.Repeat("b", 64)
+)
+
 func inttoPtr(i int) *int {
 	return &i
 }
@@ -23,24 +31,24 @@ func TestNewFilterFromRaw(t *testing.T) {
 		{
 			name: "normal (no tags)",
 			raw: map[string]any{
-				"ids":     []string{"id1", "id2"},
-				"authors": []string{"author1", "author2"},
+				"ids":     []string{testID1, testID2},
+				"authors": []string{testAuthor1, testAuthor2},
 				"kinds":   []int64{1, 2},
 				"since":   1633072800,
 				"until":   1633159200,
 				"limit":   10,
 			},
 			want: domain.Filter{
-				IDs:     []string{"id1", "id2"},
-				Authors: []string{"author1", "author2"},
+				IDs:     []string{testID1, testID2},
+				Authors: []string{testAuthor1, testAuthor2},
 				Kinds:   []int{1, 2},
 				Tags:    map[string][]string{},
 				Since:   int64toPtr(1633072800),
 				Until:   int64toPtr(1633159200),
 				Limit:   inttoPtr(10),
 				Raw: map[string]any{
-					"ids":     []string{"id1", "id2"},
-					"authors": []string{"author1", "author2"},
+					"ids":     []string{testID1, testID2},
+					"authors": []string{testAuthor1, testAuthor2},
 					"kinds":   []int64{1, 2},
 					"since":   1633072800,
 					"until":   1633159200,
@@ -51,16 +59,16 @@ func TestNewFilterFromRaw(t *testing.T) {
 		{
 			name: "with tags",
 			raw: map[string]any{
-				"ids":     []any{"id1"},
-				"authors": []any{"author1"},
+				"ids":     []any{testID1},
+				"authors": []any{testAuthor1},
 				"#e":      []any{"event1", "event2"},
 				"#p":      []any{"pubkey1"},
 				"#t":      []any{"nostr", "bitcoin"},
 				"limit":   5,
 			},
 			want: domain.Filter{
-				IDs:     []string{"id1"},
-				Authors: []string{"author1"},
+				IDs:     []string{testID1},
+				Authors: []string{testAuthor1},
 				Tags: map[string][]string{
 					"e": {"event1", "event2"},
 					"p": {"pubkey1"},
@@ -68,8 +76,8 @@ func TestNewFilterFromRaw(t *testing.T) {
 				},
 				Limit: inttoPtr(5),
 				Raw: map[string]any{
-					"ids":     []any{"id1"},
-					"authors": []any{"author1"},
+					"ids":     []any{testID1},
+					"authors": []any{testAuthor1},
 					"#e":      []any{"event1", "event2"},
 					"#p":      []any{"pubkey1"},
 					"#t":      []any{"nostr", "bitcoin"},
@@ -121,6 +129,46 @@ func TestFilter_Matches(t *testing.T) {
 			event:  createEvent("id1", "pub1", 1000, 1, nil),
 			want:   true,
 		},
+		{
+			name: "valueless tag does not match (and does not panic)",
+			filter: domain.Filter{
+				Tags: map[string][]string{"t": {"nostr"}},
+			},
+			event: createEvent("id1", "pub1", 1000, 1, [][]string{{"t"}, {}}),
+			want:  false,
+		},
+		{
+			name: "multi-letter tag",
+			filter: domain.Filter{
+				Tags: map[string][]string{"emoji": {"cat"}},
+			},
+			event: createEvent("id1", "pub1", 1000, 1, [][]string{{"emoji", "cat", "https://example.com/cat.png"}}),
+			want:  true,
+		},
+		{
+			name: "tag names are case-sensitive",
+			filter: domain.Filter{
+				Tags: map[string][]string{"T": {"nostr"}},
+			},
+			event: createEvent("id1", "pub1", 1000, 1, [][]string{{"t", "nostr"}}),
+			want:  false,
+		},
+		{
+			name: "ID prefix - only the beginning",
+			filter: domain.Filter{
+				IDs: []string{"abcd"},
+			},
+			event: createEvent(testID1[:60]+"abcd", "pub1", 1000, 1, nil),
+			want:  false, // 前方一致のみ（途中の一致は対象外）
+		},
+		{
+			name: "author prefix - match",
+			filter: domain.Filter{
+				Authors: []string{"aaaa"},
+			},
+			event: createEvent("id1", testAuthor1, 1000, 1, nil),
+			want:  true,
+		},
 		{
 			name: "IDs filter - match",
 			filter: domain.Filter{
@@ -329,13 +377,13 @@ func TestNewFiltersFromRaw(t *testing.T) {
 		{
 			name: "multiple valid filters",
 			rawFilters: []json.RawMessage{
-				toRawMessage(`{"ids": ["id1"], "authors": ["author1"]}`),
+				toRawMessage(`{"ids": ["` + testID1 + `"], "authors": ["` + testAuthor1 + `"]}`),
 				toRawMessage(`{"kinds": [1], "since": 1000}`),
 			},
 			wantFilters: []domain.Filter{
 				{
-					IDs:     []string{"id1"},
-					Authors: []string{"author1"},
+					IDs:     []string{testID1},
+					Authors: []string{testAuthor1},
 					Tags:    map[string][]string{},
 				},
 				{
@@ -370,21 +418,12 @@ func TestNewFiltersFromRaw(t *testing.T) {
 		{
 			name: "some invalid JSON",
 			rawFilters: []json.RawMessage{
-				toRawMessage(`{"ids": ["id1"]}`), // valid
-				toRawMessage(`invalid json`),     // invalid
-				toRawMessage(`{"kinds": [1]}`),   // valid
+				toRawMessage(`{"ids": ["` + testID1 + `"]}`), // valid
+				toRawMessage(`invalid json`),                 // invalid
+				toRawMessage(`{"kinds": [1]}`),               // valid
 			},
-			wantFilters: []domain.Filter{
-				{
-					IDs:  []string{"id1"},
-					Tags: map[string][]string{},
-				},
-				{
-					Kinds: []int{1},
-					Tags:  map[string][]string{},
-				},
-			},
-			wantErr: true, // 1つのフィルタが失敗
+			wantFilters: []domain.Filter{}, // 一部だけのフィルタは返さない
+			wantErr:     true,              // 1つのフィルタが失敗
 		},
 		{
 			name: "all invalid filters",
@@ -404,16 +443,11 @@ func TestNewFiltersFromRaw(t *testing.T) {
 		{
 			name: "invalid filter structure",
 			rawFilters: []json.RawMessage{
-				toRawMessage(`{"ids": ["id1"]}`),       // valid
-				toRawMessage(`{"kinds": ["not_int"]}`), // kinds should be []int, not []string
-			},
-			wantFilters: []domain.Filter{
-				{
-					IDs:  []string{"id1"},
-					Tags: map[string][]string{},
-				},
+				toRawMessage(`{"ids": ["` + testID1 + `"]}`), // valid
+				toRawMessage(`{"kinds": ["not_int"]}`),       // kinds should be []int, not []string
 			},
-			wantErr: true, // kinds の型変換エラー
+			wantFilters: []domain.Filter{},
+			wantErr:     true, // kinds の型変換エラー
 		},
 	}
 
@@ -443,3 +477,38 @@ func TestNewFiltersFromRaw(t *testing.T) {
 		})
 	}
 }
+
+func TestParseFilter_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{name: "not an object", data: `["kinds"]`, wantErr: "filter must be a JSON object"},
+		{name: "null", data: `null`, wantErr: "filter must be a JSON object"},
+		{name: "non-hex id", data: `{"ids": ["xyz"]}`, wantErr: "ids: value 0 must be lowercase hex of at most 64 characters"},
+		{name: "empty id", data: `{"ids": [""]}`, wantErr: "ids: value 0 must be lowercase hex of at most 64 characters"},
+		{name: "long id", data: `{"ids": ["` + strings.Repeat("a", 65) + `"]}`, wantErr: "ids: value 0 must be lowercase hex of at most 64 characters"},
+		{name: "uppercase author", data: `{"authors": ["` + strings.Repeat("A", 64) + `"]}`, wantErr: "authors: value 0 must be lowercase hex of at most 64 characters"},
+		{name: "ids is not an array", data: `{"ids": "` + testID1 + `"}`, wantErr: "ids: must be an array of strings"},
+		{name: "kind is a string", data: `{"kinds": ["1"]}`, wantErr: "kinds: value 0 must be an integer between 0 and 65535"},
+		{name: "kinds is not an array", data: `{"kinds": 1}`, wantErr: "kinds: must be an array of integers"},
+		{name: "kind is a fraction", data: `{"kinds": [1.5]}`, wantErr: "kinds: value 0 must be an integer between 0 and 65535"},
+		{name: "kind is too large", data: `{"kinds": [65536]}`, wantErr: "kinds: value 0 must be an integer between 0 and 65535"},
+		{name: "negative since", data: `{"since": -1}`, wantErr: "since: must be a non-negative integer"},
+		{name: "until is a string", data: `{"until": "1000"}`, wantErr: "until: must be a non-negative integer"},
+		{name: "limit is null", data: `{"limit": null}`, wantErr: "limit: must be a non-negative integer"},
+		{name: "too long tag key", data: `{"#` + strings.Repeat("x", 65) + `": ["x"]}`, wantErr: "#" + strings.Repeat("x", 65) + ": tag filter must be # followed by a tag name of up to 64 bytes"},
+		{name: "empty tag key", data: `{"#": ["x"]}`, wantErr: "#: tag filter must be # followed by a tag name of up to 64 bytes"},
+		{name: "tag value is a number", data: `{"#t": [1]}`, wantErr: "#t: must be an array of strings"},
+		{name: "unknown field", data: `{"search": "nostr"}`, wantErr: "search: unknown filter field"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := domain.ParseFilter([]byte(tt.data))
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("ParseFilter() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package domain
+
+import (
+	"maps"
+	"sort"
+)
+
+// NIP-29 のグループのモデレーションイベント（グループの管理者が h タグを付けて送る）
+const (
+	KindGroupPutUser      = 9000
+	KindGroupRemoveUser   = 9001
+	KindGroupEditMetadata = 9002
+	KindGroupDeleteEvent  = 9005
+	KindGroupCreate       = 9007
+	KindGroupDelete       = 9008
+	KindGroupCreateInvite = 9009
+)
+
+// NIP-29 のユーザーからの参加・退出リクエスト
+const (
+	KindGroupJoinRequest  = 9021
+	KindGroupLeaveRequest = 9022
+)
+
+// NIP-29 のグループの状態（リレーの鍵で署名し、d タグにグループ ID を持つ addressable イベント）
+const (
+	KindGroupMetadata = 39000
+	KindGroupAdmins   = 39001
+	KindGroupMembers  = 39002
+	KindGroupRoles    = 39003
+)
+
+// グループのロール
+const (
+	GroupRoleAdmin     = "admin"     // すべてのモデレーションができる
+	GroupRoleModerator = "moderator" // メンバーの追加・削除、イベントの削除、招待コードの作成ができる
+)
+
+const maxGroupIDLength = 64
+
+// IsGroupModerationKind reports whether kind is a NIP-29 moderation event (9000-9020).
+func IsGroupModerationKind(kind int) bool {
+	return kind >= 9000 && kind <= 9020
+}
+
+// IsGroupMetadataKind reports whether kind is relay-generated NIP-29 group state (39000-39003).
+func IsGroupMetadataKind(kind int) bool {
+	return kind >= KindGroupMetadata && kind <= KindGroupRoles
+}
+
+// GroupID returns the "h" tag value that names the group the event belongs to.
+func (e *Event) GroupID() (string, bool) {
+	return e.TagValue("h")
+}
+
+// ValidGroupID reports whether id consists of a-z0-9-_ (NIP-29).
+func ValidGroupID(id string) bool {
+	if id == "" || len(id) > maxGroupIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if !('a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// Group is the state of a NIP-29 group hosted on this relay.
+type Group struct {
+	ID      string
+	Name    string
+	About   string
+	Picture string
+	Private bool                // メンバーだけが読める
+	Closed  bool                // 参加に招待コードが必要
+	Members map[string][]string // pubkey -> ロール（一般のメンバーは空）
+	Invites map[string]struct{} // 招待コード
+}
+
+// NewGroup returns a private, closed group whose only member is its creator (admin).
+func NewGroup(id, creator string) *Group {
+	return &Group{
+		ID:      id,
+		Private: true,
+		Closed:  true,
+		Members: map[string][]string{creator: {GroupRoleAdmin}},
+		Invites: make(map[string]struct{}),
+	}
+}
+
+// Clone returns a copy of the group that can be updated without affecting g.
+func (g *Group) Clone() *Group {
+	c := *g
+	c.Members = maps.Clone(g.Members) // ロールのスライスは Apply で差し替えるため共有してよい
+	c.Invites = maps.Clone(g.Invites)
+	return &c
+}
+
+// IsMember reports whether pubkey is a member of the group.
+func (g *Group) IsMember(pubkey string) bool {
+	_, ok := g.Members[pubkey]
+	return ok
+}
+
+// HasRole reports whether pubkey has the role in the group.
+func (g *Group) HasRole(pubkey, role string) bool {
+	for _, r := range g.Members[pubkey] {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// CanModerate reports whether pubkey may send a moderation event of the kind.
+func (g *Group) CanModerate(pubkey string, kind int) bool {
+	if g.HasRole(pubkey, GroupRoleAdmin) {
+		return true
+	}
+	if !g.HasRole(pubkey, GroupRoleModerator) {
+		return false
+	}
+	switch kind {
+	case KindGroupPutUser, KindGroupRemoveUser, KindGroupDeleteEvent, KindGroupCreateInvite:
+		return true
+	}
+	return false
+}
+
+// AcceptsJoin reports whether a join request (kind 9021) may join the group.
+// 招待制のグループでは有効な招待コードが必要
+func (g *Group) AcceptsJoin(evt Event) bool {
+	if !g.Closed {
+		return true
+	}
+	code, _ := evt.TagValue("code")
+	_, ok := g.Invites[code]
+	return ok
+}
+
+// VisibleTo reports whether an event of the group can be delivered to a connection authenticated as authed.
+// 非公開のグループはメンバーだけ、招待コード（kind 9009）は管理者だけが読める
+// 参加リクエスト（kind 9021）は招待コードを含むため、公開のグループでも管理者と本人だけが読める
+func (g *Group) VisibleTo(evt Event, authed PubKeySet) bool {
+	switch evt.Kind {
+	case KindGroupCreateInvite:
+		return g.anyCanModerate(authed, KindGroupCreateInvite)
+	case KindGroupJoinRequest:
+		return authed.Contains(evt.PubKey) || g.anyCanModerate(authed, KindGroupPutUser)
+	}
+	if !g.Private {
+		return true
+	}
+	for pk := range authed {
+		if g.IsMember(pk) {
+			return true
+		}
+	}
+	return false
+}
+
+// anyCanModerate は authed のいずれかが kind のモデレーションイベントを送れるかを返す
+func (g *Group) anyCanModerate(authed PubKeySet, kind int) bool {
+	for pk := range authed {
+		if g.CanModerate(pk, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply updates the group with an accepted moderation event or join / leave request.
+// 権限の確認は呼び出し側で行う
+func (g *Group) Apply(evt Event) {
+	switch evt.Kind {
+	case KindGroupPutUser:
+		for _, tag := range evt.Tags {
+			if len(tag) >= 2 && tag[0] == "p" {
+				g.Members[tag[1]] = append([]string(nil), tag[2:]...)
+			}
+		}
+	case KindGroupRemoveUser:
+		for _, pk := range evt.Recipients() {
+			delete(g.Members, pk)
+		}
+	case KindGroupEditMetadata:
+		for _, tag := range evt.Tags {
+			g.applyMetadataTag(tag)
+		}
+	case KindGroupCreateInvite:
+		for _, tag := range evt.Tags {
+			if len(tag) >= 2 && tag[0] == "code" {
+				g.Invites[tag[1]] = struct{}{}
+			}
+		}
+	case KindGroupJoinRequest:
+		if !g.IsMember(evt.PubKey) {
+			g.Members[evt.PubKey] = nil
+		}
+	case KindGroupLeaveRequest:
+		delete(g.Members, evt.PubKey)
+	}
+}
+
+// LoadState restores the group from a stored relay-generated state event (kind 39000-39002).
+func (g *Group) LoadState(evt Event) {
+	switch evt.Kind {
+	case KindGroupMetadata:
+		g.Private, g.Closed = false, false
+		for _, tag := range evt.Tags {
+			g.applyMetadataTag(tag)
+		}
+	case KindGroupAdmins:
+		for _, tag := range evt.Tags {
+			if len(tag) >= 2 && tag[0] == "p" {
+				g.Members[tag[1]] = append([]string(nil), tag[2:]...)
+			}
+		}
+	case KindGroupMembers:
+		for _, pk := range evt.Recipients() {
+			if !g.IsMember(pk) {
+				g.Members[pk] = nil
+			}
+		}
+	}
+}
+
+func (g *Group) applyMetadataTag(tag []string) {
+	if len(tag) == 0 {
+		return
+	}
+	switch tag[0] {
+	case "private":
+		g.Private = true
+	case "public":
+		g.Private = false
+	case "closed":
+		g.Closed = true
+	case "open":
+		g.Closed = false
+	}
+	if len(tag) < 2 {
+		return
+	}
+	switch tag[0] {
+	case "name":
+		g.Name = tag[1]
+	case "about":
+		g.About = tag[1]
+	case "picture":
+		g.Picture = tag[1]
+	}
+}
+
+// StateEvents returns the unsigned relay-generated state events (kind 39000-39003) of the group.
+func (g *Group) StateEvents(createdAt int64) []Event {
+	d := []string{"d", g.ID}
+
+	metadata := [][]string{d}
+	for _, kv := range [][2]string{{"name", g.Name}, {"about", g.About}, {"picture", g.Picture}} {
+		if kv[1] != "" {
+			metadata = append(metadata, []string{kv[0], kv[1]})
+		}
+	}
+	if g.Private {
+		metadata = append(metadata, []string{"private"})
+	} else {
+		metadata = append(metadata, []string{"public"})
+	}
+	if g.Closed {
+		metadata = append(metadata, []string{"closed"})
+	} else {
+		metadata = append(metadata, []string{"open"})
+	}
+
+	pubkeys := make([]string, 0, len(g.Members))
+	for pk := range g.Members {
+		pubkeys = append(pubkeys, pk)
+	}
+	sort.Strings(pubkeys)
+	admins := [][]string{d}
+	members := [][]string{d}
+	for _, pk := range pubkeys {
+		if roles := g.Members[pk]; len(roles) > 0 {
+			admins = append(admins, append([]string{"p", pk}, roles...))
+		}
+		members = append(members, []string{"p", pk})
+	}
+
+	roles := [][]string{
+		d,
+		{"role", GroupRoleAdmin, "can do everything"},
+		{"role", GroupRoleModerator, "can add and remove members, delete events and create invites"},
+	}
+
+	return []Event{
+		{Kind: KindGroupMetadata, CreatedAt: createdAt, Tags: metadata},
+		{Kind: KindGroupAdmins, CreatedAt: createdAt, Tags: admins},
+		{Kind: KindGroupMembers, CreatedAt: createdAt, Tags: members},
+		{Kind: KindGroupRoles, CreatedAt: createdAt, Tags: roles},
+	}
+}
@@ -0,0 +1,128 @@
+package domain_test
+
+import (
+	"testing"
+
+	"nostar/internal/relay/domain"
+)
+
+func TestValidGroupID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{id: "team-a_1", want: true},
+		{id: "", want: false},
+		{id: "Team", want: false},
+		{id: "team a", want: false},
+		{id: "relay.example.com'team", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			if got := domain.ValidGroupID(tt.id); got != tt.want {
+				t.Errorf("ValidGroupID(%q) = %v, want %v", tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroup_CanModerate(t *testing.T) {
+	g := domain.NewGroup("team", "admin")
+	g.Apply(domain.Event{Kind: domain.KindGroupPutUser, Tags: [][]string{{"p", "mod", domain.GroupRoleModerator}, {"p", "member"}}})
+
+	tests := []struct {
+		name   string
+		pubkey string
+		kind   int
+		want   bool
+	}{
+		{name: "admin edits metadata", pubkey: "admin", kind: domain.KindGroupEditMetadata, want: true},
+		{name: "admin deletes the group", pubkey: "admin", kind: domain.KindGroupDelete, want: true},
+		{name: "moderator adds a member", pubkey: "mod", kind: domain.KindGroupPutUser, want: true},
+		{name: "moderator creates an invite", pubkey: "mod", kind: domain.KindGroupCreateInvite, want: true},
+		{name: "moderator cannot edit metadata", pubkey: "mod", kind: domain.KindGroupEditMetadata, want: false},
+		{name: "member cannot moderate", pubkey: "member", kind: domain.KindGroupRemoveUser, want: false},
+		{name: "outsider cannot moderate", pubkey: "outsider", kind: domain.KindGroupDeleteEvent, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := g.CanModerate(tt.pubkey, tt.kind); got != tt.want {
+				t.Errorf("CanModerate(%s, %d) = %v, want %v", tt.pubkey, tt.kind, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroup_VisibleTo(t *testing.T) {
+	private := domain.NewGroup("team", "admin")
+	private.Apply(domain.Event{Kind: domain.KindGroupJoinRequest, PubKey: "member"})
+	public := domain.NewGroup("open", "admin")
+	public.Apply(domain.Event{Kind: domain.KindGroupEditMetadata, Tags: [][]string{{"public"}}})
+
+	message := domain.Event{Kind: 9, Tags: [][]string{{"h", "team"}}}
+	invite := domain.Event{Kind: domain.KindGroupCreateInvite, Tags: [][]string{{"h", "team"}, {"code", "x"}}}
+	join := domain.Event{Kind: domain.KindGroupJoinRequest, PubKey: "joiner", Tags: [][]string{{"h", "open"}, {"code", "x"}}}
+
+	tests := []struct {
+		name   string
+		group  *domain.Group
+		event  domain.Event
+		authed []string
+		want   bool
+	}{
+		{name: "private group to a member", group: private, event: message, authed: []string{"member"}, want: true},
+		{name: "private group without auth", group: private, event: message, want: false},
+		{name: "private group to an outsider", group: private, event: message, authed: []string{"outsider"}, want: false},
+		{name: "public group without auth", group: public, event: message, want: true},
+		{name: "invite to an admin", group: public, event: invite, authed: []string{"admin"}, want: true},
+		{name: "invite to a member", group: private, event: invite, authed: []string{"member"}, want: false},
+		{name: "invite of a public group without auth", group: public, event: invite, want: false},
+		{name: "join request of a public group without auth", group: public, event: join, want: false},
+		{name: "join request to a member", group: public, event: join, authed: []string{"member"}, want: false},
+		{name: "join request to its author", group: public, event: join, authed: []string{"joiner"}, want: true},
+		{name: "join request to an admin", group: public, event: join, authed: []string{"admin"}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.group.VisibleTo(tt.event, domain.NewPubKeySet(tt.authed)); got != tt.want {
+				t.Errorf("VisibleTo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroup_StateEvents_LoadState(t *testing.T) {
+	g := domain.NewGroup("team", "admin")
+	g.Apply(domain.Event{Kind: domain.KindGroupEditMetadata, Tags: [][]string{{"h", "team"}, {"name", "Team"}, {"about", "our team"}, {"open"}}})
+	g.Apply(domain.Event{Kind: domain.KindGroupPutUser, Tags: [][]string{{"h", "team"}, {"p", "mod", domain.GroupRoleModerator}}})
+	g.Apply(domain.Event{Kind: domain.KindGroupJoinRequest, PubKey: "member"})
+	g.Apply(domain.Event{Kind: domain.KindGroupJoinRequest, PubKey: "leaver"})
+	g.Apply(domain.Event{Kind: domain.KindGroupLeaveRequest, PubKey: "leaver"})
+
+	events := g.StateEvents(1700000000)
+	kinds := []int{domain.KindGroupMetadata, domain.KindGroupAdmins, domain.KindGroupMembers, domain.KindGroupRoles}
+	if len(events) != len(kinds) {
+		t.Fatalf("StateEvents() returned %d events, want %d", len(events), len(kinds))
+	}
+	loaded := &domain.Group{ID: "team", Members: map[string][]string{}, Invites: map[string]struct{}{}}
+	for i, evt := range events {
+		if evt.Kind != kinds[i] || evt.DTag() != "team" {
+			t.Errorf("events[%d] = kind %d d=%q, want kind %d d=team", i, evt.Kind, evt.DTag(), kinds[i])
+		}
+		loaded.LoadState(evt)
+	}
+
+	if loaded.Name != "Team" || loaded.About != "our team" || !loaded.Private || loaded.Closed {
+		t.Errorf("loaded metadata = %+v", loaded)
+	}
+	want := map[string][]string{"admin": {domain.GroupRoleAdmin}, "mod": {domain.GroupRoleModerator}, "member": nil}
+	if len(loaded.Members) != len(want) {
+		t.Fatalf("loaded members = %v, want %v", loaded.Members, want)
+	}
+	for pk, roles := range want {
+		got, ok := loaded.Members[pk]
+		if !ok || len(got) != len(roles) || (len(roles) > 0 && got[0] != roles[0]) {
+			t.Errorf("loaded member %s = %v (%v), want %v", pk, got, ok, roles)
+		}
+	}
+}
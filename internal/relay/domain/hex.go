@@ -0,0 +1,26 @@
+package domain
+
+// isLowerHex は、s が長さ n の小文字 16 進文字列かどうかを返す
+// pubkey / event id は 64 文字の小文字 hex で表現される
+func isLowerHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// isLowerHexPrefix は、s が 1〜n 文字の小文字 16 進文字列かどうかを返す（ids / authors の前方一致）
+func isLowerHexPrefix(s string, n int) bool {
+	return len(s) > 0 && len(s) <= n && isLowerHex(s, len(s))
+}
+
+// IsValidPubKey returns whether s is a 32-byte lowercase hex public key.
+func IsValidPubKey(s string) bool {
+	return isLowerHex(s, 64)
+}
@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// KindHTTPAuth is the NIP-98 HTTP Auth event kind.
+const KindHTTPAuth = 27235
+
+// httpAuthWindow は NIP-98 イベントの created_at として許容する現在時刻とのずれ
+const httpAuthWindow = 60 * time.Second
+
+// VerifyHTTPAuth checks a NIP-98 HTTP Auth event for a request and returns nil if it is valid.
+// url は比較前に正規化する（ws/wss は http/https とみなし、末尾の / を無視する）
+// body が空でない場合は payload タグ（SHA-256）が必須
+func VerifyHTTPAuth(evt Event, url, method string, body []byte, now time.Time) error {
+	if evt.Kind != KindHTTPAuth {
+		return fmt.Errorf("kind must be %d", KindHTTPAuth)
+	}
+	if d := now.Sub(time.Unix(evt.CreatedAt, 0)); d > httpAuthWindow || d < -httpAuthWindow {
+		return errors.New("created_at is too far from the current time")
+	}
+	if u, _ := evt.TagValue("u"); normalizeAuthURL(u) != normalizeAuthURL(url) {
+		return fmt.Errorf("u tag does not match %s", url)
+	}
+	if m, _ := evt.TagValue("method"); !strings.EqualFold(m, method) {
+		return fmt.Errorf("method tag does not match %s", method)
+	}
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		if p, _ := evt.TagValue("payload"); !strings.EqualFold(p, hex.EncodeToString(sum[:])) {
+			return errors.New("payload tag does not match the request body")
+		}
+	}
+	if ok, err := evt.CheckSignature(); !ok || err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	return nil
+}
+
+func normalizeAuthURL(u string) string {
+	u = strings.TrimSpace(u)
+	switch {
+	case strings.HasPrefix(u, "wss://"):
+		u = "https://" + strings.TrimPrefix(u, "wss://")
+	case strings.HasPrefix(u, "ws://"):
+		u = "http://" + strings.TrimPrefix(u, "ws://")
+	}
+	return strings.TrimSuffix(u, "/")
+}
@@ -0,0 +1,121 @@
+package domain_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"nostar/internal/relay/domain"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestVerifyHTTPAuth(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"method":"supportedmethods","params":[]}`)
+	sum := sha256.Sum256(body)
+	payload := hex.EncodeToString(sum[:])
+	sk := nostr.GeneratePrivateKey()
+
+	sign := func(kind int, createdAt time.Time, tags nostr.Tags) domain.Event {
+		evt := nostr.Event{Kind: kind, CreatedAt: nostr.Timestamp(createdAt.Unix()), Tags: tags}
+		if err := evt.Sign(sk); err != nil {
+			t.Fatal(err)
+		}
+		domainTags := make([][]string, len(evt.Tags))
+		for i, tag := range evt.Tags {
+			domainTags[i] = tag
+		}
+		return domain.Event{
+			ID:        evt.ID,
+			PubKey:    evt.PubKey,
+			Signature: evt.Sig,
+			CreatedAt: int64(evt.CreatedAt),
+			Kind:      evt.Kind,
+			Tags:      domainTags,
+			Content:   evt.Content,
+		}
+	}
+	tags := func(u, method, payload string) nostr.Tags {
+		return nostr.Tags{{"u", u}, {"method", method}, {"payload", payload}}
+	}
+
+	tampered := sign(domain.KindHTTPAuth, now, tags("https://relay.example.com", "POST", payload))
+	tampered.Tags = [][]string{{"u", "https://relay.example.com"}, {"method", "GET"}, {"payload", payload}}
+
+	tests := []struct {
+		name    string
+		event   domain.Event
+		url     string
+		method  string // 空の場合は POST
+		wantErr bool
+	}{
+		{
+			name:  "valid",
+			event: sign(domain.KindHTTPAuth, now, tags("https://relay.example.com/", "POST", payload)),
+			url:   "https://relay.example.com",
+		},
+		{
+			name:  "wss url is equivalent to https",
+			event: sign(domain.KindHTTPAuth, now.Add(-30*time.Second), tags("wss://relay.example.com", "post", payload)),
+			url:   "https://relay.example.com/",
+		},
+		{
+			name:    "wrong kind",
+			event:   sign(1, now, tags("https://relay.example.com", "POST", payload)),
+			url:     "https://relay.example.com",
+			wantErr: true,
+		},
+		{
+			name:    "too old",
+			event:   sign(domain.KindHTTPAuth, now.Add(-2*time.Minute), tags("https://relay.example.com", "POST", payload)),
+			url:     "https://relay.example.com",
+			wantErr: true,
+		},
+		{
+			name:    "url mismatch",
+			event:   sign(domain.KindHTTPAuth, now, tags("https://other.example.com", "POST", payload)),
+			url:     "https://relay.example.com",
+			wantErr: true,
+		},
+		{
+			name:    "method mismatch",
+			event:   sign(domain.KindHTTPAuth, now, tags("https://relay.example.com", "GET", payload)),
+			url:     "https://relay.example.com",
+			wantErr: true,
+		},
+		{
+			name:    "payload mismatch",
+			event:   sign(domain.KindHTTPAuth, now, tags("https://relay.example.com", "POST", "00")),
+			url:     "https://relay.example.com",
+			wantErr: true,
+		},
+		{
+			name:    "missing payload",
+			event:   sign(domain.KindHTTPAuth, now, nostr.Tags{{"u", "https://relay.example.com"}, {"method", "POST"}}),
+			url:     "https://relay.example.com",
+			wantErr: true,
+		},
+		{
+			// 署名後に method タグを書き換えたイベント
+			name:    "tampered tags",
+			event:   tampered,
+			url:     "https://relay.example.com",
+			method:  "GET",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = "POST"
+			}
+			err := domain.VerifyHTTPAuth(tt.event, tt.url, method, body, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyHTTPAuth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
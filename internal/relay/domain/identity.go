@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// NIP-05 の local-part は a-z0-9-_. のみ許可される（大文字小文字は区別しない）
+var identityNamePattern = regexp.MustCompile(`^[a-z0-9\-_.]+$`)
+
+// maxIdentityNameLength は DB のカラム長に合わせる
+const maxIdentityNameLength = 64
+
+// Identity represents a NIP-05 identifier (name@domain) hosted by this relay.
+type Identity struct {
+	Name   string   // local-part（正規化済み）
+	PubKey string   // hex pubkey
+	Relays []string // このユーザが使っているリレーのヒント
+}
+
+// NormalizeIdentityName lowercases the local-part, since NIP-05 names are case-insensitive.
+func NormalizeIdentityName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// ValidateIdentityName checks that the (normalized) name uses only the allowed characters.
+func ValidateIdentityName(name string) error {
+	if name == "" {
+		return fmt.Errorf("identity name is empty")
+	}
+	if len(name) > maxIdentityNameLength {
+		return fmt.Errorf("identity name is too long (max %d)", maxIdentityNameLength)
+	}
+	if !identityNamePattern.MatchString(name) {
+		return fmt.Errorf("identity name %q contains invalid characters (allowed: a-z0-9-_.)", name)
+	}
+	return nil
+}
+
+// Validate performs basic validation on the identity fields.
+func (i *Identity) Validate() error {
+	if err := ValidateIdentityName(i.Name); err != nil {
+		return err
+	}
+	if !IsValidPubKey(i.PubKey) {
+		return fmt.Errorf("identity pubkey must be 64 lowercase hex characters")
+	}
+	for _, r := range i.Relays {
+		if !strings.HasPrefix(r, "wss://") && !strings.HasPrefix(r, "ws://") {
+			return fmt.Errorf("relay hint %q must be a ws:// or wss:// URL", r)
+		}
+	}
+	return nil
+}
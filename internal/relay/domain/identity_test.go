@@ -0,0 +1,82 @@
+package domain_test
+
+import (
+	"nostar/internal/relay/domain"
+	"testing"
+)
+
+func TestValidateIdentityName(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{name: "simple name", input: "bob", wantErr: false},
+		{name: "allowed symbols", input: "bob-1_test.x", wantErr: false},
+		{name: "root identifier", input: "_", wantErr: false},
+		{name: "empty", input: "", wantErr: true},
+		{name: "uppercase (not normalized)", input: "Bob", wantErr: true},
+		{name: "contains @", input: "bob@example.com", wantErr: true},
+		{name: "contains space", input: "bob smith", wantErr: true},
+		{name: "non-ascii", input: "ぼぶ", wantErr: true},
+		{name: "too long", input: "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotErr := domain.ValidateIdentityName(tt.input)
+			if (gotErr != nil) != tt.wantErr {
+				t.Errorf("ValidateIdentityName(%q) error = %v, wantErr %v", tt.input, gotErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIdentity_Validate(t *testing.T) {
+	pubkey := "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+
+	tests := []struct {
+		name     string
+		identity domain.Identity
+		wantErr  bool
+	}{
+		{
+			name:     "valid identity",
+			identity: domain.Identity{Name: "bob", PubKey: pubkey, Relays: []string{"wss://relay.example.com"}},
+			wantErr:  false,
+		},
+		{
+			name:     "invalid name",
+			identity: domain.Identity{Name: "bob!", PubKey: pubkey},
+			wantErr:  true,
+		},
+		{
+			name:     "uppercase hex pubkey",
+			identity: domain.Identity{Name: "bob", PubKey: "3BF0C63FCB93463407AF97A5E5EE64FA883D107EF9E558472C4EB9AAAEFA459D"},
+			wantErr:  true,
+		},
+		{
+			name:     "npub is not accepted (must be hex)",
+			identity: domain.Identity{Name: "bob", PubKey: "npub180cvv07tjdrrgpa0j7j7tmnyl2yr6yr7l8j4s3evf6u64th6gkwsyjh6w6"},
+			wantErr:  true,
+		},
+		{
+			name:     "relay hint is not websocket URL",
+			identity: domain.Identity{Name: "bob", PubKey: pubkey, Relays: []string{"https://relay.example.com"}},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotErr := tt.identity.Validate()
+			if (gotErr != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", gotErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNormalizeIdentityName(t *testing.T) {
+	if got := domain.NormalizeIdentityName(" Bob "); got != "bob" {
+		t.Errorf("NormalizeIdentityName() = %q, want %q", got, "bob")
+	}
+}
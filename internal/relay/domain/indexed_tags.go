@@ -0,0 +1,59 @@
+package domain
+
+import "sort"
+
+// maxTagNameLength はタグフィルタ（#<name>）に使えるタグ名の最大バイト数
+const maxTagNameLength = 64
+
+// IndexedTags is the set of tag names stored in event_tags, i.e. usable in tag filters of historical queries.
+// nil の場合は NIP-01 の1文字の英字タグ（a-z, A-Z）を索引付けする
+// ライブ配信の判定（Filter.Matches）は、索引に関係なくすべてのタグ名に対応する
+type IndexedTags map[string]struct{}
+
+// NewIndexedTags returns the set of names, or nil (single-letter tags) if names is empty.
+func NewIndexedTags(names []string) IndexedTags {
+	if len(names) == 0 {
+		return nil
+	}
+	t := make(IndexedTags, len(names))
+	for _, name := range names {
+		t[name] = struct{}{}
+	}
+	return t
+}
+
+// Contains reports whether tags with the name are indexed.
+func (t IndexedTags) Contains(name string) bool {
+	if t == nil {
+		return isSingleLetter(name)
+	}
+	_, ok := t[name]
+	return ok
+}
+
+// Names returns the indexed tag names in order (NIP-11 で公開する).
+func (t IndexedTags) Names() []string {
+	var names []string
+	if t == nil {
+		for c := 'A'; c <= 'Z'; c++ {
+			names = append(names, string(c))
+		}
+		for c := 'a'; c <= 'z'; c++ {
+			names = append(names, string(c))
+		}
+		return names
+	}
+	for name := range t {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isSingleLetter(name string) bool {
+	if len(name) != 1 {
+		return false
+	}
+	c := name[0]
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,18 @@
+package domain
+
+// NIP-01 の kind の分類
+
+// IsReplaceableKind returns true for kinds where only the latest event per pubkey is kept.
+func IsReplaceableKind(kind int) bool {
+	return kind == 0 || kind == 3 || (10000 <= kind && kind < 20000)
+}
+
+// IsEphemeralKind returns true for kinds that are not expected to be stored.
+func IsEphemeralKind(kind int) bool {
+	return 20000 <= kind && kind < 30000
+}
+
+// IsAddressableKind returns true for kinds where only the latest event per (pubkey, kind, d tag) is kept.
+func IsAddressableKind(kind int) bool {
+	return 30000 <= kind && kind < 40000
+}
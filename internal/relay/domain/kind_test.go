@@ -0,0 +1,38 @@
+package domain_test
+
+import (
+	"nostar/internal/relay/domain"
+	"testing"
+)
+
+func TestKindClassification(t *testing.T) {
+	tests := []struct {
+		kind            int
+		wantReplaceable bool
+		wantEphemeral   bool
+		wantAddressable bool
+	}{
+		{kind: 0, wantReplaceable: true},
+		{kind: 1},
+		{kind: 3, wantReplaceable: true},
+		{kind: 9999},
+		{kind: 10000, wantReplaceable: true},
+		{kind: 19999, wantReplaceable: true},
+		{kind: 20000, wantEphemeral: true},
+		{kind: 29999, wantEphemeral: true},
+		{kind: 30000, wantAddressable: true},
+		{kind: 39999, wantAddressable: true},
+		{kind: 40000},
+	}
+	for _, tt := range tests {
+		if got := domain.IsReplaceableKind(tt.kind); got != tt.wantReplaceable {
+			t.Errorf("IsReplaceableKind(%d) = %v, want %v", tt.kind, got, tt.wantReplaceable)
+		}
+		if got := domain.IsEphemeralKind(tt.kind); got != tt.wantEphemeral {
+			t.Errorf("IsEphemeralKind(%d) = %v, want %v", tt.kind, got, tt.wantEphemeral)
+		}
+		if got := domain.IsAddressableKind(tt.kind); got != tt.wantAddressable {
+			t.Errorf("IsAddressableKind(%d) = %v, want %v", tt.kind, got, tt.wantAddressable)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package domain
+
+import "time"
+
+// BanType is what a NIP-86 management entry refers to.
+type BanType string
+
+const (
+	BanPubKey BanType = "pubkey" // 書き込みを拒否する pubkey
+	BanEvent  BanType = "event"  // 保存・再投稿を拒否するイベント ID
+	BanIP     BanType = "ip"     // 接続を拒否する IP アドレス
+)
+
+// Ban is a banned pubkey / event or a blocked IP set through the management API.
+type Ban struct {
+	Type      BanType
+	Value     string
+	Reason    string
+	CreatedAt time.Time
+}
+
+// NIP-86 で変更できる NIP-11 の項目（リレー設定のキー）
+const (
+	SettingRelayName        = "name"
+	SettingRelayDescription = "description"
+)
@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrHidden は、モデレーションで非表示にされた pubkey のイベントを保存した場合に EventStore.Save が返す
+// イベントは非表示の状態で保存されており、配信しないことを呼び出し側に伝えるためのもの
+var ErrHidden = errors.New("event is hidden by moderation")
+
+// HideTarget is what a moderation hide applies to.
+type HideTarget string
+
+const (
+	HideEvent  HideTarget = "event"  // 1つのイベント
+	HidePubKey HideTarget = "pubkey" // ある pubkey のすべてのイベント（今後保存されるものも含む）
+)
+
+// Hide is a moderation decision that hides events from REQ results and live delivery.
+// 非表示にしたイベントは削除せず、unhide で元に戻せる
+type Hide struct {
+	Target    HideTarget
+	Value     string // イベント ID / pubkey（hex）
+	Reason    string
+	Actor     string // 操作した人（CLI のユーザ名 / 管理者の pubkey など）
+	CreatedAt time.Time
+}
+
+// ModerationAction is an entry type of the moderation audit log.
+type ModerationAction string
+
+const (
+	ActionHide   ModerationAction = "hide"
+	ActionUnhide ModerationAction = "unhide"
+	ActionVanish ModerationAction = "vanish" // NIP-62 の request to vanish による削除
+)
+
+// ModerationLogEntry is one record of the moderation audit log.
+type ModerationLogEntry struct {
+	ID        int64
+	Action    ModerationAction
+	Target    HideTarget
+	Value     string
+	Reason    string
+	Actor     string
+	CreatedAt time.Time
+}
+
+// ParseHideTarget parses "event" / "pubkey" and normalizes the value.
+func ParseHideTarget(target, value string) (HideTarget, string, error) {
+	switch HideTarget(target) {
+	case HideEvent:
+		value = strings.ToLower(strings.TrimSpace(value))
+		if !isLowerHex(value, 64) {
+			return "", "", fmt.Errorf("invalid event id: %s", value)
+		}
+		return HideEvent, value, nil
+	case HidePubKey:
+		pk, err := ParsePubKey(value)
+		if err != nil {
+			return "", "", err
+		}
+		return HidePubKey, pk, nil
+	}
+	return "", "", fmt.Errorf("unknown hide target: %q (event / pubkey)", target)
+}
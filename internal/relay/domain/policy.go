@@ -0,0 +1,94 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+)
+
+// PolicyAction is the outcome of an EventPolicy.
+type PolicyAction int
+
+const (
+	PolicyAccept       PolicyAction = iota // 次のポリシーへ進む
+	PolicyReject                           // OK false で拒否する
+	PolicyShadowReject                     // クライアントには OK true を返すが、保存も配信もしない
+)
+
+func (a PolicyAction) String() string {
+	switch a {
+	case PolicyAccept:
+		return "accept"
+	case PolicyReject:
+		return "reject"
+	case PolicyShadowReject:
+		return "shadowReject"
+	default:
+		return fmt.Sprintf("PolicyAction(%d)", int(a))
+	}
+}
+
+// イベントの受信経路（strfry の sourceType に合わせる）
+const (
+	SourceIP4   = "IP4"
+	SourceIP6   = "IP6"
+	SourceSync  = "Sync"
+	SourceRelay = "Relay" // リレー自身が署名したイベント（RelayService.Publish）
+)
+
+// PolicyInput is what an EventPolicy gets to decide on.
+type PolicyInput struct {
+	Event        Event
+	ReceivedAt   int64  // リレーが受信した UNIX 時刻
+	SourceType   string // SourceIP4 など
+	SourceInfo   string // 送信元 IP アドレスなど
+	AuthedPubKey string // NIP-42 で認証済みの pubkey（未認証の場合は空）
+}
+
+// PolicyDecision is returned by EventPolicy.Check.
+type PolicyDecision struct {
+	Action  PolicyAction
+	Prefix  string // ReasonBlocked など（Reject / ShadowReject の場合）
+	Message string
+}
+
+// Accept lets the event continue to the next policy.
+func Accept() PolicyDecision {
+	return PolicyDecision{Action: PolicyAccept}
+}
+
+// Reject refuses the event with a machine-readable prefix.
+func Reject(prefix, format string, args ...any) PolicyDecision {
+	return PolicyDecision{
+		Action:  PolicyReject,
+		Prefix:  prefix,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Reason returns the decision as "<prefix>: <message>".
+func (d PolicyDecision) Reason() string {
+	if d.Prefix == "" {
+		return d.Message
+	}
+	return fmt.Sprintf("%s: %s", d.Prefix, d.Message)
+}
+
+// EventPolicy decides whether an incoming event is accepted.
+// ポリシーは RelayService に登録された順に評価され、最初に Accept 以外を返したものが採用される
+type EventPolicy interface {
+	Name() string
+	Check(ctx context.Context, in PolicyInput) PolicyDecision
+}
+
+// PolicyChain evaluates policies in order.
+type PolicyChain []EventPolicy
+
+// Evaluate returns the first non-accept decision and the policy that made it.
+func (c PolicyChain) Evaluate(ctx context.Context, in PolicyInput) (PolicyDecision, EventPolicy) {
+	for _, p := range c {
+		if d := p.Check(ctx, in); d.Action != PolicyAccept {
+			return d, p
+		}
+	}
+	return Accept(), nil
+}
@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+const (
+	KindEncryptedDM = 4    // NIP-04 の暗号化 DM
+	KindGiftWrap    = 1059 // NIP-59 の gift wrap（NIP-17 の DM を包む）
+)
+
+// IsPrivateKind reports whether events of the kind are delivered only to their author and recipients.
+func IsPrivateKind(kind int) bool {
+	return kind == KindEncryptedDM || kind == KindGiftWrap
+}
+
+// VisibleTo reports whether e can be delivered to a connection authenticated (NIP-42) as authed.
+// 非公開の kind は、作者または p タグの受信者として認証した接続にのみ配信する
+func (e *Event) VisibleTo(authed PubKeySet) bool {
+	if !IsPrivateKind(e.Kind) {
+		return true
+	}
+	if authed.Contains(e.PubKey) {
+		return true
+	}
+	for _, tag := range e.Tags {
+		if len(tag) >= 2 && tag[0] == "p" && authed.Contains(tag[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestsPrivateKinds reports whether the filter explicitly asks for private kinds (kinds に 4 / 1059 を含む).
+// このようなフィルタは、認証していない接続では CLOSED（auth-required:）にする
+func (f Filter) RequestsPrivateKinds() bool {
+	for _, k := range f.Kinds {
+		if IsPrivateKind(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// RestrictPrivateKinds splits a filter that asks for private kinds so that the store only reads
+// the private events authored by or addressed (p タグ) to authed.
+// 見えないイベントで limit が埋まらないように、検索の前に絞り込む（配信前の VisibleTo の確認は引き続き行う）
+// 公開の kind は元の条件のまま別のフィルタに残す。該当する pubkey がない場合、非公開の kind のフィルタは返さない
+func (f Filter) RestrictPrivateKinds(authed PubKeySet) []Filter {
+	if !f.RequestsPrivateKinds() {
+		return []Filter{f}
+	}
+
+	var public, private []int
+	for _, k := range f.Kinds {
+		if IsPrivateKind(k) {
+			private = append(private, k)
+		} else {
+			public = append(public, k)
+		}
+	}
+
+	var res []Filter
+	if len(public) > 0 {
+		pub := f
+		pub.Kinds = public
+		res = append(res, pub)
+	}
+
+	// 作者として: authors（前方一致を含む）に一致する認証済みの pubkey
+	var authors, recipients []string
+	for _, pk := range slices.Sorted(maps.Keys(authed)) {
+		if matchesAnyPrefix(pk, f.Authors) {
+			authors = append(authors, pk)
+		}
+		if values, ok := f.Tags["p"]; !ok || slices.Contains(values, pk) {
+			recipients = append(recipients, pk)
+		}
+	}
+	if len(authors) > 0 {
+		byAuthor := f
+		byAuthor.Kinds = private
+		byAuthor.Authors = authors
+		res = append(res, byAuthor)
+	}
+	// 受信者として: authors はそのまま、#p を認証済みの pubkey に絞る
+	if len(recipients) > 0 {
+		byRecipient := f
+		byRecipient.Kinds = private
+		byRecipient.Tags = make(map[string][]string, len(f.Tags)+1)
+		maps.Copy(byRecipient.Tags, f.Tags)
+		byRecipient.Tags["p"] = recipients
+		res = append(res, byRecipient)
+	}
+	return res
+}
+
+// matchesAnyPrefix は prefixes が空か、pk がそのいずれかで始まるかを返す
+func matchesAnyPrefix(pk string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(pk, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package domain_test
+
+import (
+	"fmt"
+	"testing"
+
+	"nostar/internal/relay/domain"
+)
+
+func TestEvent_VisibleTo(t *testing.T) {
+	author := "author"
+	recipient := "recipient"
+
+	tests := []struct {
+		name   string
+		event  domain.Event
+		authed []string
+		want   bool
+	}{
+		{
+			name:  "public kinds are visible to anyone",
+			event: domain.Event{Kind: 1, PubKey: author},
+			want:  true,
+		},
+		{
+			name:  "gift wrap is hidden without auth",
+			event: domain.Event{Kind: domain.KindGiftWrap, PubKey: author, Tags: [][]string{{"p", recipient}}},
+			want:  false,
+		},
+		{
+			name:   "gift wrap is visible to the recipient",
+			event:  domain.Event{Kind: domain.KindGiftWrap, PubKey: author, Tags: [][]string{{"p", recipient}}},
+			authed: []string{"other", recipient},
+			want:   true,
+		},
+		{
+			name:   "DM is visible to the author",
+			event:  domain.Event{Kind: domain.KindEncryptedDM, PubKey: author, Tags: [][]string{{"p", recipient}}},
+			authed: []string{author},
+			want:   true,
+		},
+		{
+			name:   "DM is hidden from others",
+			event:  domain.Event{Kind: domain.KindEncryptedDM, PubKey: author, Tags: [][]string{{"p", recipient}, {"p"}}},
+			authed: []string{"other"},
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var authed domain.PubKeySet
+			if tt.authed != nil {
+				authed = domain.NewPubKeySet(tt.authed)
+			}
+			if got := tt.event.VisibleTo(authed); got != tt.want {
+				t.Errorf("VisibleTo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilter_RestrictPrivateKinds(t *testing.T) {
+	authed := domain.NewPubKeySet([]string{"bob", "alice"})
+
+	tests := []struct {
+		name   string
+		filter domain.Filter
+		want   []domain.Filter
+	}{
+		{
+			name:   "public kinds are kept as is",
+			filter: domain.Filter{Kinds: []int{1}},
+			want:   []domain.Filter{{Kinds: []int{1}}},
+		},
+		{
+			name:   "private kinds by author or recipient",
+			filter: domain.Filter{Kinds: []int{domain.KindGiftWrap}},
+			want: []domain.Filter{
+				{Kinds: []int{domain.KindGiftWrap}, Authors: []string{"alice", "bob"}},
+				{Kinds: []int{domain.KindGiftWrap}, Tags: map[string][]string{"p": {"alice", "bob"}}},
+			},
+		},
+		{
+			name:   "public kinds are split off",
+			filter: domain.Filter{Kinds: []int{1, domain.KindEncryptedDM}},
+			want: []domain.Filter{
+				{Kinds: []int{1}},
+				{Kinds: []int{domain.KindEncryptedDM}, Authors: []string{"alice", "bob"}},
+				{Kinds: []int{domain.KindEncryptedDM}, Tags: map[string][]string{"p": {"alice", "bob"}}},
+			},
+		},
+		{
+			name:   "authors and #p are narrowed",
+			filter: domain.Filter{Kinds: []int{domain.KindEncryptedDM}, Authors: []string{"al", "carol"}, Tags: map[string][]string{"p": {"bob", "dave"}}},
+			want: []domain.Filter{
+				{Kinds: []int{domain.KindEncryptedDM}, Authors: []string{"alice"}, Tags: map[string][]string{"p": {"bob", "dave"}}},
+				{Kinds: []int{domain.KindEncryptedDM}, Authors: []string{"al", "carol"}, Tags: map[string][]string{"p": {"bob"}}},
+			},
+		},
+		{
+			name:   "no authed pubkey matches",
+			filter: domain.Filter{Kinds: []int{domain.KindEncryptedDM}, Authors: []string{"carol"}, Tags: map[string][]string{"p": {"dave"}}},
+			want:   nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.filter.RestrictPrivateKinds(authed)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("RestrictPrivateKinds() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
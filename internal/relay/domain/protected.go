@@ -0,0 +1,12 @@
+package domain
+
+// IsProtected reports whether e carries the NIP-70 ["-"] tag.
+// protected イベントは、作者本人として認証（NIP-42）した接続からのみ受け付ける
+func (e *Event) IsProtected() bool {
+	for _, tag := range e.Tags {
+		if len(tag) >= 1 && tag[0] == "-" {
+			return true
+		}
+	}
+	return false
+}
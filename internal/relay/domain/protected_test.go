@@ -0,0 +1,28 @@
+package domain_test
+
+import (
+	"testing"
+
+	"nostar/internal/relay/domain"
+)
+
+func TestEvent_IsProtected(t *testing.T) {
+	tests := []struct {
+		name string
+		tags [][]string
+		want bool
+	}{
+		{name: "no tags", want: false},
+		{name: "protected", tags: [][]string{{"p", "abc"}, {"-"}}, want: true},
+		{name: "dash as a value is not protected", tags: [][]string{{"t", "-"}}, want: false},
+		{name: "empty tag", tags: [][]string{{}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evt := domain.Event{Tags: tt.tags}
+			if got := evt.IsProtected(); got != tt.want {
+				t.Errorf("IsProtected() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
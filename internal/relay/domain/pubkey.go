@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+// ParsePubKey accepts a hex or npub encoded public key and returns it as lowercase hex.
+func ParsePubKey(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "npub1") {
+		prefix, value, err := nip19.Decode(s)
+		if err != nil || prefix != "npub" {
+			return "", fmt.Errorf("invalid npub: %s", s)
+		}
+		s = value.(string)
+	}
+
+	s = strings.ToLower(s)
+	if !IsValidPubKey(s) {
+		return "", fmt.Errorf("invalid pubkey: %s", s)
+	}
+	return s, nil
+}
+
+// PubKeySet is a set of hex pubkeys. 生成後は変更しない（差し替えで更新する）
+type PubKeySet map[string]struct{}
+
+func NewPubKeySet(pubkeys []string) PubKeySet {
+	set := make(PubKeySet, len(pubkeys))
+	for _, pk := range pubkeys {
+		set[pk] = struct{}{}
+	}
+	return set
+}
+
+func (s PubKeySet) Contains(pubkey string) bool {
+	_, ok := s[pubkey]
+	return ok
+}
@@ -0,0 +1,106 @@
+package domain
+
+import "time"
+
+// QueryPath is the condition a filter is expected to be narrowed by.
+// 遅いクエリのログに出す分類で、実際に使うインデックスは PostgreSQL のプランナが決める
+type QueryPath string
+
+const (
+	QueryByIDs     QueryPath = "ids"        // 主キー
+	QueryByTags    QueryPath = "tags"       // event_tags (name, value, created_at)
+	QueryByAuthors QueryPath = "authors"    // events (pubkey, created_at)
+	QueryByTime    QueryPath = "created_at" // since 以降の範囲（kinds と組み合わせる場合は events (kind, created_at)）
+	QueryByKinds   QueryPath = "kinds"      // events (kind, created_at)
+	QueryFullScan  QueryPath = "scan"       // 絞り込みなし（新しい順に limit 件）
+)
+
+// QueryPath classifies the filter by its most selective condition.
+// 上から順に絞り込みが強い（1件あたりの候補が少ない）条件を選ぶ
+func (f Filter) QueryPath() QueryPath {
+	switch {
+	case len(f.IDs) > 0:
+		return QueryByIDs
+	case len(f.Tags) > 0:
+		return QueryByTags
+	case len(f.Authors) > 0:
+		return QueryByAuthors
+	case f.Since != nil:
+		// since は読む範囲を区切るため、kinds だけより先に判定する
+		return QueryByTime
+	case len(f.Kinds) > 0:
+		return QueryByKinds
+	}
+	return QueryFullScan
+}
+
+// Unbounded reports whether the filter can match an unbounded part of the history.
+// ids / タグ / authors / since のいずれもない（kinds だけ、または空の）フィルタ
+func (f Filter) Unbounded() bool {
+	p := f.QueryPath()
+	return p == QueryByKinds || p == QueryFullScan
+}
+
+// QueryLimits are the cost limits applied to REQ filters.
+// 0 の項目は制限しない
+type QueryLimits struct {
+	DefaultLimit    int           // limit のないフィルタに適用する件数
+	MaxLimit        int           // limit の上限（超える場合は切り詰める）
+	RejectUnbounded bool          // Unbounded なフィルタを拒否する（false の場合は limit で絞り込むだけ）
+	Timeout         time.Duration // 1つの REQ のクエリのタイムアウト
+	SlowQuery       time.Duration // これより遅いクエリをログに出す
+
+	PrefixMatch     bool // ids / authors の前方一致（64 文字未満の値）を許可する
+	MinPrefixLength int  // 前方一致に使える最短の長さ（短い前方一致は全件に近い検索になるため）
+
+	IndexedTags IndexedTags // タグフィルタに使えるタグ名（nil の場合は1文字の英字）
+}
+
+// Plan returns the filter narrowed to the limits, or a RejectError for a filter that is too broad.
+// 元のフィルタ（ライブ配信の判定に使う）は変更しない
+func (l QueryLimits) Plan(f Filter) (Filter, error) {
+	if err := l.checkPrefixes(f); err != nil {
+		return f, err
+	}
+	for name := range f.Tags {
+		// 索引のないタグは event_tags で引けないため、全件を読まずに拒否する
+		if !l.IndexedTags.Contains(name) {
+			return f, NewRejectError(ReasonInvalid, "tag #%s is not indexed on this relay", name)
+		}
+	}
+	if l.RejectUnbounded && f.Unbounded() {
+		return f, NewRejectError(ReasonBlocked, "filter is too broad, add ids, authors, tags or since")
+	}
+
+	limit := -1
+	if f.Limit != nil {
+		limit = *f.Limit
+	} else if l.DefaultLimit > 0 {
+		limit = l.DefaultLimit
+	}
+	if l.MaxLimit > 0 && (limit < 0 || limit > l.MaxLimit) {
+		limit = l.MaxLimit
+	}
+	if limit >= 0 {
+		f.Limit = &limit
+	}
+	return f, nil
+}
+
+// checkPrefixes は ids / authors の前方一致が許可されていて、十分に長いことを確認する
+func (l QueryLimits) checkPrefixes(f Filter) error {
+	for _, values := range [][]string{f.IDs, f.Authors} {
+		for _, v := range values {
+			if len(v) == 64 {
+				continue
+			}
+			if !l.PrefixMatch {
+				return NewRejectError(ReasonInvalid, "prefix matching is not supported, use 64-character ids and authors")
+			}
+			if len(v) < l.MinPrefixLength {
+				return NewRejectError(ReasonInvalid, "prefix %s is too short, use at least %d characters", v, l.MinPrefixLength)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package domain_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"nostar/internal/relay/domain"
+)
+
+func TestFilter_QueryPath(t *testing.T) {
+	since := int64(1000)
+
+	tests := []struct {
+		name   string
+		filter domain.Filter
+		want   domain.QueryPath
+	}{
+		{name: "ids win over everything", filter: domain.Filter{IDs: []string{"a"}, Authors: []string{"b"}, Kinds: []int{1}}, want: domain.QueryByIDs},
+		{name: "tags", filter: domain.Filter{Tags: map[string][]string{"e": {"a"}}, Kinds: []int{1}}, want: domain.QueryByTags},
+		{name: "authors", filter: domain.Filter{Authors: []string{"b"}, Kinds: []int{1}}, want: domain.QueryByAuthors},
+		{name: "since bounds kinds", filter: domain.Filter{Kinds: []int{1}, Since: &since}, want: domain.QueryByTime},
+		{name: "since", filter: domain.Filter{Since: &since}, want: domain.QueryByTime},
+		{name: "kinds", filter: domain.Filter{Kinds: []int{1}}, want: domain.QueryByKinds},
+		{name: "empty", filter: domain.Filter{}, want: domain.QueryFullScan},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.QueryPath(); got != tt.want {
+				t.Errorf("QueryPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryLimits_Plan(t *testing.T) {
+	limit := func(i int) *int { return &i }
+	author := strings.Repeat("a", 64)
+
+	tests := []struct {
+		name       string
+		limits     domain.QueryLimits
+		filter     domain.Filter
+		wantLimit  *int
+		wantReject string // 拒否の prefix（空の場合は成功）
+	}{
+		{
+			name:      "default limit",
+			limits:    domain.QueryLimits{DefaultLimit: 100, MaxLimit: 500},
+			filter:    domain.Filter{Kinds: []int{1}},
+			wantLimit: limit(100),
+		},
+		{
+			name:      "limit is capped",
+			limits:    domain.QueryLimits{DefaultLimit: 100, MaxLimit: 500},
+			filter:    domain.Filter{Authors: []string{author}, Limit: limit(10000)},
+			wantLimit: limit(500),
+		},
+		{
+			name:      "limit within the cap is kept",
+			limits:    domain.QueryLimits{DefaultLimit: 100, MaxLimit: 500},
+			filter:    domain.Filter{Authors: []string{author}, Limit: limit(300)},
+			wantLimit: limit(300),
+		},
+		{
+			name:      "limit 0 is kept",
+			limits:    domain.QueryLimits{DefaultLimit: 100, MaxLimit: 500},
+			filter:    domain.Filter{Authors: []string{author}, Limit: limit(0)},
+			wantLimit: limit(0),
+		},
+		{
+			name:      "max limit without default",
+			limits:    domain.QueryLimits{MaxLimit: 500},
+			filter:    domain.Filter{},
+			wantLimit: limit(500),
+		},
+		{
+			name:      "no limits",
+			limits:    domain.QueryLimits{},
+			filter:    domain.Filter{},
+			wantLimit: nil,
+		},
+		{
+			name:       "unbounded filter is rejected",
+			limits:     domain.QueryLimits{MaxLimit: 500, RejectUnbounded: true},
+			filter:     domain.Filter{Kinds: []int{1}},
+			wantReject: domain.ReasonBlocked,
+		},
+		{
+			name:      "bounded filter is accepted",
+			limits:    domain.QueryLimits{MaxLimit: 500, RejectUnbounded: true},
+			filter:    domain.Filter{Kinds: []int{1}, Tags: map[string][]string{"t": {"nostr"}}},
+			wantLimit: limit(500),
+		},
+		{
+			name:      "kinds with since is bounded",
+			limits:    domain.QueryLimits{MaxLimit: 500, RejectUnbounded: true},
+			filter:    domain.Filter{Kinds: []int{1}, Since: func(i int64) *int64 { return &i }(1000)},
+			wantLimit: limit(500),
+		},
+		{
+			name:       "multi-letter tag is not indexed by default",
+			limits:     domain.QueryLimits{},
+			filter:     domain.Filter{Tags: map[string][]string{"emoji": {"cat"}}},
+			wantReject: domain.ReasonInvalid,
+		},
+		{
+			name:      "configured tag is indexed",
+			limits:    domain.QueryLimits{IndexedTags: domain.NewIndexedTags([]string{"e", "emoji"})},
+			filter:    domain.Filter{Tags: map[string][]string{"emoji": {"cat"}}},
+			wantLimit: nil,
+		},
+		{
+			name:       "tag missing from the configured names is rejected",
+			limits:     domain.QueryLimits{IndexedTags: domain.NewIndexedTags([]string{"e", "emoji"})},
+			filter:     domain.Filter{Tags: map[string][]string{"t": {"nostr"}}},
+			wantReject: domain.ReasonInvalid,
+		},
+		{
+			name:       "prefix is rejected when prefix matching is disabled",
+			limits:     domain.QueryLimits{},
+			filter:     domain.Filter{IDs: []string{"abcdef01"}},
+			wantReject: domain.ReasonInvalid,
+		},
+		{
+			name:       "short prefix is rejected",
+			limits:     domain.QueryLimits{PrefixMatch: true, MinPrefixLength: 8},
+			filter:     domain.Filter{Authors: []string{"abcd"}},
+			wantReject: domain.ReasonInvalid,
+		},
+		{
+			name:      "long enough prefix is accepted",
+			limits:    domain.QueryLimits{PrefixMatch: true, MinPrefixLength: 8},
+			filter:    domain.Filter{Authors: []string{"abcdef01"}},
+			wantLimit: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var orig int
+			if tt.filter.Limit != nil {
+				orig = *tt.filter.Limit
+			}
+			got, err := tt.limits.Plan(tt.filter)
+
+			var rejectErr *domain.RejectError
+			if tt.wantReject != "" {
+				if !errors.As(err, &rejectErr) || rejectErr.Prefix != tt.wantReject {
+					t.Fatalf("Plan() error = %v, want %s", err, tt.wantReject)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Plan() failed: %v", err)
+			}
+			if (got.Limit == nil) != (tt.wantLimit == nil) || (got.Limit != nil && *got.Limit != *tt.wantLimit) {
+				t.Errorf("Plan() limit = %v, want %v", got.Limit, tt.wantLimit)
+			}
+			if tt.filter.Limit != nil && *tt.filter.Limit != orig {
+				t.Errorf("Plan() modified the original filter")
+			}
+		})
+	}
+}
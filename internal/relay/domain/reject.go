@@ -0,0 +1,35 @@
+package domain
+
+import "fmt"
+
+// NIP-01 の OK / CLOSED メッセージで使う machine-readable prefix
+const (
+	ReasonDuplicate    = "duplicate"
+	ReasonPoW          = "pow"
+	ReasonBlocked      = "blocked"
+	ReasonRateLimited  = "rate-limited"
+	ReasonInvalid      = "invalid"
+	ReasonRestricted   = "restricted"
+	ReasonAuthRequired = "auth-required"
+	ReasonError        = "error"
+	ReasonClosed       = "closed" // NIP-77: リレー側からの NEG セッション終了
+)
+
+// RejectError is returned when the relay refuses an event or a REQ on purpose.
+// Error() は "<prefix>: <message>" 形式で、そのままクライアントに返してよい
+type RejectError struct {
+	Prefix  string
+	Message string
+}
+
+func (e *RejectError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Prefix, e.Message)
+}
+
+// NewRejectError builds a RejectError with a formatted message.
+func NewRejectError(prefix, format string, args ...any) *RejectError {
+	return &RejectError{
+		Prefix:  prefix,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
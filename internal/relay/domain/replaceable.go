@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// KindDeletion is the NIP-09 event deletion request kind.
+const KindDeletion = 5
+
+// EventStore.Save が返すエラー
+var (
+	// ErrDuplicate は同じイベント、もしくはより新しい replaceable イベントが既に保存されている場合に返す
+	ErrDuplicate = errors.New("duplicate event")
+	// ErrDeleted は作者によって削除（NIP-09）済みのイベント、
+	// もしくは request to vanish（NIP-62）より前のイベントを再度保存しようとした場合に返す
+	ErrDeleted = errors.New("event was deleted by its author")
+)
+
+// TagValue returns the value of the first tag with the given name.
+func (e *Event) TagValue(name string) (string, bool) {
+	for _, tag := range e.Tags {
+		if len(tag) >= 2 && tag[0] == name {
+			return tag[1], true
+		}
+	}
+	return "", false
+}
+
+// DTag returns the "d" tag value used to identify addressable events (empty if absent).
+func (e *Event) DTag() string {
+	d, _ := e.TagValue("d")
+	return d
+}
+
+// Address returns "<kind>:<pubkey>:<d tag>" for addressable events.
+func (e *Event) Address() string {
+	return strconv.Itoa(e.Kind) + ":" + e.PubKey + ":" + e.DTag()
+}
+
+// Supersedes reports whether e replaces other (same replaceable slot).
+// NIP-01: created_at が新しい方を残し、同時刻の場合は id が辞書順で小さい方を残す
+func (e *Event) Supersedes(other Event) bool {
+	if e.CreatedAt != other.CreatedAt {
+		return e.CreatedAt > other.CreatedAt
+	}
+	return e.ID < other.ID
+}
+
+// EventAddress is a parsed "a" tag value ("<kind>:<pubkey>:<d tag>").
+type EventAddress struct {
+	Kind   int
+	PubKey string
+	DTag   string
+}
+
+// ParseEventAddress parses "<kind>:<pubkey>:<d tag>".
+func ParseEventAddress(s string) (EventAddress, bool) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) != 3 {
+		return EventAddress{}, false
+	}
+	kind, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return EventAddress{}, false
+	}
+	return EventAddress{Kind: kind, PubKey: parts[1], DTag: parts[2]}, true
+}
+
+// DeletionTargets returns the event ids ("e" tags) and addresses ("a" tags) a kind 5 event asks to delete.
+// 他人のイベントは削除できないため、a タグは作者本人の pubkey のものだけを返す
+func (e *Event) DeletionTargets() (ids []string, addrs []EventAddress) {
+	if e.Kind != KindDeletion {
+		return nil, nil
+	}
+	for _, tag := range e.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "e":
+			ids = append(ids, tag[1])
+		case "a":
+			if addr, ok := ParseEventAddress(tag[1]); ok && addr.PubKey == e.PubKey {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return ids, addrs
+}
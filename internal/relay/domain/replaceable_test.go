@@ -0,0 +1,92 @@
+package domain_test
+
+import (
+	"nostar/internal/relay/domain"
+	"reflect"
+	"testing"
+)
+
+func TestEvent_Supersedes(t *testing.T) {
+	tests := []struct {
+		name  string
+		evt   domain.Event
+		other domain.Event
+		want  bool
+	}{
+		{
+			name:  "newer created_at wins",
+			evt:   domain.Event{ID: "bb", CreatedAt: 200},
+			other: domain.Event{ID: "aa", CreatedAt: 100},
+			want:  true,
+		},
+		{
+			name:  "older created_at loses",
+			evt:   domain.Event{ID: "aa", CreatedAt: 100},
+			other: domain.Event{ID: "bb", CreatedAt: 200},
+			want:  false,
+		},
+		{
+			name:  "same created_at, lower id wins",
+			evt:   domain.Event{ID: "aa", CreatedAt: 100},
+			other: domain.Event{ID: "bb", CreatedAt: 100},
+			want:  true,
+		},
+		{
+			name:  "same created_at, higher id loses",
+			evt:   domain.Event{ID: "bb", CreatedAt: 100},
+			other: domain.Event{ID: "aa", CreatedAt: 100},
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.evt.Supersedes(tt.other); got != tt.want {
+				t.Errorf("Supersedes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvent_DTagAndAddress(t *testing.T) {
+	evt := domain.Event{Kind: 30023, PubKey: "pub1", Tags: [][]string{{"d"}, {"d", "article"}, {"t", "nostr"}}}
+	if got := evt.DTag(); got != "article" {
+		t.Errorf("DTag() = %q, want %q", got, "article")
+	}
+	if got := evt.Address(); got != "30023:pub1:article" {
+		t.Errorf("Address() = %q, want %q", got, "30023:pub1:article")
+	}
+
+	noD := domain.Event{Kind: 30023, PubKey: "pub1"}
+	if got := noD.Address(); got != "30023:pub1:" {
+		t.Errorf("Address() = %q, want %q", got, "30023:pub1:")
+	}
+}
+
+func TestEvent_DeletionTargets(t *testing.T) {
+	evt := domain.Event{
+		Kind:   domain.KindDeletion,
+		PubKey: "pub1",
+		Tags: [][]string{
+			{"e", "id1"},
+			{"e"}, // 不正なタグは無視
+			{"a", "30023:pub1:article"},
+			{"a", "30023:pub2:article"}, // 他人のアドレスは無視
+			{"a", "invalid"},
+			{"k", "1"},
+		},
+	}
+
+	ids, addrs := evt.DeletionTargets()
+	if !reflect.DeepEqual(ids, []string{"id1"}) {
+		t.Errorf("DeletionTargets() ids = %v", ids)
+	}
+	wantAddrs := []domain.EventAddress{{Kind: 30023, PubKey: "pub1", DTag: "article"}}
+	if !reflect.DeepEqual(addrs, wantAddrs) {
+		t.Errorf("DeletionTargets() addrs = %v, want %v", addrs, wantAddrs)
+	}
+
+	notDeletion := domain.Event{Kind: 1, Tags: [][]string{{"e", "id1"}}}
+	if ids, addrs := notDeletion.DeletionTargets(); ids != nil || addrs != nil {
+		t.Errorf("DeletionTargets() for kind 1 = %v, %v, want nil", ids, addrs)
+	}
+}
@@ -0,0 +1,82 @@
+package domain
+
+import "time"
+
+// KindReport is the NIP-56 report kind.
+const KindReport = 1984
+
+// Report is one target of a NIP-56 report event.
+// e タグがある場合はイベントへの報告、ない場合は p タグの pubkey への報告として扱う
+type Report struct {
+	ReportID  string     // 報告イベントの ID
+	Reporter  string     // 報告者の pubkey
+	Target    HideTarget // event / pubkey
+	Value     string     // 報告対象のイベント ID / pubkey
+	Type      string     // nudity / malware / profanity / illegal / spam / impersonation / other（不明な場合は空）
+	CreatedAt int64
+}
+
+// Reports extracts the report targets of a kind 1984 event.
+// 不正な ID / pubkey のタグは無視する
+func (e *Event) Reports() []Report {
+	if e.Kind != KindReport {
+		return nil
+	}
+
+	var pubkeyType string // e タグに type がない場合は p タグの type を使う
+	var eTags, pTags [][]string
+	for _, tag := range e.Tags {
+		if len(tag) < 2 || !isLowerHex(tag[1], 64) {
+			continue
+		}
+		switch tag[0] {
+		case "e":
+			eTags = append(eTags, tag)
+		case "p":
+			pTags = append(pTags, tag)
+			if pubkeyType == "" && len(tag) >= 3 {
+				pubkeyType = tag[2]
+			}
+		}
+	}
+
+	target, tags := HidePubKey, pTags
+	if len(eTags) > 0 {
+		target, tags = HideEvent, eTags
+	}
+
+	reports := make([]Report, 0, len(tags))
+	for _, tag := range tags {
+		typ := pubkeyType
+		if len(tag) >= 3 && tag[2] != "" {
+			typ = tag[2]
+		}
+		reports = append(reports, Report{
+			ReportID:  e.ID,
+			Reporter:  e.PubKey,
+			Target:    target,
+			Value:     tag[1],
+			Type:      typ,
+			CreatedAt: e.CreatedAt,
+		})
+	}
+	return reports
+}
+
+// ReportQueueItem is a reported event / pubkey in the moderation queue.
+type ReportQueueItem struct {
+	Target           HideTarget
+	Value            string
+	TrustedReporters int      // 信頼できる報告者の数（重複なし）
+	Reporters        int      // すべての報告者の数（重複なし）
+	Types            []string // 報告の種類
+	LastReportedAt   time.Time
+	Hidden           bool // 既に非表示にしているか
+}
+
+// ReportQueueOptions selects the moderation queue.
+type ReportQueueOptions struct {
+	TrustedReporters []string // 信頼できる報告者の pubkey（hex）
+	IncludeHidden    bool     // 既に非表示にした対象も含める
+	Limit            int
+}
@@ -0,0 +1,74 @@
+package domain_test
+
+import (
+	"reflect"
+	"testing"
+
+	"nostar/internal/relay/domain"
+)
+
+func TestEvent_Reports(t *testing.T) {
+	pk1 := "1111111111111111111111111111111111111111111111111111111111111111"
+	pk2 := "2222222222222222222222222222222222222222222222222222222222222222"
+	id1 := "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+
+	report := func(target domain.HideTarget, value, typ string) domain.Report {
+		return domain.Report{ReportID: "report", Reporter: "reporter", Target: target, Value: value, Type: typ, CreatedAt: 1000}
+	}
+
+	tests := []struct {
+		name string
+		kind int
+		tags [][]string
+		want []domain.Report
+	}{
+		{
+			name: "pubkey report",
+			kind: domain.KindReport,
+			tags: [][]string{{"p", pk1, "impersonation"}},
+			want: []domain.Report{report(domain.HidePubKey, pk1, "impersonation")},
+		},
+		{
+			name: "event report uses the type of the e tag",
+			kind: domain.KindReport,
+			tags: [][]string{{"e", id1, "illegal"}, {"p", pk1}},
+			want: []domain.Report{report(domain.HideEvent, id1, "illegal")},
+		},
+		{
+			name: "event report falls back to the type of the p tag",
+			kind: domain.KindReport,
+			tags: [][]string{{"e", id1}, {"p", pk1, "spam"}},
+			want: []domain.Report{report(domain.HideEvent, id1, "spam")},
+		},
+		{
+			name: "multiple pubkeys",
+			kind: domain.KindReport,
+			tags: [][]string{{"p", pk1, "spam"}, {"p", pk2, "spam"}},
+			want: []domain.Report{report(domain.HidePubKey, pk1, "spam"), report(domain.HidePubKey, pk2, "spam")},
+		},
+		{
+			name: "invalid ids are ignored",
+			kind: domain.KindReport,
+			tags: [][]string{{"e", "not-an-id", "spam"}, {"p"}, {"p", pk1, "other"}},
+			want: []domain.Report{report(domain.HidePubKey, pk1, "other")},
+		},
+		{
+			name: "not a report",
+			kind: 1,
+			tags: [][]string{{"p", pk1, "spam"}},
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evt := domain.Event{ID: "report", PubKey: "reporter", CreatedAt: 1000, Kind: tt.kind, Tags: tt.tags}
+			got := evt.Reports()
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Reports() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
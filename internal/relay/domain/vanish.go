@@ -0,0 +1,35 @@
+package domain
+
+// KindVanish is the NIP-62 request to vanish kind.
+const KindVanish = 62
+
+// AllRelays is the relay tag value of a request to vanish addressed to every relay.
+const AllRelays = "ALL_RELAYS"
+
+// VanishesFrom reports whether a kind 62 event asks relayURL to erase its author.
+// relay タグが ALL_RELAYS、またはホスト名が relayURL と一致する場合
+func (e *Event) VanishesFrom(relayURL string) bool {
+	if e.Kind != KindVanish {
+		return false
+	}
+	for _, tag := range e.Tags {
+		if len(tag) < 2 || tag[0] != "relay" {
+			continue
+		}
+		if tag[1] == AllRelays || (relayURL != "" && sameRelayHost(tag[1], relayURL)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Recipients returns the pubkeys of the "p" tags (gift wrap の宛先など).
+func (e *Event) Recipients() []string {
+	var pubkeys []string
+	for _, tag := range e.Tags {
+		if len(tag) >= 2 && tag[0] == "p" {
+			pubkeys = append(pubkeys, tag[1])
+		}
+	}
+	return pubkeys
+}
@@ -0,0 +1,68 @@
+package domain_test
+
+import (
+	"testing"
+
+	"nostar/internal/relay/domain"
+)
+
+func TestEvent_VanishesFrom(t *testing.T) {
+	const relayURL = "wss://relay.example.com"
+
+	tests := []struct {
+		name     string
+		kind     int
+		tags     [][]string
+		relayURL string
+		want     bool
+	}{
+		{
+			name:     "this relay",
+			kind:     domain.KindVanish,
+			tags:     [][]string{{"relay", "wss://other.example.com"}, {"relay", "wss://Relay.Example.com/"}},
+			relayURL: relayURL,
+			want:     true,
+		},
+		{
+			name:     "all relays",
+			kind:     domain.KindVanish,
+			tags:     [][]string{{"relay", domain.AllRelays}},
+			relayURL: relayURL,
+			want:     true,
+		},
+		{
+			name: "all relays without a relay URL",
+			kind: domain.KindVanish,
+			tags: [][]string{{"relay", domain.AllRelays}},
+			want: true,
+		},
+		{
+			name:     "another relay",
+			kind:     domain.KindVanish,
+			tags:     [][]string{{"relay", "wss://other.example.com"}},
+			relayURL: relayURL,
+			want:     false,
+		},
+		{
+			name:     "no relay tag",
+			kind:     domain.KindVanish,
+			relayURL: relayURL,
+			want:     false,
+		},
+		{
+			name:     "not a request to vanish",
+			kind:     1,
+			tags:     [][]string{{"relay", domain.AllRelays}},
+			relayURL: relayURL,
+			want:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evt := domain.Event{Kind: tt.kind, Tags: tt.tags}
+			if got := evt.VanishesFrom(tt.relayURL); got != tt.want {
+				t.Errorf("VanishesFrom() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
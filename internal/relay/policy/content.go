@@ -0,0 +1,30 @@
+package policy
+
+import (
+	"context"
+	"regexp"
+
+	"nostar/internal/relay/domain"
+)
+
+// ContentRegex rejects events whose content matches the pattern.
+type ContentRegex struct {
+	pattern *regexp.Regexp
+}
+
+func NewContentRegex(pattern string) (*ContentRegex, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &ContentRegex{pattern: re}, nil
+}
+
+func (p *ContentRegex) Name() string { return "content_regex" }
+
+func (p *ContentRegex) Check(ctx context.Context, in domain.PolicyInput) domain.PolicyDecision {
+	if p.pattern.MatchString(in.Event.Content) {
+		return domain.Reject(domain.ReasonBlocked, "content is not allowed")
+	}
+	return domain.Accept()
+}
@@ -0,0 +1,50 @@
+package policy
+
+import (
+	"context"
+	"time"
+
+	"nostar/internal/relay/domain"
+)
+
+// CreatedAtWindow rejects events whose created_at is too far from the relay clock.
+// 0 を指定した方向はチェックしない。sync で取り込むイベントは過去方向のチェックをしない
+type CreatedAtWindow struct {
+	maxFuture         time.Duration
+	maxPast           time.Duration
+	exemptReplaceable bool // replaceable / addressable な kind は過去方向のチェックをしない
+	now               func() time.Time
+}
+
+func NewCreatedAtWindow(maxFuture, maxPast time.Duration, exemptReplaceable bool) *CreatedAtWindow {
+	return &CreatedAtWindow{
+		maxFuture:         maxFuture,
+		maxPast:           maxPast,
+		exemptReplaceable: exemptReplaceable,
+		now:               time.Now,
+	}
+}
+
+func (p *CreatedAtWindow) Name() string { return "created_at" }
+
+func (p *CreatedAtWindow) Check(ctx context.Context, in domain.PolicyInput) domain.PolicyDecision {
+	now := p.now().Unix()
+	evt := in.Event
+
+	// 未来方向は replaceable でも許可しない（未来日時のプロフィールは上書きできなくなるため）
+	if p.maxFuture > 0 && evt.CreatedAt > now+int64(p.maxFuture/time.Second) {
+		return domain.Reject(domain.ReasonInvalid, "created_at is too far in the future (max %s ahead)", p.maxFuture)
+	}
+
+	// nostar sync は管理者による過去のイベントの取り込みなので、過去方向はチェックしない
+	if in.SourceType == domain.SourceSync {
+		return domain.Accept()
+	}
+	if p.exemptReplaceable && (domain.IsReplaceableKind(evt.Kind) || domain.IsAddressableKind(evt.Kind)) {
+		return domain.Accept()
+	}
+	if p.maxPast > 0 && evt.CreatedAt < now-int64(p.maxPast/time.Second) {
+		return domain.Reject(domain.ReasonInvalid, "created_at is too far in the past (max %s ago)", p.maxPast)
+	}
+	return domain.Accept()
+}
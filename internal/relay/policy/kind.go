@@ -0,0 +1,35 @@
+package policy
+
+import (
+	"context"
+	"slices"
+
+	"nostar/internal/relay/domain"
+)
+
+// KindFilter accepts or rejects events by kind.
+// allow が空でない場合は allow に含まれる kind のみ受け付ける。deny は allow より優先する
+type KindFilter struct {
+	allow []int
+	deny  []int
+}
+
+func NewKindFilter(allow, deny []int) *KindFilter {
+	return &KindFilter{
+		allow: allow,
+		deny:  deny,
+	}
+}
+
+func (p *KindFilter) Name() string { return "kind" }
+
+func (p *KindFilter) Check(ctx context.Context, in domain.PolicyInput) domain.PolicyDecision {
+	kind := in.Event.Kind
+	if slices.Contains(p.deny, kind) {
+		return domain.Reject(domain.ReasonBlocked, "kind %d is not accepted by this relay", kind)
+	}
+	if len(p.allow) > 0 && !slices.Contains(p.allow, kind) {
+		return domain.Reject(domain.ReasonBlocked, "kind %d is not accepted by this relay", kind)
+	}
+	return domain.Accept()
+}
@@ -0,0 +1,236 @@
+package policy_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"nostar/internal/relay/domain"
+	"nostar/internal/relay/policy"
+)
+
+// assertDecision はポリシーの判定結果が期待どおりかを確認する
+func assertDecision(t *testing.T, d domain.PolicyDecision, wantReject bool, wantPrefix string) {
+	t.Helper()
+	if (d.Action != domain.PolicyAccept) != wantReject {
+		t.Fatalf("Check() = %v (%s), wantReject %v", d.Action, d.Reason(), wantReject)
+	}
+	if wantReject && d.Prefix != wantPrefix {
+		t.Errorf("Check() prefix = %q, want %q", d.Prefix, wantPrefix)
+	}
+}
+
+func TestPolicies_Check(t *testing.T) {
+	now := time.Now().Unix()
+	mustContentRegex := func(pattern string) domain.EventPolicy {
+		p, err := policy.NewContentRegex(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	tests := []struct {
+		name       string
+		policy     domain.EventPolicy
+		event      domain.Event
+		source     string // PolicyInput.SourceType
+		wantReject bool
+		wantPrefix string
+	}{
+		{
+			name:   "kind allowed",
+			policy: policy.NewKindFilter([]int{0, 1}, nil),
+			event:  domain.Event{Kind: 1},
+		},
+		{
+			name:       "kind not in allow list",
+			policy:     policy.NewKindFilter([]int{0, 1}, nil),
+			event:      domain.Event{Kind: 4},
+			wantReject: true,
+			wantPrefix: domain.ReasonBlocked,
+		},
+		{
+			name:       "kind denied",
+			policy:     policy.NewKindFilter(nil, []int{4}),
+			event:      domain.Event{Kind: 4},
+			wantReject: true,
+			wantPrefix: domain.ReasonBlocked,
+		},
+		{
+			name:   "kind not denied",
+			policy: policy.NewKindFilter(nil, []int{4}),
+			event:  domain.Event{Kind: 1},
+		},
+		{
+			name:   "content within limit (counted in characters)",
+			policy: policy.NewMaxContentLength(3),
+			event:  domain.Event{Content: "ぽわ〜"},
+		},
+		{
+			name:       "content too long",
+			policy:     policy.NewMaxContentLength(3),
+			event:      domain.Event{Content: "powa"},
+			wantReject: true,
+			wantPrefix: domain.ReasonInvalid,
+		},
+		{
+			name:   "tags within limit",
+			policy: policy.NewMaxTags(1),
+			event:  domain.Event{Tags: [][]string{{"t", "nostr"}}},
+		},
+		{
+			name:       "too many tags",
+			policy:     policy.NewMaxTags(1),
+			event:      domain.Event{Tags: [][]string{{"t", "nostr"}, {"t", "bitcoin"}}},
+			wantReject: true,
+			wantPrefix: domain.ReasonInvalid,
+		},
+		{
+			name:   "created_at within window",
+			policy: policy.NewCreatedAtWindow(15*time.Minute, 24*time.Hour, false),
+			event:  domain.Event{CreatedAt: now},
+		},
+		{
+			name:       "created_at too far in the future",
+			policy:     policy.NewCreatedAtWindow(15*time.Minute, 0, false),
+			event:      domain.Event{CreatedAt: now + 3600},
+			wantReject: true,
+			wantPrefix: domain.ReasonInvalid,
+		},
+		{
+			name:       "created_at too far in the past",
+			policy:     policy.NewCreatedAtWindow(0, 24*time.Hour, false),
+			event:      domain.Event{CreatedAt: now - 2*24*3600},
+			wantReject: true,
+			wantPrefix: domain.ReasonInvalid,
+		},
+		{
+			name:   "replaceable kind is exempt from past limit",
+			policy: policy.NewCreatedAtWindow(15*time.Minute, 24*time.Hour, true),
+			event:  domain.Event{Kind: 0, CreatedAt: now - 30*24*3600},
+		},
+		{
+			name:   "addressable kind is exempt from past limit",
+			policy: policy.NewCreatedAtWindow(15*time.Minute, 24*time.Hour, true),
+			event:  domain.Event{Kind: 30023, CreatedAt: now - 30*24*3600},
+		},
+		{
+			name:       "replaceable kind is not exempt from future limit",
+			policy:     policy.NewCreatedAtWindow(15*time.Minute, 24*time.Hour, true),
+			event:      domain.Event{Kind: 0, CreatedAt: now + 3600},
+			wantReject: true,
+			wantPrefix: domain.ReasonInvalid,
+		},
+		{
+			name:       "replaceable kind without exemption",
+			policy:     policy.NewCreatedAtWindow(15*time.Minute, 24*time.Hour, false),
+			event:      domain.Event{Kind: 0, CreatedAt: now - 30*24*3600},
+			wantReject: true,
+			wantPrefix: domain.ReasonInvalid,
+		},
+		{
+			name:   "created_at past check disabled",
+			policy: policy.NewCreatedAtWindow(15*time.Minute, 0, false),
+			event:  domain.Event{CreatedAt: 1},
+		},
+		{
+			name:   "synced event is exempt from past limit",
+			policy: policy.NewCreatedAtWindow(15*time.Minute, 24*time.Hour, false),
+			event:  domain.Event{CreatedAt: now - 30*24*3600},
+			source: domain.SourceSync,
+		},
+		{
+			name:       "synced event is not exempt from future limit",
+			policy:     policy.NewCreatedAtWindow(15*time.Minute, 24*time.Hour, false),
+			event:      domain.Event{CreatedAt: now + 3600},
+			source:     domain.SourceSync,
+			wantReject: true,
+			wantPrefix: domain.ReasonInvalid,
+		},
+		{
+			name:       "content matches regex",
+			policy:     mustContentRegex(`(?i)buy\s+now`),
+			event:      domain.Event{Content: "BUY NOW!!"},
+			wantReject: true,
+			wantPrefix: domain.ReasonBlocked,
+		},
+		{
+			name:   "content does not match regex",
+			policy: mustContentRegex(`(?i)buy\s+now`),
+			event:  domain.Event{Content: "gm"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.policy.Check(context.Background(), domain.PolicyInput{Event: tt.event, SourceType: tt.source})
+			assertDecision(t, d, tt.wantReject, tt.wantPrefix)
+			if tt.wantReject && d.Action != domain.PolicyReject {
+				t.Errorf("Check() action = %v, want reject", d.Action)
+			}
+		})
+	}
+}
+
+func TestNewContentRegex_InvalidPattern(t *testing.T) {
+	if _, err := policy.NewContentRegex(`(`); err == nil {
+		t.Fatal("NewContentRegex() succeeded unexpectedly")
+	}
+}
+
+func TestShadow_Check(t *testing.T) {
+	p := policy.NewShadow(policy.NewKindFilter(nil, []int{4}))
+
+	d := p.Check(context.Background(), domain.PolicyInput{Event: domain.Event{Kind: 4}})
+	if d.Action != domain.PolicyShadowReject {
+		t.Errorf("Check() action = %v, want shadowReject", d.Action)
+	}
+	if !strings.HasPrefix(d.Reason(), "blocked: ") {
+		t.Errorf("Check() reason = %q, want blocked: prefix", d.Reason())
+	}
+
+	d = p.Check(context.Background(), domain.PolicyInput{Event: domain.Event{Kind: 1}})
+	if d.Action != domain.PolicyAccept {
+		t.Errorf("Check() action = %v, want accept", d.Action)
+	}
+	if p.Name() != "kind" {
+		t.Errorf("Name() = %q, want %q", p.Name(), "kind")
+	}
+}
+
+func TestPolicyChain_Evaluate(t *testing.T) {
+	chain := domain.PolicyChain{
+		policy.NewMaxTags(10),
+		policy.NewShadow(policy.NewKindFilter(nil, []int{4})),
+		policy.NewKindFilter([]int{1}, nil),
+	}
+
+	tests := []struct {
+		name       string
+		event      domain.Event
+		wantAction domain.PolicyAction
+		wantPolicy string
+	}{
+		{name: "all policies accept", event: domain.Event{Kind: 1}, wantAction: domain.PolicyAccept},
+		{name: "first non-accept wins (shadow)", event: domain.Event{Kind: 4}, wantAction: domain.PolicyShadowReject, wantPolicy: "kind"},
+		{name: "later policy rejects", event: domain.Event{Kind: 7}, wantAction: domain.PolicyReject, wantPolicy: "kind"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, p := chain.Evaluate(context.Background(), domain.PolicyInput{Event: tt.event})
+			if d.Action != tt.wantAction {
+				t.Fatalf("Evaluate() action = %v, want %v", d.Action, tt.wantAction)
+			}
+			if tt.wantAction == domain.PolicyAccept {
+				if p != nil {
+					t.Errorf("Evaluate() policy = %v, want nil", p)
+				}
+				return
+			}
+			if p.Name() != tt.wantPolicy {
+				t.Errorf("Evaluate() policy = %q, want %q", p.Name(), tt.wantPolicy)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package policy
+
+import (
+	"context"
+	"sync/atomic"
+
+	"nostar/internal/relay/domain"
+)
+
+// PubKeyList decides who may publish to this relay based on pubkey allow/deny lists.
+// リストは SetAllowlist / SetDenylist で丸ごと差し替える（ファイルのホットリロード用）
+type PubKeyList struct {
+	allowTagged bool // allowlist のメンバーを p タグで指しているイベントは、外部の pubkey でも受け付ける
+
+	allowlist atomic.Pointer[domain.PubKeySet] // nil の場合は allowlist 無効（全員許可）
+	denylist  atomic.Pointer[domain.PubKeySet] // nil の場合は denylist 無効
+}
+
+func NewPubKeyList(allowTagged bool) *PubKeyList {
+	return &PubKeyList{
+		allowTagged: allowTagged,
+	}
+}
+
+func (p *PubKeyList) Name() string { return "pubkey_list" }
+
+func (p *PubKeyList) SetAllowlist(set domain.PubKeySet) {
+	p.allowlist.Store(&set)
+}
+
+func (p *PubKeyList) SetDenylist(set domain.PubKeySet) {
+	p.denylist.Store(&set)
+}
+
+// Check rejects with blocked: if the author is denied or not allowed.
+// denylist は allowlist より優先する
+func (p *PubKeyList) Check(ctx context.Context, in domain.PolicyInput) domain.PolicyDecision {
+	evt := in.Event
+	if deny := p.denylist.Load(); deny != nil && deny.Contains(evt.PubKey) {
+		return domain.Reject(domain.ReasonBlocked, "pubkey is banned")
+	}
+
+	allow := p.allowlist.Load()
+	if allow == nil || allow.Contains(evt.PubKey) {
+		return domain.Accept()
+	}
+
+	if p.allowTagged {
+		for _, tag := range evt.Tags {
+			if len(tag) >= 2 && tag[0] == "p" && allow.Contains(tag[1]) {
+				return domain.Accept()
+			}
+		}
+	}
+	return domain.Reject(domain.ReasonBlocked, "pubkey is not allowed to publish to this relay")
+}
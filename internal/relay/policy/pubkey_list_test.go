@@ -0,0 +1,97 @@
+package policy_test
+
+import (
+	"context"
+	"testing"
+
+	"nostar/internal/relay/domain"
+	"nostar/internal/relay/policy"
+)
+
+const (
+	memberPubKey   = "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+	outsiderPubKey = "82341f882b6eabcd2ba7f1ef90aad961cf074af15b9ef44a09f9d2a8fbfbe6a2"
+)
+
+func TestPubKeyList_Check(t *testing.T) {
+	tests := []struct {
+		name        string
+		allowTagged bool
+		allowlist   []string // nil の場合は設定しない
+		denylist    []string // nil の場合は設定しない
+		event       domain.Event
+		wantBlocked bool
+	}{
+		{
+			name:        "no lists configured",
+			event:       domain.Event{PubKey: outsiderPubKey},
+			wantBlocked: false,
+		},
+		{
+			name:        "allowlisted author",
+			allowlist:   []string{memberPubKey},
+			event:       domain.Event{PubKey: memberPubKey},
+			wantBlocked: false,
+		},
+		{
+			name:        "author not in allowlist",
+			allowlist:   []string{memberPubKey},
+			event:       domain.Event{PubKey: outsiderPubKey},
+			wantBlocked: true,
+		},
+		{
+			name:        "empty allowlist blocks everyone",
+			allowlist:   []string{},
+			event:       domain.Event{PubKey: memberPubKey},
+			wantBlocked: true,
+		},
+		{
+			name:        "outsider tagging a member (allow_tagged)",
+			allowTagged: true,
+			allowlist:   []string{memberPubKey},
+			event:       domain.Event{PubKey: outsiderPubKey, Tags: [][]string{{"p", memberPubKey}}},
+			wantBlocked: false,
+		},
+		{
+			name:        "outsider tagging a member (allow_tagged disabled)",
+			allowTagged: false,
+			allowlist:   []string{memberPubKey},
+			event:       domain.Event{PubKey: outsiderPubKey, Tags: [][]string{{"p", memberPubKey}}},
+			wantBlocked: true,
+		},
+		{
+			name:        "malformed p tag is ignored",
+			allowTagged: true,
+			allowlist:   []string{memberPubKey},
+			event:       domain.Event{PubKey: outsiderPubKey, Tags: [][]string{{"p"}}},
+			wantBlocked: true,
+		},
+		{
+			name:        "denylisted author",
+			denylist:    []string{outsiderPubKey},
+			event:       domain.Event{PubKey: outsiderPubKey},
+			wantBlocked: true,
+		},
+		{
+			name:        "denylist wins over allowlist",
+			allowlist:   []string{memberPubKey},
+			denylist:    []string{memberPubKey},
+			event:       domain.Event{PubKey: memberPubKey},
+			wantBlocked: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := policy.NewPubKeyList(tt.allowTagged)
+			if tt.allowlist != nil {
+				p.SetAllowlist(domain.NewPubKeySet(tt.allowlist))
+			}
+			if tt.denylist != nil {
+				p.SetDenylist(domain.NewPubKeySet(tt.denylist))
+			}
+
+			d := p.Check(context.Background(), domain.PolicyInput{Event: tt.event})
+			assertDecision(t, d, tt.wantBlocked, domain.ReasonBlocked)
+		})
+	}
+}
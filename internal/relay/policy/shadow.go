@@ -0,0 +1,34 @@
+package policy
+
+import (
+	"context"
+	"io"
+
+	"nostar/internal/relay/domain"
+)
+
+// Shadow wraps a policy so that its rejections become shadow-rejections.
+// スパム対策など、拒否したことを送信者に知らせたくない場合に使う
+type Shadow struct {
+	domain.EventPolicy
+}
+
+func NewShadow(p domain.EventPolicy) *Shadow {
+	return &Shadow{EventPolicy: p}
+}
+
+func (p *Shadow) Check(ctx context.Context, in domain.PolicyInput) domain.PolicyDecision {
+	d := p.EventPolicy.Check(ctx, in)
+	if d.Action == domain.PolicyReject {
+		d.Action = domain.PolicyShadowReject
+	}
+	return d
+}
+
+// Close closes the wrapped policy if it holds resources (プラグインのプロセスなど).
+func (p *Shadow) Close() error {
+	if c, ok := p.EventPolicy.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
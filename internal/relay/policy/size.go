@@ -0,0 +1,45 @@
+package policy
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"nostar/internal/relay/domain"
+)
+
+// MaxContentLength rejects events whose content is longer than max characters.
+type MaxContentLength struct {
+	max int
+}
+
+func NewMaxContentLength(max int) *MaxContentLength {
+	return &MaxContentLength{max: max}
+}
+
+func (p *MaxContentLength) Name() string { return "max_content_length" }
+
+func (p *MaxContentLength) Check(ctx context.Context, in domain.PolicyInput) domain.PolicyDecision {
+	// バイト数ではなく文字数で数える（日本語が不利にならないように）
+	if n := utf8.RuneCountInString(in.Event.Content); n > p.max {
+		return domain.Reject(domain.ReasonInvalid, "content is too long (%d > %d)", n, p.max)
+	}
+	return domain.Accept()
+}
+
+// MaxTags rejects events with more than max tags.
+type MaxTags struct {
+	max int
+}
+
+func NewMaxTags(max int) *MaxTags {
+	return &MaxTags{max: max}
+}
+
+func (p *MaxTags) Name() string { return "max_tags" }
+
+func (p *MaxTags) Check(ctx context.Context, in domain.PolicyInput) domain.PolicyDecision {
+	if n := len(in.Event.Tags); n > p.max {
+		return domain.Reject(domain.ReasonInvalid, "too many tags (%d > %d)", n, p.max)
+	}
+	return domain.Accept()
+}
@@ -8,6 +8,102 @@ import (
 
 // EventStore persists and queries Nostr events.
 type EventStore interface {
+	// Save は非表示の pubkey のイベントを非表示の状態で保存し、domain.ErrHidden を返す
 	Save(ctx context.Context, evt domain.Event) error
+	// Query はモデレーションで非表示にしたイベントを返さない（db.WithHiddenEvents の場合を除く）
 	Query(ctx context.Context, sub domain.Subscription) ([]domain.Event, error)
+	// QueryStream は Query と同じイベントを、読み込みながら1件ずつ fn に渡す
+	// fn がエラーを返すか ctx が終了すると、読み込みを中断してそのエラーを返す
+	QueryStream(ctx context.Context, sub domain.Subscription, fn func(domain.Event) error) error
+}
+
+// BatchEventStore is an EventStore that can save many events in one transaction.
+// import などで保存するイベントがまとめて手元にある場合に使う
+type BatchEventStore interface {
+	EventStore
+	// SaveBatch は evts を順番に保存し、イベントごとに Save と同じ結果を返す
+	SaveBatch(ctx context.Context, evts []domain.Event) []error
+}
+
+// EventRefStore is an EventStore that can read matching events without loading their content.
+// NIP-77 の NEG-OPEN など、大量のイベントの (created_at, id) だけが必要な場合に使う
+type EventRefStore interface {
+	EventStore
+	// QueryRefs は QueryStream と同じイベントを、ID・CreatedAt・PubKey・Kind・Tags だけを設定して fn に渡す
+	// （配信先の確認に必要な項目。content と sig は読まない）
+	QueryRefs(ctx context.Context, sub domain.Subscription, fn func(domain.Event) error) error
+}
+
+// IdentityStore persists NIP-05 identities (name -> pubkey) served by this relay.
+type IdentityStore interface {
+	SaveIdentity(ctx context.Context, identity domain.Identity) error
+	DeleteIdentity(ctx context.Context, name string) error
+	FindIdentity(ctx context.Context, name string) (domain.Identity, error) // 存在しない場合は domain.ErrNotFound
+	ListIdentities(ctx context.Context) ([]domain.Identity, error)
+}
+
+// Upstream is a client connection to another relay (used by sync).
+type Upstream interface {
+	// Query sends a REQ with the filter and returns the stored events received until EOSE.
+	Query(ctx context.Context, filter domain.Filter) ([]domain.Event, error)
+	Close() error
+}
+
+// UpstreamDialer connects to a relay URL.
+type UpstreamDialer func(ctx context.Context, url string) (Upstream, error)
+
+// SyncCheckpointStore remembers how far each upstream has been synced.
+type SyncCheckpointStore interface {
+	GetCheckpoint(ctx context.Context, relayURL, filterKey string) (int64, error) // 未同期の場合は domain.ErrNotFound
+	SaveCheckpoint(ctx context.Context, relayURL, filterKey string, createdAt int64) error
+}
+
+// ManagementStore persists state changed through the NIP-86 management API.
+type ManagementStore interface {
+	// AddBan は既存のエントリを理由ごと上書きする
+	// domain.BanEvent の場合、保存済みのイベントも削除する
+	AddBan(ctx context.Context, ban domain.Ban) error
+	RemoveBan(ctx context.Context, banType domain.BanType, value string) error
+	ListBans(ctx context.Context) ([]domain.Ban, error)
+
+	SaveRelaySetting(ctx context.Context, key, value string) error
+	RelaySettings(ctx context.Context) (map[string]string, error)
+}
+
+// ModerationStore hides events (events.hidden) and keeps the audit log.
+// 非表示の設定と監査ログの記録は同じトランザクションで行う
+type ModerationStore interface {
+	// Hide は既存の設定を上書きし、新たに非表示にしたイベント数を返す
+	Hide(ctx context.Context, hide domain.Hide) (int64, error)
+	// Unhide は再表示したイベント数を返す。非表示にしていない場合は domain.ErrNotFound
+	Unhide(ctx context.Context, target domain.HideTarget, value, actor, reason string) (int64, error)
+	ListHides(ctx context.Context) ([]domain.Hide, error)
+	ModerationLog(ctx context.Context, limit int) ([]domain.ModerationLogEntry, error) // 新しい順
+	// Moderated は対象を非表示・再表示したことがあるか（監査ログに記録があるか）を返す
+	Moderated(ctx context.Context, target domain.HideTarget, value string) (bool, error)
+}
+
+// ReportStore reads the index of NIP-56 reports (kind 1984).
+// 索引は EventStore.Save が kind 1984 を保存するときに作成し、報告イベントの削除とともに消える
+type ReportStore interface {
+	// ReportQueue は報告された対象を、信頼できる報告者の数・報告者の数が多い順に返す
+	ReportQueue(ctx context.Context, opts domain.ReportQueueOptions) ([]domain.ReportQueueItem, error)
+	// CountReporters は reporters のうち対象を報告した pubkey の数を返す
+	CountReporters(ctx context.Context, target domain.HideTarget, value string, reporters []string) (int, error)
+}
+
+// EventSigner signs events with the relay's own key (NIP-29 のグループの状態など).
+type EventSigner interface {
+	PubKey() string
+	// Sign は evt に pubkey・id・sig を設定したイベントを返す
+	Sign(evt domain.Event) (domain.Event, error)
+}
+
+// GroupStore deletes events of NIP-29 groups.
+// グループのモデレーションイベントや状態（kind 39000-39003）は EventStore に通常のイベントとして保存する
+type GroupStore interface {
+	// DeleteGroupEvents は h タグが groupID のイベントのうち、ids のものを削除して削除数を返す
+	DeleteGroupEvents(ctx context.Context, groupID string, ids []string) (int64, error)
+	// DeleteGroup は h タグが groupID のイベントと、d タグが groupID のグループの状態をすべて削除する
+	DeleteGroup(ctx context.Context, groupID string) (int64, error)
 }
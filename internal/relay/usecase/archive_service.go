@@ -0,0 +1,243 @@
+package usecase
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+
+	"nostar/internal/relay"
+	"nostar/internal/relay/domain"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// exportPageSize は export 時に1回の Query で取得する件数
+	exportPageSize = 500
+	// defaultImportBatchSize は import 時にまとめて署名検証する件数
+	defaultImportBatchSize = 1000
+	// maxImportLineSize は JSONL の1行の上限
+	maxImportLineSize = 16 * 1024 * 1024
+)
+
+// ArchiveService imports and exports events as NIP-01 JSONL (1行1イベント).
+// 管理者向けの操作なので、EventPolicy は適用しない
+type ArchiveService struct {
+	store relay.EventStore
+}
+
+func NewArchiveService(store relay.EventStore) *ArchiveService {
+	return &ArchiveService{
+		store: store,
+	}
+}
+
+// Export writes events matching the filter to w, newest first.
+// until をずらしながらページングするので、全件をメモリに載せない
+// filter.Limit を指定した場合は、その件数で打ち切る
+func (s *ArchiveService) Export(ctx context.Context, filter domain.Filter, w io.Writer) (int, error) {
+	enc := json.NewEncoder(w)
+	written := 0
+	pageSize := exportPageSize
+	until := filter.Until
+	seen := map[string]struct{}{} // ページ境界（until と同時刻）で既に出力したイベント
+
+	for {
+		f := filter
+		f.Until = until
+		f.Limit = &pageSize
+
+		events, err := s.store.Query(ctx, domain.Subscription{Filters: []domain.Filter{f}})
+		if err != nil {
+			return written, err
+		}
+
+		emitted := 0
+		for _, evt := range events {
+			if _, ok := seen[evt.ID]; ok {
+				continue
+			}
+			if err := enc.Encode(evt); err != nil {
+				return written, err
+			}
+			written++
+			emitted++
+			if filter.Limit != nil && written >= *filter.Limit {
+				return written, nil
+			}
+		}
+
+		if len(events) < pageSize {
+			return written, nil
+		}
+
+		oldest := events[len(events)-1].CreatedAt
+		if emitted == 0 {
+			// 1ページ全てが同時刻のイベントだった場合は、ページを広げて取り直す
+			pageSize *= 2
+			continue
+		}
+
+		seen = map[string]struct{}{}
+		for _, evt := range events {
+			if evt.CreatedAt == oldest {
+				seen[evt.ID] = struct{}{}
+			}
+		}
+		until = &oldest
+	}
+}
+
+// ImportOptions controls Import.
+type ImportOptions struct {
+	SkipVerify bool              // 信頼できるダンプの場合は署名検証を省略する
+	BatchSize  int               // この件数ごとに署名検証・保存・進捗通知を行う
+	RelayURL   string            // NIP-62 の relay タグと比較する URL（空の場合は ALL_RELAYS 宛てだけを取り込む）
+	Progress   func(ImportStats) // バッチごとに呼ばれる（nil 可）
+}
+
+// ImportStats is the running result of Import.
+type ImportStats struct {
+	Lines      int // 読み込んだ行数（空行を除く）
+	Imported   int // 保存したイベント数
+	Duplicates int // 既に存在した / より新しい replaceable があったイベント数
+	Deleted    int // 作者によって削除済みだったイベント数
+	Invalid    int // パース・検証に失敗した（他のリレー宛ての request to vanish を含む）イベント数
+}
+
+// Import reads JSONL events from r and stores them.
+// 保存は EventStore.Save を通すので、replaceable / 削除 (NIP-09) のルールが適用される
+// 1件ごとのエラーは Invalid として数えて続行し、ストアのエラーの場合のみ中断する
+func (s *ArchiveService) Import(ctx context.Context, r io.Reader, opts ImportOptions) (ImportStats, error) {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultImportBatchSize
+	}
+
+	var stats ImportStats
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineSize)
+
+	batch := make([]domain.Event, 0, batchSize)
+	flush := func() error {
+		if err := s.importBatch(ctx, batch, opts, &stats); err != nil {
+			return err
+		}
+		batch = batch[:0]
+		if opts.Progress != nil {
+			opts.Progress(stats)
+		}
+		return nil
+	}
+
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		stats.Lines++
+
+		var evt domain.Event
+		if err := json.Unmarshal(line, &evt); err != nil {
+			zap.S().Warnw("skip invalid JSON line", "line", lineNo, "error", err)
+			stats.Invalid++
+			continue
+		}
+		batch = append(batch, evt)
+
+		if len(batch) >= batchSize {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return stats, fmt.Errorf("failed to read line %d: %w", lineNo+1, err)
+	}
+
+	if err := flush(); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// importBatch は署名検証を並列に行ってから、元の順番で保存する
+// 順番を保つのは、同じファイル内の kind 5 や replaceable の結果を安定させるため
+func (s *ArchiveService) importBatch(ctx context.Context, batch []domain.Event, opts ImportOptions, stats *ImportStats) error {
+	valid := make([]bool, len(batch))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, runtime.NumCPU())
+	for i := range batch {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			evt := &batch[i]
+			if err := evt.Validate(); err != nil {
+				zap.S().Warnw("skip invalid event", "event_id", evt.ID, "error", err)
+				return
+			}
+			if evt.Kind == domain.KindVanish && !evt.VanishesFrom(opts.RelayURL) {
+				// NIP-62: 他のリレー宛ての request to vanish を保存すると、作者のイベントを削除してしまう
+				zap.S().Warnw("skip request to vanish addressed to another relay", "event_id", evt.ID)
+				return
+			}
+			if !opts.SkipVerify {
+				if ok, err := evt.CheckSignature(); !ok {
+					zap.S().Warnw("skip event with invalid signature", "event_id", evt.ID, "error", err)
+					return
+				}
+			}
+			valid[i] = true
+		}(i)
+	}
+	wg.Wait()
+
+	var events []domain.Event
+	for i, evt := range batch {
+		if !valid[i] {
+			stats.Invalid++
+			continue
+		}
+		events = append(events, evt)
+	}
+
+	errs := s.save(ctx, events)
+	for i, evt := range events {
+		err := errs[i]
+		switch {
+		case err == nil, errors.Is(err, domain.ErrHidden):
+			stats.Imported++ // 非表示の pubkey のイベントも、非表示のまま取り込む
+		case errors.Is(err, domain.ErrDuplicate):
+			stats.Duplicates++
+		case errors.Is(err, domain.ErrDeleted):
+			stats.Deleted++
+		default:
+			return fmt.Errorf("failed to save event %s: %w", evt.ID, err)
+		}
+	}
+	return nil
+}
+
+// save は events を元の順番で保存する（BatchEventStore の場合は1つのトランザクションで保存する）
+func (s *ArchiveService) save(ctx context.Context, events []domain.Event) []error {
+	if store, ok := s.store.(relay.BatchEventStore); ok && len(events) > 0 {
+		return store.SaveBatch(ctx, events)
+	}
+
+	errs := make([]error, len(events))
+	for i, evt := range events {
+		errs[i] = s.store.Save(ctx, evt)
+	}
+	return errs
+}
@@ -0,0 +1,309 @@
+package usecase_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"nostar/internal/relay/domain"
+	"nostar/internal/relay/usecase"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// sliceEventStore is an in-memory EventStore that orders and limits like the Postgres store.
+type sliceEventStore struct {
+	events  []domain.Event
+	saveErr map[string]error // event ID -> Save が返すエラー
+	queries int
+}
+
+func (m *sliceEventStore) Save(ctx context.Context, evt domain.Event) error {
+	if err, ok := m.saveErr[evt.ID]; ok {
+		return err
+	}
+	for _, e := range m.events {
+		if e.ID == evt.ID {
+			return domain.ErrDuplicate
+		}
+	}
+	m.events = append(m.events, evt)
+	return nil
+}
+
+func (m *sliceEventStore) Query(ctx context.Context, sub domain.Subscription) ([]domain.Event, error) {
+	m.queries++
+	var results []domain.Event
+	for _, f := range sub.Filters {
+		var matched []domain.Event
+		for _, evt := range m.events {
+			if f.Matches(evt) {
+				matched = append(matched, evt)
+			}
+		}
+		sort.Slice(matched, func(i, j int) bool {
+			if matched[i].CreatedAt != matched[j].CreatedAt {
+				return matched[i].CreatedAt > matched[j].CreatedAt
+			}
+			return matched[i].ID < matched[j].ID
+		})
+		if f.Limit != nil && len(matched) > *f.Limit {
+			matched = matched[:*f.Limit]
+		}
+		results = append(results, matched...)
+	}
+	return domain.DedupeByID(results), nil
+}
+
+func (m *sliceEventStore) QueryStream(ctx context.Context, sub domain.Subscription, fn func(domain.Event) error) error {
+	events, err := m.Query(ctx, sub)
+	if err != nil {
+		return err
+	}
+	for _, evt := range events {
+		if err := fn(evt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// batchEventStore is a sliceEventStore that also implements relay.BatchEventStore.
+type batchEventStore struct {
+	*sliceEventStore
+	batches int
+}
+
+func (m *batchEventStore) SaveBatch(ctx context.Context, evts []domain.Event) []error {
+	m.batches++
+	errs := make([]error, len(evts))
+	for i, evt := range evts {
+		errs[i] = m.Save(ctx, evt)
+	}
+	return errs
+}
+
+func inttoPtr(i int) *int {
+	return &i
+}
+
+func decodeJSONL(t *testing.T, b []byte) []domain.Event {
+	t.Helper()
+	var events []domain.Event
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		var evt domain.Event
+		if err := json.Unmarshal(scanner.Bytes(), &evt); err != nil {
+			t.Fatalf("invalid JSONL line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, evt)
+	}
+	return events
+}
+
+func TestArchiveService_Export(t *testing.T) {
+	store := &sliceEventStore{}
+	// 同時刻のイベントをページ境界にまたがるように作る
+	for i := 0; i < 1200; i++ {
+		store.events = append(store.events, domain.Event{
+			ID:        fmt.Sprintf("%064x", i),
+			PubKey:    "pub1",
+			CreatedAt: int64(1000 + i/3),
+			Kind:      1 + i%2,
+			Tags:      [][]string{},
+		})
+	}
+
+	tests := []struct {
+		name   string
+		filter domain.Filter
+		want   int
+	}{
+		{name: "all events", filter: domain.Filter{}, want: 1200},
+		{name: "filtered by kind", filter: domain.Filter{Kinds: []int{2}}, want: 600},
+		{name: "limit", filter: domain.Filter{Limit: inttoPtr(10)}, want: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			svc := usecase.NewArchiveService(store)
+			n, err := svc.Export(context.Background(), tt.filter, &buf)
+			if err != nil {
+				t.Fatalf("Export() failed: %v", err)
+			}
+			if n != tt.want {
+				t.Errorf("Export() = %d, want %d", n, tt.want)
+			}
+
+			events := decodeJSONL(t, buf.Bytes())
+			if len(events) != tt.want {
+				t.Fatalf("Export() wrote %d lines, want %d", len(events), tt.want)
+			}
+			seen := map[string]bool{}
+			for i, evt := range events {
+				if seen[evt.ID] {
+					t.Fatalf("Export() wrote %s twice", evt.ID)
+				}
+				seen[evt.ID] = true
+				if i > 0 && evt.CreatedAt > events[i-1].CreatedAt {
+					t.Fatalf("Export() is not ordered newest first at line %d", i)
+				}
+			}
+		})
+	}
+}
+
+func TestArchiveService_Export_SameTimestampPage(t *testing.T) {
+	// 1ページ (500件) を超える数のイベントが全て同時刻
+	store := &sliceEventStore{}
+	for i := 0; i < 1100; i++ {
+		store.events = append(store.events, domain.Event{ID: fmt.Sprintf("%064x", i), CreatedAt: 1000, Kind: 1})
+	}
+
+	var buf bytes.Buffer
+	n, err := usecase.NewArchiveService(store).Export(context.Background(), domain.Filter{}, &buf)
+	if err != nil {
+		t.Fatalf("Export() failed: %v", err)
+	}
+	if n != 1100 {
+		t.Errorf("Export() = %d, want %d", n, 1100)
+	}
+}
+
+func TestArchiveService_Import(t *testing.T) {
+	valid1 := createValidTestEvent("one", 1)
+	valid2 := createValidTestEvent("two", 1)
+	duplicate := createValidTestEvent("dup", 1)
+	deleted := createValidTestEvent("deleted", 1)
+	hidden := createValidTestEvent("hidden", 1)
+	badSig := createValidTestEvent("bad sig", 1)
+	badSig.Content = "tampered"
+	vanishHere := signTestEvent(t, nostr.GeneratePrivateKey(), domain.KindVanish, nostr.Tags{{"relay", "wss://relay.example.com"}})
+	vanishAll := signTestEvent(t, nostr.GeneratePrivateKey(), domain.KindVanish, nostr.Tags{{"relay", domain.AllRelays}})
+	vanishOther := signTestEvent(t, nostr.GeneratePrivateKey(), domain.KindVanish, nostr.Tags{{"relay", "wss://other.example.com"}})
+
+	lines := func(events ...domain.Event) string {
+		var sb strings.Builder
+		for _, evt := range events {
+			b, _ := json.Marshal(evt)
+			sb.Write(b)
+			sb.WriteString("\n")
+		}
+		return sb.String()
+	}
+
+	tests := []struct {
+		name       string
+		input      string
+		skipVerify bool
+		relayURL   string
+		want       usecase.ImportStats
+	}{
+		{
+			name:  "valid events with duplicates and deletions",
+			input: lines(valid1, duplicate, deleted) + "\n" + lines(valid2),
+			want:  usecase.ImportStats{Lines: 4, Imported: 2, Duplicates: 1, Deleted: 1},
+		},
+		{
+			// 非表示の pubkey のイベントも取り込む（非表示のまま）
+			name:  "hidden events are imported",
+			input: lines(valid1, hidden),
+			want:  usecase.ImportStats{Lines: 2, Imported: 2},
+		},
+		{
+			name:  "invalid JSON and bad signature are skipped",
+			input: "not json\n" + lines(valid1, badSig),
+			want:  usecase.ImportStats{Lines: 3, Imported: 1, Invalid: 2},
+		},
+		{
+			name:       "skip-verify accepts bad signature",
+			input:      lines(valid1, badSig),
+			skipVerify: true,
+			want:       usecase.ImportStats{Lines: 2, Imported: 2},
+		},
+		{
+			// 他のリレー宛ての request to vanish は、作者のイベントを削除しないように取り込まない
+			name:     "request to vanish addressed to another relay is skipped",
+			input:    lines(vanishHere, vanishAll, vanishOther),
+			relayURL: "wss://relay.example.com/",
+			want:     usecase.ImportStats{Lines: 3, Imported: 2, Invalid: 1},
+		},
+		{
+			name:  "request to vanish without relay URL needs ALL_RELAYS",
+			input: lines(vanishHere, vanishAll),
+			want:  usecase.ImportStats{Lines: 2, Imported: 1, Invalid: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &sliceEventStore{saveErr: map[string]error{
+				duplicate.ID: domain.ErrDuplicate,
+				deleted.ID:   domain.ErrDeleted,
+				hidden.ID:    domain.ErrHidden,
+			}}
+			var progress []usecase.ImportStats
+			opts := usecase.ImportOptions{
+				SkipVerify: tt.skipVerify,
+				BatchSize:  2,
+				RelayURL:   tt.relayURL,
+				Progress:   func(s usecase.ImportStats) { progress = append(progress, s) },
+			}
+
+			got, err := usecase.NewArchiveService(store).Import(context.Background(), strings.NewReader(tt.input), opts)
+			if err != nil {
+				t.Fatalf("Import() failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Import() = %+v, want %+v", got, tt.want)
+			}
+			if len(progress) == 0 || progress[len(progress)-1] != got {
+				t.Errorf("Progress() last = %+v, want %+v", progress, got)
+			}
+		})
+	}
+}
+
+func TestArchiveService_Import_BatchEventStore(t *testing.T) {
+	var events []domain.Event
+	for i := 0; i < 5; i++ {
+		events = append(events, createValidTestEvent(fmt.Sprintf("event %d", i), 1))
+	}
+	events = append(events, events[0]) // 重複
+
+	var sb strings.Builder
+	for _, evt := range events {
+		b, _ := json.Marshal(evt)
+		sb.Write(b)
+		sb.WriteString("\n")
+	}
+
+	store := &batchEventStore{sliceEventStore: &sliceEventStore{}}
+	got, err := usecase.NewArchiveService(store).Import(context.Background(), strings.NewReader(sb.String()), usecase.ImportOptions{BatchSize: 4})
+	if err != nil {
+		t.Fatalf("Import() failed: %v", err)
+	}
+	want := usecase.ImportStats{Lines: 6, Imported: 5, Duplicates: 1}
+	if got != want {
+		t.Errorf("Import() = %+v, want %+v", got, want)
+	}
+	if store.batches != 2 {
+		t.Errorf("SaveBatch() called %d times, want %d", store.batches, 2)
+	}
+}
+
+func TestArchiveService_Import_StoreError(t *testing.T) {
+	evt := createValidTestEvent("one", 1)
+	store := &sliceEventStore{saveErr: map[string]error{evt.ID: errors.New("database error")}}
+	b, _ := json.Marshal(evt)
+
+	_, err := usecase.NewArchiveService(store).Import(context.Background(), bytes.NewReader(b), usecase.ImportOptions{})
+	if err == nil {
+		t.Fatal("Import() succeeded unexpectedly")
+	}
+}
@@ -0,0 +1,324 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"nostar/internal/relay"
+	"nostar/internal/relay/domain"
+
+	"go.uber.org/zap"
+)
+
+// GroupService manages NIP-29 groups hosted on this relay.
+// グループの状態はメモリに持ち、変更のたびに kind 39000-39003 をリレーの鍵で署名して発行する（Load で復元する）
+type GroupService struct {
+	events      relay.EventStore
+	store       relay.GroupStore
+	relayPubKey string           // グループの状態に署名するリレーの pubkey
+	creators    domain.PubKeySet // グループを作成できる pubkey（空の場合は誰でも）
+
+	applyMu sync.Mutex // Apply を直列にする（状態の計算から発行・反映までの間に他の更新を挟まない）
+
+	mu      sync.RWMutex
+	groups  map[string]*domain.Group
+	stateAt map[string]int64 // 最後に発行した状態イベントの created_at（同じ秒に更新しても置き換わるようにする）
+}
+
+func NewGroupService(events relay.EventStore, store relay.GroupStore, relayPubKey string, creators domain.PubKeySet) *GroupService {
+	return &GroupService{
+		events:      events,
+		store:       store,
+		relayPubKey: relayPubKey,
+		creators:    creators,
+		groups:      make(map[string]*domain.Group),
+		stateAt:     make(map[string]int64),
+	}
+}
+
+// Load restores the groups from the stored state events and invite codes. serve の起動時に1回呼ぶ
+func (s *GroupService) Load(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.groups = make(map[string]*domain.Group)
+	s.stateAt = make(map[string]int64)
+
+	state := domain.Subscription{Filters: []domain.Filter{{
+		Kinds:   []int{domain.KindGroupMetadata, domain.KindGroupAdmins, domain.KindGroupMembers},
+		Authors: []string{s.relayPubKey},
+	}}}
+	seen := make(map[string]struct{})
+	err := s.events.QueryStream(ctx, state, func(evt domain.Event) error {
+		// 新しい順に届くので、同じ kind・グループの古い版は読み飛ばす
+		if _, ok := seen[evt.Address()]; ok {
+			return nil
+		}
+		seen[evt.Address()] = struct{}{}
+
+		id := evt.DTag()
+		g, ok := s.groups[id]
+		if !ok {
+			g = &domain.Group{ID: id, Members: make(map[string][]string), Invites: make(map[string]struct{})}
+			s.groups[id] = g
+		}
+		g.LoadState(evt)
+		s.stateAt[id] = max(s.stateAt[id], evt.CreatedAt)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load groups: %w", err)
+	}
+
+	invites := domain.Subscription{Filters: []domain.Filter{{Kinds: []int{domain.KindGroupCreateInvite}}}}
+	err = s.events.QueryStream(ctx, invites, func(evt domain.Event) error {
+		id, _ := evt.GroupID()
+		if g, ok := s.groups[id]; ok {
+			g.Apply(evt)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load group invites: %w", err)
+	}
+	zap.S().Infow("groups loaded", "groups", len(s.groups))
+	return nil
+}
+
+// CheckWrite checks the membership and roles for an event sent to a group.
+// 受け付けない場合は *domain.RejectError（OK false で返す）
+func (s *GroupService) CheckWrite(evt domain.Event) error {
+	if domain.IsGroupMetadataKind(evt.Kind) {
+		if evt.PubKey != s.relayPubKey {
+			return domain.NewRejectError(domain.ReasonRestricted, "group state is published by the relay")
+		}
+		return nil
+	}
+
+	id, ok := evt.GroupID()
+	if !ok {
+		if isGroupRequestKind(evt.Kind) {
+			return domain.NewRejectError(domain.ReasonInvalid, "missing h tag")
+		}
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if evt.Kind == domain.KindGroupCreate {
+		if !domain.ValidGroupID(id) {
+			return domain.NewRejectError(domain.ReasonInvalid, "group id must be a-z0-9-_")
+		}
+		if _, exists := s.groups[id]; exists {
+			return domain.NewRejectError(domain.ReasonDuplicate, "group %s already exists", id)
+		}
+		if len(s.creators) > 0 && !s.creators.Contains(evt.PubKey) {
+			return domain.NewRejectError(domain.ReasonRestricted, "you are not allowed to create groups")
+		}
+		return nil
+	}
+
+	g, exists := s.groups[id]
+	if !exists {
+		return domain.NewRejectError(domain.ReasonInvalid, "group %s does not exist", id)
+	}
+
+	switch {
+	case evt.Kind == domain.KindGroupJoinRequest:
+		if g.IsMember(evt.PubKey) {
+			return domain.NewRejectError(domain.ReasonDuplicate, "already a member")
+		}
+		if !g.AcceptsJoin(evt) {
+			return domain.NewRejectError(domain.ReasonRestricted, "this group requires a valid invite code")
+		}
+	case evt.Kind == domain.KindGroupLeaveRequest:
+		if !g.IsMember(evt.PubKey) {
+			return domain.NewRejectError(domain.ReasonInvalid, "not a member")
+		}
+	case domain.IsGroupModerationKind(evt.Kind):
+		if !g.CanModerate(evt.PubKey, evt.Kind) {
+			return domain.NewRejectError(domain.ReasonRestricted, "you are not allowed to send kind %d in this group", evt.Kind)
+		}
+		return checkModeration(g, evt)
+	default:
+		if !g.IsMember(evt.PubKey) {
+			return domain.NewRejectError(domain.ReasonRestricted, "only members can write to this group")
+		}
+	}
+	return nil
+}
+
+// checkModeration は、モデレーションイベントの内容と、ロールの変更に必要な権限を確認する
+func checkModeration(g *domain.Group, evt domain.Event) error {
+	isAdmin := g.HasRole(evt.PubKey, domain.GroupRoleAdmin)
+	switch evt.Kind {
+	case domain.KindGroupPutUser:
+		found := false
+		for _, tag := range evt.Tags {
+			if len(tag) < 2 || tag[0] != "p" {
+				continue
+			}
+			found = true
+			for _, role := range tag[2:] {
+				if role != domain.GroupRoleAdmin && role != domain.GroupRoleModerator {
+					return domain.NewRejectError(domain.ReasonInvalid, "unknown role: %s", role)
+				}
+			}
+			if !isAdmin && (len(tag) > 2 || len(g.Members[tag[1]]) > 0) {
+				return domain.NewRejectError(domain.ReasonRestricted, "only admins can change roles")
+			}
+		}
+		if !found {
+			return domain.NewRejectError(domain.ReasonInvalid, "missing p tag")
+		}
+	case domain.KindGroupRemoveUser:
+		pubkeys := evt.Recipients()
+		if len(pubkeys) == 0 {
+			return domain.NewRejectError(domain.ReasonInvalid, "missing p tag")
+		}
+		for _, pk := range pubkeys {
+			if !isAdmin && len(g.Members[pk]) > 0 {
+				return domain.NewRejectError(domain.ReasonRestricted, "only admins can remove admins")
+			}
+		}
+	case domain.KindGroupDeleteEvent:
+		if _, ok := evt.TagValue("e"); !ok {
+			return domain.NewRejectError(domain.ReasonInvalid, "missing e tag")
+		}
+	case domain.KindGroupCreateInvite:
+		if _, ok := evt.TagValue("code"); !ok {
+			return domain.NewRejectError(domain.ReasonInvalid, "missing code tag")
+		}
+	}
+	return nil
+}
+
+// Apply updates the groups with a stored event.
+// CheckWrite で受け付けたイベントを保存した後に呼ぶ。状態が変わる場合は、新しい状態イベント（kind 39000-39003、未署名）を
+// publish に渡し（RelayService.Publish で署名・保存する）、成功した場合にだけメモリの状態に反映する
+// （発行できなかった状態をメモリに残すと、保存された状態イベントや再起動後の状態と食い違うため）
+func (s *GroupService) Apply(ctx context.Context, evt domain.Event, publish func(state []domain.Event) error) error {
+	id, ok := evt.GroupID()
+	if !ok || domain.IsGroupMetadataKind(evt.Kind) {
+		return nil
+	}
+
+	// publish は状態イベントを HandleEvent に通すため、mu を持ったまま呼べない。applyMu で他の Apply を待たせる
+	s.applyMu.Lock()
+	defer s.applyMu.Unlock()
+
+	switch evt.Kind {
+	case domain.KindGroupDelete:
+		n, err := s.store.DeleteGroup(ctx, id)
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		delete(s.groups, id)
+		s.mu.Unlock()
+		zap.S().Infow("group deleted", "group", id, "by", evt.PubKey, "events", n)
+		return nil
+	case domain.KindGroupDeleteEvent:
+		var ids []string
+		for _, tag := range evt.Tags {
+			if len(tag) >= 2 && tag[0] == "e" {
+				ids = append(ids, tag[1])
+			}
+		}
+		if _, err := s.store.DeleteGroupEvents(ctx, id, ids); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	// CheckWrite から保存までの間に他のイベントで状態が変わっている場合があるため、確認し直す
+	s.mu.RLock()
+	g, exists := s.groups[id]
+	s.mu.RUnlock()
+	switch {
+	case evt.Kind == domain.KindGroupCreate && exists:
+		zap.S().Warnw("ignore creating a group that already exists", "group", id, "by", evt.PubKey)
+		return nil
+	case evt.Kind == domain.KindGroupCreate:
+		g = domain.NewGroup(id, evt.PubKey)
+	case !exists:
+		return nil
+	case evt.Kind == domain.KindGroupJoinRequest && !g.AcceptsJoin(evt):
+		zap.S().Warnw("ignore join request without a valid invite code", "group", id, "by", evt.PubKey)
+		return nil
+	default:
+		g = g.Clone()
+		g.Apply(evt)
+	}
+
+	if changesGroupState(evt.Kind) {
+		s.mu.Lock()
+		createdAt := max(time.Now().Unix(), s.stateAt[id]+1)
+		s.stateAt[id] = createdAt
+		s.mu.Unlock()
+		if err := publish(g.StateEvents(createdAt)); err != nil {
+			return fmt.Errorf("failed to publish group state: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	s.groups[id] = g
+	s.mu.Unlock()
+	if evt.Kind == domain.KindGroupCreate {
+		zap.S().Infow("group created", "group", id, "by", evt.PubKey)
+	}
+	return nil
+}
+
+// VisibleTo reports whether evt can be delivered to a connection authenticated (NIP-42) as authed.
+// 非公開のグループのイベントはメンバーにだけ、招待コードは管理者にだけ、参加リクエストは管理者と本人にだけ配信する
+func (s *GroupService) VisibleTo(evt domain.Event, authed domain.PubKeySet) bool {
+	id, ok := evt.GroupID()
+	if !ok {
+		return true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	g, exists := s.groups[id]
+	return !exists || g.VisibleTo(evt, authed)
+}
+
+// CheckRead closes REQs for private groups (#h) from connections not authenticated as a member.
+func (s *GroupService) CheckRead(filter domain.Filter, authed domain.PubKeySet) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, id := range filter.Tags["h"] {
+		g, exists := s.groups[id]
+		if !exists || !g.Private {
+			continue
+		}
+		if len(authed) == 0 {
+			return domain.NewRejectError(domain.ReasonAuthRequired, "group %s is only readable by its members", id)
+		}
+		member := false
+		for pk := range authed {
+			member = member || g.IsMember(pk)
+		}
+		if !member {
+			return domain.NewRejectError(domain.ReasonRestricted, "group %s is only readable by its members", id)
+		}
+	}
+	return nil
+}
+
+// isGroupRequestKind は h タグが必須の kind（モデレーションイベントと参加・退出リクエスト）かを返す
+func isGroupRequestKind(kind int) bool {
+	return domain.IsGroupModerationKind(kind) || kind == domain.KindGroupJoinRequest || kind == domain.KindGroupLeaveRequest
+}
+
+// changesGroupState は、kind 39000-39003 の再発行が必要なイベントかを返す
+func changesGroupState(kind int) bool {
+	switch kind {
+	case domain.KindGroupCreate, domain.KindGroupPutUser, domain.KindGroupRemoveUser, domain.KindGroupEditMetadata,
+		domain.KindGroupJoinRequest, domain.KindGroupLeaveRequest:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,258 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"nostar/internal/relay/domain"
+	"nostar/internal/relay/policy"
+	"nostar/internal/relay/usecase"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// testSigner signs events with a generated key (relay.EventSigner).
+type testSigner struct {
+	sk string
+	pk string
+}
+
+func newTestSigner() *testSigner {
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	return &testSigner{sk: sk, pk: pk}
+}
+
+func (s *testSigner) PubKey() string { return s.pk }
+func (s *testSigner) Sign(evt domain.Event) (domain.Event, error) {
+	tags := make(nostr.Tags, len(evt.Tags))
+	for i, tag := range evt.Tags {
+		tags[i] = tag
+	}
+	ne := nostr.Event{CreatedAt: nostr.Timestamp(evt.CreatedAt), Kind: evt.Kind, Tags: tags, Content: evt.Content}
+	if err := ne.Sign(s.sk); err != nil {
+		return domain.Event{}, err
+	}
+	evt.ID, evt.PubKey, evt.Signature = ne.ID, ne.PubKey, ne.Sig
+	return evt, nil
+}
+
+// recordingGroupStore records the deletions requested by GroupService.
+type recordingGroupStore struct {
+	deletedEvents []string
+	deletedGroups []string
+}
+
+func (s *recordingGroupStore) DeleteGroupEvents(ctx context.Context, groupID string, ids []string) (int64, error) {
+	s.deletedEvents = append(s.deletedEvents, ids...)
+	return int64(len(ids)), nil
+}
+
+func (s *recordingGroupStore) DeleteGroup(ctx context.Context, groupID string) (int64, error) {
+	s.deletedGroups = append(s.deletedGroups, groupID)
+	return 0, nil
+}
+
+func TestGroupService(t *testing.T) {
+	ctx := context.Background()
+	adminSK := nostr.GeneratePrivateKey()
+	userSK := nostr.GeneratePrivateKey()
+	user, _ := nostr.GetPublicKey(userSK)
+
+	signer := newTestSigner()
+	store := &sliceEventStore{}
+	groupStore := &recordingGroupStore{}
+	groups := usecase.NewGroupService(store, groupStore, signer.PubKey(), nil)
+	s := usecase.NewRelayService(store, domain.NewConnectionPool(), usecase.WithGroupService(groups), usecase.WithSigner(signer))
+
+	h := nostr.Tag{"h", "team"}
+	steps := []struct {
+		name       string
+		event      domain.Event
+		wantPrefix string // 拒否の prefix（空の場合は受け付ける）
+	}{
+		{name: "create group", event: signTestEvent(t, adminSK, domain.KindGroupCreate, nostr.Tags{h})},
+		{name: "create the same group", event: signTestEvent(t, userSK, domain.KindGroupCreate, nostr.Tags{h}), wantPrefix: domain.ReasonDuplicate},
+		{name: "invalid group id", event: signTestEvent(t, userSK, domain.KindGroupCreate, nostr.Tags{{"h", "Team"}}), wantPrefix: domain.ReasonInvalid},
+		{name: "outsider writes", event: signTestEvent(t, userSK, 9, nostr.Tags{h}), wantPrefix: domain.ReasonRestricted},
+		{name: "unknown group", event: signTestEvent(t, userSK, 9, nostr.Tags{{"h", "other"}}), wantPrefix: domain.ReasonInvalid},
+		{name: "join without invite", event: signTestEvent(t, userSK, domain.KindGroupJoinRequest, nostr.Tags{h}), wantPrefix: domain.ReasonRestricted},
+		{name: "outsider creates invite", event: signTestEvent(t, userSK, domain.KindGroupCreateInvite, nostr.Tags{h, {"code", "mine"}}), wantPrefix: domain.ReasonRestricted},
+		{name: "admin creates invite", event: signTestEvent(t, adminSK, domain.KindGroupCreateInvite, nostr.Tags{h, {"code", "secret"}})},
+		{name: "join with invite", event: signTestEvent(t, userSK, domain.KindGroupJoinRequest, nostr.Tags{h, {"code", "secret"}})},
+		{name: "member writes", event: signTestEvent(t, userSK, 9, nostr.Tags{h})},
+		{name: "member moderates", event: signTestEvent(t, userSK, domain.KindGroupPutUser, nostr.Tags{h, {"p", user, domain.GroupRoleAdmin}}), wantPrefix: domain.ReasonRestricted},
+		{name: "forged group state", event: signTestEvent(t, userSK, domain.KindGroupMetadata, nostr.Tags{{"d", "team"}}), wantPrefix: domain.ReasonRestricted},
+	}
+	for _, step := range steps {
+		err := s.HandleEvent(ctx, usecase.EventMessage{Event: step.event})
+		if step.wantPrefix == "" {
+			if err != nil {
+				t.Fatalf("%s: HandleEvent() failed: %v", step.name, err)
+			}
+			continue
+		}
+		var rejectErr *domain.RejectError
+		if !errors.As(err, &rejectErr) || rejectErr.Prefix != step.wantPrefix {
+			t.Fatalf("%s: HandleEvent() error = %v, want %s", step.name, err, step.wantPrefix)
+		}
+	}
+
+	// 状態イベントはリレーの鍵で署名して保存される
+	var members domain.Event
+	for _, evt := range store.events {
+		if evt.Kind == domain.KindGroupMembers && evt.CreatedAt >= members.CreatedAt {
+			members = evt
+		}
+	}
+	if members.PubKey != signer.PubKey() {
+		t.Fatalf("group members event = %+v, want signed by the relay", members)
+	}
+	if ok, err := members.CheckSignature(); !ok {
+		t.Fatalf("group members event does not verify: %v", err)
+	}
+	if got := members.Recipients(); len(got) != 2 {
+		t.Errorf("group members = %v, want admin and user", got)
+	}
+
+	// 非公開のグループは、メンバーとして認証していない接続には読めない
+	req := usecase.ReqMessage{Subscription: domain.Subscription{ID: "sub", Filters: []domain.Filter{{Tags: map[string][]string{"h": {"team"}}}}}}
+	err := s.HandleReq(ctx, req, func(domain.Event) error { return nil })
+	var rejectErr *domain.RejectError
+	if !errors.As(err, &rejectErr) || rejectErr.Prefix != domain.ReasonAuthRequired {
+		t.Fatalf("HandleReq() error = %v, want %s", err, domain.ReasonAuthRequired)
+	}
+
+	// NEG-OPEN（NIP-77）も同じ規則で、読めないイベントの id を集合に含めない
+	err = s.QueryRefs(ctx, domain.NewConnectionID(), "neg", req.Subscription.Filters[0], func(domain.Event) error { return nil })
+	if !errors.As(err, &rejectErr) || rejectErr.Prefix != domain.ReasonAuthRequired {
+		t.Fatalf("QueryRefs() error = %v, want %s", err, domain.ReasonAuthRequired)
+	}
+	err = s.QueryRefs(ctx, domain.NewConnectionID(), "neg", domain.Filter{}, func(evt domain.Event) error {
+		if id, ok := evt.GroupID(); ok && id == "team" {
+			t.Errorf("QueryRefs() returned group event kind %d to an unauthenticated connection", evt.Kind)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("QueryRefs() failed: %v", err)
+	}
+
+	// 保存したイベントから状態を復元できる
+	reloaded := usecase.NewGroupService(store, groupStore, signer.PubKey(), nil)
+	if err := reloaded.Load(ctx); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	again := signTestEvent(t, userSK, 9, nostr.Tags{h})
+	if err := reloaded.CheckWrite(again); err != nil {
+		t.Errorf("member is not restored: %v", err)
+	}
+	join := signTestEvent(t, nostr.GeneratePrivateKey(), domain.KindGroupJoinRequest, nostr.Tags{h, {"code", "secret"}})
+	if err := reloaded.CheckWrite(join); err != nil {
+		t.Errorf("invite is not restored: %v", err)
+	}
+
+	if err := s.HandleEvent(ctx, usecase.EventMessage{Event: signTestEvent(t, adminSK, domain.KindGroupDelete, nostr.Tags{h})}); err != nil {
+		t.Fatalf("delete group: HandleEvent() failed: %v", err)
+	}
+	if len(groupStore.deletedGroups) != 1 || groupStore.deletedGroups[0] != "team" {
+		t.Errorf("deleted groups = %v, want [team]", groupStore.deletedGroups)
+	}
+	if err := s.HandleEvent(ctx, usecase.EventMessage{Event: signTestEvent(t, userSK, 9, nostr.Tags{h})}); !errors.As(err, &rejectErr) {
+		t.Errorf("write to a deleted group: HandleEvent() error = %v, want a rejection", err)
+	}
+}
+
+func TestGroupService_Apply_Rechecks(t *testing.T) {
+	// CheckWrite の後に状態が変わった場合でも、Apply で確認し直す
+	ctx := context.Background()
+	adminSK := nostr.GeneratePrivateKey()
+	userSK := nostr.GeneratePrivateKey()
+	h := nostr.Tag{"h", "team"}
+	groups := usecase.NewGroupService(&sliceEventStore{}, &recordingGroupStore{}, newTestSigner().PubKey(), nil)
+
+	var published [][]domain.Event
+	publish := func(state []domain.Event) error {
+		published = append(published, state)
+		return nil
+	}
+
+	if err := groups.Apply(ctx, signTestEvent(t, adminSK, domain.KindGroupCreate, nostr.Tags{h}), publish); err != nil {
+		t.Fatalf("Apply(create) failed: %v", err)
+	}
+	if len(published) != 1 {
+		t.Fatalf("Apply(create) published %d times, want 1", len(published))
+	}
+
+	// 同時に受け付けた2つ目の作成は、既存のグループを置き換えない
+	if err := groups.Apply(ctx, signTestEvent(t, userSK, domain.KindGroupCreate, nostr.Tags{h}), publish); err != nil || len(published) != 1 {
+		t.Fatalf("Apply(create again) = %v, published %d times, want nothing to publish", err, len(published)-1)
+	}
+	if err := groups.CheckWrite(signTestEvent(t, adminSK, domain.KindGroupCreateInvite, nostr.Tags{h, {"code", "secret"}})); err != nil {
+		t.Errorf("creator lost the admin role: %v", err)
+	}
+
+	// 招待コードのない参加リクエストでは、招待制のグループのメンバーにならない
+	if err := groups.Apply(ctx, signTestEvent(t, userSK, domain.KindGroupJoinRequest, nostr.Tags{h}), publish); err != nil || len(published) != 1 {
+		t.Fatalf("Apply(join) = %v, published %d times, want nothing to publish", err, len(published)-1)
+	}
+	if err := groups.CheckWrite(signTestEvent(t, userSK, 9, nostr.Tags{h})); err == nil {
+		t.Error("join request without an invite code made the user a member")
+	}
+}
+
+func TestGroupService_Apply_PublishFails(t *testing.T) {
+	// 状態イベントを発行できなかった場合は、メモリの状態を変えない
+	ctx := context.Background()
+	adminSK := nostr.GeneratePrivateKey()
+	userSK := nostr.GeneratePrivateKey()
+	user, _ := nostr.GetPublicKey(userSK)
+	h := nostr.Tag{"h", "team"}
+	groups := usecase.NewGroupService(&sliceEventStore{}, &recordingGroupStore{}, newTestSigner().PubKey(), nil)
+
+	ok := func([]domain.Event) error { return nil }
+	fail := func([]domain.Event) error { return errors.New("rejected") }
+
+	if err := groups.Apply(ctx, signTestEvent(t, adminSK, domain.KindGroupCreate, nostr.Tags{h}), fail); err == nil {
+		t.Fatal("Apply(create) succeeded although publishing failed")
+	}
+	if err := groups.CheckWrite(signTestEvent(t, adminSK, 9, nostr.Tags{h})); err == nil {
+		t.Fatal("group was created although its state was not published")
+	}
+
+	if err := groups.Apply(ctx, signTestEvent(t, adminSK, domain.KindGroupCreate, nostr.Tags{h}), ok); err != nil {
+		t.Fatalf("Apply(create) failed: %v", err)
+	}
+	if err := groups.Apply(ctx, signTestEvent(t, adminSK, domain.KindGroupPutUser, nostr.Tags{h, {"p", user}}), fail); err == nil {
+		t.Fatal("Apply(put user) succeeded although publishing failed")
+	}
+	if err := groups.CheckWrite(signTestEvent(t, userSK, 9, nostr.Tags{h})); err == nil {
+		t.Error("user became a member although the members event was not published")
+	}
+}
+
+func TestGroupService_StateRejectedByPolicy(t *testing.T) {
+	// ポリシーがリレーの状態イベントを shadow-reject した場合、グループは作成されない
+	ctx := context.Background()
+	adminSK := nostr.GeneratePrivateKey()
+	h := nostr.Tag{"h", "team"}
+
+	signer := newTestSigner()
+	store := &sliceEventStore{}
+	groups := usecase.NewGroupService(store, &recordingGroupStore{}, signer.PubKey(), nil)
+	s := usecase.NewRelayService(store, domain.NewConnectionPool(),
+		usecase.WithGroupService(groups),
+		usecase.WithSigner(signer),
+		usecase.WithEventPolicies(policy.NewShadow(policy.NewKindFilter(nil, []int{domain.KindGroupMembers}))),
+	)
+
+	if err := s.HandleEvent(ctx, usecase.EventMessage{Event: signTestEvent(t, adminSK, domain.KindGroupCreate, nostr.Tags{h})}); err != nil {
+		t.Fatalf("create group: HandleEvent() failed: %v", err)
+	}
+	err := s.HandleEvent(ctx, usecase.EventMessage{Event: signTestEvent(t, adminSK, 9, nostr.Tags{h})})
+	var rejectErr *domain.RejectError
+	if !errors.As(err, &rejectErr) || rejectErr.Prefix != domain.ReasonInvalid {
+		t.Errorf("write to a group whose state was rejected: HandleEvent() error = %v, want %s", err, domain.ReasonInvalid)
+	}
+}
@@ -0,0 +1,56 @@
+package usecase
+
+import (
+	"context"
+
+	"nostar/internal/relay"
+	"nostar/internal/relay/domain"
+)
+
+// IdentityService manages NIP-05 identities hosted by this relay.
+// HTTP (/.well-known/nostr.json) と CLI の両方から呼ばれる
+type IdentityService struct {
+	store relay.IdentityStore
+}
+
+func NewIdentityService(store relay.IdentityStore) *IdentityService {
+	return &IdentityService{
+		store: store,
+	}
+}
+
+// Register validates and saves an identity. An existing name is overwritten.
+func (s *IdentityService) Register(ctx context.Context, name, pubkey string, relays []string) (domain.Identity, error) {
+	identity := domain.Identity{
+		Name:   domain.NormalizeIdentityName(name),
+		PubKey: pubkey,
+		Relays: relays,
+	}
+	if err := identity.Validate(); err != nil {
+		return domain.Identity{}, err
+	}
+
+	if err := s.store.SaveIdentity(ctx, identity); err != nil {
+		return domain.Identity{}, err
+	}
+	return identity, nil
+}
+
+// Remove deletes an identity. Returns domain.ErrNotFound if it does not exist.
+func (s *IdentityService) Remove(ctx context.Context, name string) error {
+	return s.store.DeleteIdentity(ctx, domain.NormalizeIdentityName(name))
+}
+
+// List returns all identities ordered by name.
+func (s *IdentityService) List(ctx context.Context) ([]domain.Identity, error) {
+	return s.store.ListIdentities(ctx)
+}
+
+// Lookup resolves a NIP-05 name. Invalid names are rejected before hitting the store.
+func (s *IdentityService) Lookup(ctx context.Context, name string) (domain.Identity, error) {
+	name = domain.NormalizeIdentityName(name)
+	if err := domain.ValidateIdentityName(name); err != nil {
+		return domain.Identity{}, err
+	}
+	return s.store.FindIdentity(ctx, name)
+}
@@ -0,0 +1,78 @@
+package usecase
+
+import (
+	"sync"
+
+	"nostar/internal/relay/domain"
+)
+
+// maxBufferedLiveEvents は REQ の保存済みイベントを送信している間に溜めるライブイベントの上限
+// 超えた場合は EOSE の後にサブスクリプションを CLOSED にする（取りこぼしを黙って起こさない）
+const maxBufferedLiveEvents = 10000
+
+// liveKey identifies a subscription of a connection.
+type liveKey struct {
+	connID domain.ConnectionID
+	subID  string
+}
+
+// liveBuffer holds the live events that matched a subscription while its stored events were being sent.
+// EOSE の後に flush し、保存済みイベントとして送った ID は除いて送信する
+type liveBuffer struct {
+	mu       sync.Mutex
+	sent     map[string]struct{} // 保存済みイベントとして送信した ID
+	events   []domain.Event
+	overflow bool
+	live     bool // flush 済み（以降のイベントは直接送信する）
+}
+
+func newLiveBuffer() *liveBuffer {
+	return &liveBuffer{
+		sent: make(map[string]struct{}),
+	}
+}
+
+// markSent records that the event was sent as a stored event.
+func (b *liveBuffer) markSent(id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sent[id] = struct{}{}
+}
+
+// add buffers evt and reports whether it was buffered (false if the buffer is already flushed).
+func (b *liveBuffer) add(evt domain.Event) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.live {
+		return false
+	}
+	if len(b.events) >= maxBufferedLiveEvents {
+		b.overflow = true
+		return true
+	}
+	b.events = append(b.events, evt)
+	return true
+}
+
+// flush passes the buffered events that were not sent as stored events to send, then switches to live.
+// flush 中に届いたイベントは add で待たされ、flush の後に直接送信される（順序が入れ替わらない）
+func (b *liveBuffer) flush(send func(domain.Event) error) (overflow bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.live = true
+	events, sent := b.events, b.sent
+	b.events, b.sent = nil, nil
+	if b.overflow {
+		return true, nil
+	}
+	for _, evt := range events {
+		if _, ok := sent[evt.ID]; ok {
+			continue
+		}
+		if err := send(evt); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,172 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"nostar/internal/relay"
+	"nostar/internal/relay/domain"
+)
+
+// ManagementService implements NIP-86 relay management.
+// 変更はストアに保存した上でメモリにも反映し、EventPolicy としてすぐに適用する
+type ManagementService struct {
+	store  relay.ManagementStore
+	admins domain.PubKeySet
+
+	mu       sync.RWMutex
+	bans     map[domain.BanType]map[string]domain.Ban
+	settings map[string]string
+}
+
+func NewManagementService(store relay.ManagementStore, admins domain.PubKeySet) *ManagementService {
+	return &ManagementService{
+		store:    store,
+		admins:   admins,
+		bans:     make(map[domain.BanType]map[string]domain.Ban),
+		settings: make(map[string]string),
+	}
+}
+
+// Load reads the persisted state. serve の起動時に1回呼ぶ
+func (s *ManagementService) Load(ctx context.Context) error {
+	bans, err := s.store.ListBans(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load bans: %w", err)
+	}
+	settings, err := s.store.RelaySettings(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load relay settings: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bans = make(map[domain.BanType]map[string]domain.Ban)
+	for _, ban := range bans {
+		s.setBanLocked(ban)
+	}
+	s.settings = settings
+	return nil
+}
+
+// IsAdmin returns true if pubkey may call the management API.
+func (s *ManagementService) IsAdmin(pubkey string) bool {
+	return s.admins.Contains(pubkey)
+}
+
+// Ban bans a pubkey or an event, or blocks an IP address.
+func (s *ManagementService) Ban(ctx context.Context, banType domain.BanType, value, reason string) error {
+	ban := domain.Ban{
+		Type:      banType,
+		Value:     normalizeBanValue(banType, value),
+		Reason:    reason,
+		CreatedAt: time.Now(),
+	}
+	if err := s.store.AddBan(ctx, ban); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setBanLocked(ban)
+	return nil
+}
+
+// Unban removes a ban. 存在しない場合も成功とする
+func (s *ManagementService) Unban(ctx context.Context, banType domain.BanType, value string) error {
+	value = normalizeBanValue(banType, value)
+	if err := s.store.RemoveBan(ctx, banType, value); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.bans[banType], value)
+	return nil
+}
+
+// ListBans returns the bans of a type, oldest first.
+func (s *ManagementService) ListBans(banType domain.BanType) []domain.Ban {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	bans := make([]domain.Ban, 0, len(s.bans[banType]))
+	for _, ban := range s.bans[banType] {
+		bans = append(bans, ban)
+	}
+	slices.SortFunc(bans, func(a, b domain.Ban) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Value, b.Value)
+	})
+	return bans
+}
+
+// IsBanned returns true if the value is banned.
+func (s *ManagementService) IsBanned(banType domain.BanType, value string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.bans[banType][value]
+	return ok
+}
+
+// SetRelaySetting changes a NIP-11 field (domain.SettingRelayName など).
+func (s *ManagementService) SetRelaySetting(ctx context.Context, key, value string) error {
+	if err := s.store.SaveRelaySetting(ctx, key, value); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings[key] = value
+	return nil
+}
+
+// RelaySetting returns a NIP-11 field changed through the management API.
+// 変更されていない場合は config の値を使うため、ok = false を返す
+func (s *ManagementService) RelaySetting(key string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.settings[key]
+	return v, ok
+}
+
+func (s *ManagementService) Name() string { return "management" }
+
+// Check rejects events from banned pubkeys / IPs and banned events.
+func (s *ManagementService) Check(ctx context.Context, in domain.PolicyInput) domain.PolicyDecision {
+	if s.IsBanned(domain.BanPubKey, in.Event.PubKey) {
+		return domain.Reject(domain.ReasonBlocked, "pubkey is banned")
+	}
+	if s.IsBanned(domain.BanEvent, in.Event.ID) {
+		return domain.Reject(domain.ReasonBlocked, "event is banned")
+	}
+	if (in.SourceType == domain.SourceIP4 || in.SourceType == domain.SourceIP6) && s.IsBanned(domain.BanIP, in.SourceInfo) {
+		return domain.Reject(domain.ReasonBlocked, "ip address is blocked")
+	}
+	return domain.Accept()
+}
+
+func (s *ManagementService) setBanLocked(ban domain.Ban) {
+	if s.bans[ban.Type] == nil {
+		s.bans[ban.Type] = make(map[string]domain.Ban)
+	}
+	s.bans[ban.Type][ban.Value] = ban
+}
+
+func normalizeBanValue(banType domain.BanType, value string) string {
+	if banType == domain.BanIP {
+		// remoteSource と同じ表記に揃える
+		if ip := net.ParseIP(strings.TrimSpace(value)); ip != nil {
+			return ip.String()
+		}
+		return strings.TrimSpace(value)
+	}
+	return strings.ToLower(strings.TrimSpace(value))
+}
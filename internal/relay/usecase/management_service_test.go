@@ -0,0 +1,168 @@
+package usecase_test
+
+import (
+	"context"
+	"testing"
+
+	"nostar/internal/relay/domain"
+	"nostar/internal/relay/usecase"
+)
+
+// mapManagementStore is an in-memory ManagementStore.
+type mapManagementStore struct {
+	bans     map[domain.BanType]map[string]domain.Ban
+	settings map[string]string
+}
+
+func newMapManagementStore() *mapManagementStore {
+	return &mapManagementStore{
+		bans:     make(map[domain.BanType]map[string]domain.Ban),
+		settings: make(map[string]string),
+	}
+}
+
+func (m *mapManagementStore) AddBan(ctx context.Context, ban domain.Ban) error {
+	if m.bans[ban.Type] == nil {
+		m.bans[ban.Type] = make(map[string]domain.Ban)
+	}
+	m.bans[ban.Type][ban.Value] = ban
+	return nil
+}
+
+func (m *mapManagementStore) RemoveBan(ctx context.Context, banType domain.BanType, value string) error {
+	delete(m.bans[banType], value)
+	return nil
+}
+
+func (m *mapManagementStore) ListBans(ctx context.Context) ([]domain.Ban, error) {
+	var bans []domain.Ban
+	for _, byValue := range m.bans {
+		for _, ban := range byValue {
+			bans = append(bans, ban)
+		}
+	}
+	return bans, nil
+}
+
+func (m *mapManagementStore) SaveRelaySetting(ctx context.Context, key, value string) error {
+	m.settings[key] = value
+	return nil
+}
+
+func (m *mapManagementStore) RelaySettings(ctx context.Context) (map[string]string, error) {
+	settings := make(map[string]string, len(m.settings))
+	for k, v := range m.settings {
+		settings[k] = v
+	}
+	return settings, nil
+}
+
+func TestManagementService_Ban(t *testing.T) {
+	ctx := context.Background()
+	pubkey := "aa00000000000000000000000000000000000000000000000000000000000000"
+	eventID := "bb00000000000000000000000000000000000000000000000000000000000000"
+	store := newMapManagementStore()
+	svc := usecase.NewManagementService(store, domain.NewPubKeySet(nil))
+
+	if err := svc.Ban(ctx, domain.BanPubKey, "AA"+pubkey[2:], "spam"); err != nil {
+		t.Fatalf("Ban() failed: %v", err)
+	}
+	if err := svc.Ban(ctx, domain.BanEvent, eventID, "illegal"); err != nil {
+		t.Fatalf("Ban() failed: %v", err)
+	}
+	if err := svc.Ban(ctx, domain.BanIP, "::ffff:192.0.2.1", ""); err != nil {
+		t.Fatalf("Ban() failed: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		input domain.PolicyInput
+		want  domain.PolicyAction
+	}{
+		{
+			name:  "banned pubkey",
+			input: domain.PolicyInput{Event: domain.Event{ID: "other", PubKey: pubkey}},
+			want:  domain.PolicyReject,
+		},
+		{
+			name:  "banned event",
+			input: domain.PolicyInput{Event: domain.Event{ID: eventID, PubKey: "other"}},
+			want:  domain.PolicyReject,
+		},
+		{
+			name:  "blocked ip",
+			input: domain.PolicyInput{Event: domain.Event{ID: "other", PubKey: "other"}, SourceType: domain.SourceIP4, SourceInfo: "192.0.2.1"},
+			want:  domain.PolicyReject,
+		},
+		{
+			// sync の SourceInfo は URL なので IP として扱わない
+			name:  "ip ban is not applied to sync",
+			input: domain.PolicyInput{Event: domain.Event{ID: "other", PubKey: "other"}, SourceType: domain.SourceSync, SourceInfo: "192.0.2.1"},
+			want:  domain.PolicyAccept,
+		},
+		{
+			name:  "not banned",
+			input: domain.PolicyInput{Event: domain.Event{ID: "other", PubKey: "other"}, SourceType: domain.SourceIP4, SourceInfo: "192.0.2.2"},
+			want:  domain.PolicyAccept,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := svc.Check(ctx, tt.input)
+			if got.Action != tt.want {
+				t.Errorf("Check() = %v, want %v", got.Action, tt.want)
+			}
+			if got.Action == domain.PolicyReject && got.Prefix != domain.ReasonBlocked {
+				t.Errorf("Check() prefix = %q, want %q", got.Prefix, domain.ReasonBlocked)
+			}
+		})
+	}
+
+	// 永続化した状態は別のインスタンスでも読み込める
+	reloaded := usecase.NewManagementService(store, domain.NewPubKeySet(nil))
+	if err := reloaded.Load(ctx); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if bans := reloaded.ListBans(domain.BanPubKey); len(bans) != 1 || bans[0].Value != pubkey || bans[0].Reason != "spam" {
+		t.Errorf("ListBans(pubkey) = %+v", bans)
+	}
+
+	// allowpubkey / allowevent / unblockip
+	if err := reloaded.Unban(ctx, domain.BanPubKey, pubkey); err != nil {
+		t.Fatalf("Unban() failed: %v", err)
+	}
+	if err := reloaded.Unban(ctx, domain.BanEvent, eventID); err != nil {
+		t.Fatalf("Unban() failed: %v", err)
+	}
+	if err := reloaded.Unban(ctx, domain.BanIP, "192.0.2.1"); err != nil {
+		t.Fatalf("Unban() failed: %v", err)
+	}
+	for _, in := range []domain.PolicyInput{tests[0].input, tests[1].input, tests[2].input} {
+		if got := reloaded.Check(ctx, in); got.Action != domain.PolicyAccept {
+			t.Errorf("Check(%+v) after unban = %v, want accept", in, got.Action)
+		}
+	}
+	if len(store.bans[domain.BanPubKey])+len(store.bans[domain.BanEvent])+len(store.bans[domain.BanIP]) != 0 {
+		t.Errorf("bans left in store: %+v", store.bans)
+	}
+}
+
+func TestManagementService_RelaySetting(t *testing.T) {
+	ctx := context.Background()
+	admin := "cc00000000000000000000000000000000000000000000000000000000000000"
+	svc := usecase.NewManagementService(newMapManagementStore(), domain.NewPubKeySet([]string{admin}))
+
+	if !svc.IsAdmin(admin) || svc.IsAdmin("other") {
+		t.Error("IsAdmin() returned unexpected result")
+	}
+
+	if _, ok := svc.RelaySetting(domain.SettingRelayName); ok {
+		t.Error("RelaySetting() returned a value before it was set")
+	}
+	if err := svc.SetRelaySetting(ctx, domain.SettingRelayName, "renamed"); err != nil {
+		t.Fatalf("SetRelaySetting() failed: %v", err)
+	}
+	if got, ok := svc.RelaySetting(domain.SettingRelayName); !ok || got != "renamed" {
+		t.Errorf("RelaySetting() = %q, %v, want renamed", got, ok)
+	}
+}
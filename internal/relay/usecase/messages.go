@@ -4,7 +4,12 @@ import "nostar/internal/relay/domain"
 
 // EventMessage wraps an EVENT message from a client.
 type EventMessage struct {
-	Event domain.Event
+	Event          domain.Event
+	SourceType     string // domain.SourceIP4 など（空の場合は不明）
+	SourceInfo     string // 送信元 IP アドレスなど
+	AuthedPubKey   string // NIP-42 で認証済みの pubkey
+	AllowProtected bool   // NIP-70 の protected イベントを認証なしで受け付ける（管理者が明示的に sync する場合）
+	RelayURL       string // 接続先の URL（WithRelayURL を指定していない場合に request to vanish の relay タグと比較する）
 }
 
 // ReqMessage wraps a REQ with filters.
@@ -13,8 +18,37 @@ type ReqMessage struct {
 	Subscription domain.Subscription
 }
 
+// AuthMessage wraps a NIP-42 AUTH message from a client.
+type AuthMessage struct {
+	ConnectionID domain.ConnectionID
+	Event        domain.Event
+	Challenge    string // この接続に送ったチャレンジ
+	RelayURL     string // 接続先の URL（WithRelayURL を指定していない場合に relay タグと比較する）
+}
+
 // CloseMessage represents a CLOSE request for a subscription ID.
 type CloseMessage struct {
 	ConnectionID   domain.ConnectionID
 	SubscriptionID string
 }
+
+// NegOpenMessage represents a NIP-77 NEG-OPEN.
+type NegOpenMessage struct {
+	ConnectionID   domain.ConnectionID
+	SubscriptionID string
+	Filter         domain.Filter
+	Message        string // hex エンコードされた negentropy メッセージ
+}
+
+// NegMessage represents a NIP-77 NEG-MSG.
+type NegMessage struct {
+	ConnectionID   domain.ConnectionID
+	SubscriptionID string
+	Message        string
+}
+
+// NegCloseMessage represents a NIP-77 NEG-CLOSE.
+type NegCloseMessage struct {
+	ConnectionID   domain.ConnectionID
+	SubscriptionID string
+}
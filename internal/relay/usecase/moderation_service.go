@@ -0,0 +1,81 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"nostar/internal/relay"
+	"nostar/internal/relay/domain"
+
+	"go.uber.org/zap"
+)
+
+// defaultModerationLogLimit は監査ログを表示する件数のデフォルト
+const defaultModerationLogLimit = 50
+
+// ModerationService hides events from REQ results and live delivery without deleting them.
+type ModerationService struct {
+	store relay.ModerationStore
+}
+
+func NewModerationService(store relay.ModerationStore) *ModerationService {
+	return &ModerationService{
+		store: store,
+	}
+}
+
+// Hide hides an event ("event") or all events of a pubkey ("pubkey") and returns the number of newly hidden events.
+// pubkey の場合、今後保存されるイベントも非表示になる
+func (s *ModerationService) Hide(ctx context.Context, target, value, reason, actor string) (int64, error) {
+	t, v, err := domain.ParseHideTarget(target, value)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := s.store.Hide(ctx, domain.Hide{
+		Target:    t,
+		Value:     v,
+		Reason:    reason,
+		Actor:     actor,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return 0, err
+	}
+	zap.S().Infow("moderation hide", "target", t, "value", v, "actor", actor, "reason", reason, "events", n)
+	return n, nil
+}
+
+// Unhide reverts Hide and returns the number of events shown again.
+// 非表示にしていない場合は domain.ErrNotFound
+func (s *ModerationService) Unhide(ctx context.Context, target, value, reason, actor string) (int64, error) {
+	t, v, err := domain.ParseHideTarget(target, value)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := s.store.Unhide(ctx, t, v, actor, reason)
+	if err != nil {
+		return 0, err
+	}
+	zap.S().Infow("moderation unhide", "target", t, "value", v, "actor", actor, "reason", reason, "events", n)
+	return n, nil
+}
+
+// Moderated reports whether the target has ever been hidden or unhidden (監査ログに記録がある).
+func (s *ModerationService) Moderated(ctx context.Context, target domain.HideTarget, value string) (bool, error) {
+	return s.store.Moderated(ctx, target, value)
+}
+
+// List returns the current hides, oldest first.
+func (s *ModerationService) List(ctx context.Context) ([]domain.Hide, error) {
+	return s.store.ListHides(ctx)
+}
+
+// Log returns the audit log, newest first.
+func (s *ModerationService) Log(ctx context.Context, limit int) ([]domain.ModerationLogEntry, error) {
+	if limit <= 0 {
+		limit = defaultModerationLogLimit
+	}
+	return s.store.ModerationLog(ctx, limit)
+}
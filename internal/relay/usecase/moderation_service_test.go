@@ -0,0 +1,121 @@
+package usecase_test
+
+import (
+	"context"
+	"testing"
+
+	"nostar/internal/relay/domain"
+	"nostar/internal/relay/usecase"
+)
+
+// recordingModerationStore records the calls of ModerationStore.
+type recordingModerationStore struct {
+	hides   []domain.Hide
+	unhides []string
+}
+
+func (m *recordingModerationStore) Hide(ctx context.Context, hide domain.Hide) (int64, error) {
+	m.hides = append(m.hides, hide)
+	return 1, nil
+}
+
+func (m *recordingModerationStore) Unhide(ctx context.Context, target domain.HideTarget, value, actor, reason string) (int64, error) {
+	for i, h := range m.hides {
+		if h.Target == target && h.Value == value {
+			m.hides = append(m.hides[:i], m.hides[i+1:]...)
+			m.unhides = append(m.unhides, value)
+			return 1, nil
+		}
+	}
+	return 0, domain.ErrNotFound
+}
+
+func (m *recordingModerationStore) ListHides(ctx context.Context) ([]domain.Hide, error) {
+	return m.hides, nil
+}
+
+func (m *recordingModerationStore) ModerationLog(ctx context.Context, limit int) ([]domain.ModerationLogEntry, error) {
+	return nil, nil
+}
+
+func (m *recordingModerationStore) Moderated(ctx context.Context, target domain.HideTarget, value string) (bool, error) {
+	for _, h := range m.hides {
+		if h.Target == target && h.Value == value {
+			return true, nil
+		}
+	}
+	for _, v := range m.unhides {
+		if v == value {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestModerationService_Hide(t *testing.T) {
+	pubkey := "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+	npub := "npub180cvv07tjdrrgpa0j7j7tmnyl2yr6yr7l8j4s3evf6u64th6gkwsyjh6w6"
+	eventID := "AB00000000000000000000000000000000000000000000000000000000000000"
+
+	tests := []struct {
+		name      string
+		target    string
+		value     string
+		wantValue string
+		wantErr   bool
+	}{
+		{
+			name:      "event id is lowercased",
+			target:    "event",
+			value:     eventID,
+			wantValue: "ab00000000000000000000000000000000000000000000000000000000000000",
+		},
+		{
+			name:      "npub is decoded",
+			target:    "pubkey",
+			value:     npub,
+			wantValue: pubkey,
+		},
+		{
+			name:    "invalid event id",
+			target:  "event",
+			value:   "abc",
+			wantErr: true,
+		},
+		{
+			name:    "unknown target",
+			target:  "kind",
+			value:   "1",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &recordingModerationStore{}
+			svc := usecase.NewModerationService(store)
+
+			_, err := svc.Hide(context.Background(), tt.target, tt.value, "spam", "admin")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Hide() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if len(store.hides) != 0 {
+					t.Errorf("store called on invalid input: %+v", store.hides)
+				}
+				return
+			}
+			if len(store.hides) != 1 {
+				t.Fatalf("store.Hide called %d times, want 1", len(store.hides))
+			}
+			got := store.hides[0]
+			if string(got.Target) != tt.target || got.Value != tt.wantValue || got.Reason != "spam" || got.Actor != "admin" || got.CreatedAt.IsZero() {
+				t.Errorf("Hide() stored %+v", got)
+			}
+
+			// unhide も同じ正規化を行う
+			if _, err := svc.Unhide(context.Background(), tt.target, tt.value, "", "admin"); err != nil {
+				t.Errorf("Unhide() failed: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+
+	"nostar/internal/relay/domain"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip77/negentropy"
+	"github.com/nbd-wtf/go-nostr/nip77/negentropy/storage/vector"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultNegentropyMaxRecords は1つの NEG-OPEN で扱う最大イベント数
+	DefaultNegentropyMaxRecords = 500_000
+	// DefaultNegentropyMaxSessions は1つの接続で同時に開ける NEG-OPEN の数
+	DefaultNegentropyMaxSessions = 8
+
+	// negentropyFrameSizeLimit は1つの NEG-MSG の最大バイト数（strfry と同じ）
+	negentropyFrameSizeLimit = 60_000
+)
+
+type negSessionKey struct {
+	connID domain.ConnectionID
+	subID  string
+}
+
+// NegentropyService handles NIP-77 set reconciliation sessions.
+// NEG-OPEN 時点でフィルタに一致する (created_at, id) を読み込み、セッションが閉じるまで保持する
+type NegentropyService struct {
+	relay       *RelayService // REQ と同じ制限でイベントを読む
+	maxRecords  int
+	maxSessions int // 1つの接続のセッション数の上限
+
+	mu       sync.Mutex
+	sessions map[negSessionKey]*negentropy.Negentropy
+}
+
+// NewNegentropyService returns a service. maxRecords / maxSessions が 0 以下の場合はデフォルト値を使う
+func NewNegentropyService(relaySvc *RelayService, maxRecords, maxSessions int) *NegentropyService {
+	if maxRecords <= 0 {
+		maxRecords = DefaultNegentropyMaxRecords
+	}
+	if maxSessions <= 0 {
+		maxSessions = DefaultNegentropyMaxSessions
+	}
+	return &NegentropyService{
+		relay:       relaySvc,
+		maxRecords:  maxRecords,
+		maxSessions: maxSessions,
+		sessions:    make(map[negSessionKey]*negentropy.Negentropy),
+	}
+}
+
+// Open starts a session and returns the first NEG-MSG payload.
+// 同じ subscription ID のセッションが既にあれば置き換える
+// 拒否した場合は NEG-ERR の reason になる *domain.RejectError を返す
+func (s *NegentropyService) Open(ctx context.Context, msg NegOpenMessage) (string, error) {
+	key := negSessionKey{connID: msg.ConnectionID, subID: msg.SubscriptionID}
+	s.close(key)
+	if n := s.countSessions(msg.ConnectionID); n >= s.maxSessions {
+		return "", domain.NewRejectError(domain.ReasonBlocked, "too many negentropy sessions (max %d), close one first", s.maxSessions)
+	}
+
+	// 上限を超えたかを判定するため 1 件多く取得する
+	filter := msg.Filter
+	limit := s.maxRecords + 1
+	if filter.Limit != nil && *filter.Limit < limit {
+		limit = *filter.Limit
+	}
+	filter.Limit = &limit
+
+	vec := vector.New()
+	records := 0
+	errTooBig := domain.NewRejectError(domain.ReasonBlocked, "this query is too big (more than %d events)", s.maxRecords)
+	err := s.relay.QueryRefs(ctx, msg.ConnectionID, msg.SubscriptionID, filter, func(evt domain.Event) error {
+		if records++; records > s.maxRecords {
+			return errTooBig
+		}
+		if len(evt.ID) != 64 {
+			return nil // vector.Insert は不正な ID で panic する
+		}
+		vec.Insert(nostr.Timestamp(evt.CreatedAt), evt.ID)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	vec.Seal()
+
+	neg := negentropy.New(vec, negentropyFrameSizeLimit)
+	out, err := neg.Reconcile(msg.Message)
+	if err != nil {
+		return "", domain.NewRejectError(domain.ReasonInvalid, "failed to reconcile: %v", err)
+	}
+
+	s.mu.Lock()
+	s.sessions[key] = neg
+	s.mu.Unlock()
+
+	zap.S().Debugw("negentropy session opened", "connID", msg.ConnectionID, "subscriptionID", msg.SubscriptionID, "records", vec.Size())
+	return out, nil
+}
+
+// countSessions は接続で開いているセッションの数を返す
+func (s *NegentropyService) countSessions(connID domain.ConnectionID) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for key := range s.sessions {
+		if key.connID == connID {
+			n++
+		}
+	}
+	return n
+}
+
+// Message continues a session with a NEG-MSG from the client.
+// 失敗した場合、セッションは閉じる（NIP-77: NEG-ERR の後はセッションが存在しない）
+func (s *NegentropyService) Message(ctx context.Context, msg NegMessage) (string, error) {
+	key := negSessionKey{connID: msg.ConnectionID, subID: msg.SubscriptionID}
+
+	s.mu.Lock()
+	neg, ok := s.sessions[key]
+	s.mu.Unlock()
+	if !ok {
+		return "", domain.NewRejectError(domain.ReasonClosed, "unknown subscription")
+	}
+
+	out, err := neg.Reconcile(msg.Message)
+	if err != nil {
+		s.close(key)
+		return "", domain.NewRejectError(domain.ReasonInvalid, "failed to reconcile: %v", err)
+	}
+	return out, nil
+}
+
+// Close ends a session. 存在しない場合は何もしない
+func (s *NegentropyService) Close(ctx context.Context, msg NegCloseMessage) {
+	s.close(negSessionKey{connID: msg.ConnectionID, subID: msg.SubscriptionID})
+}
+
+// CloseAll ends all sessions of a connection (called on disconnect).
+func (s *NegentropyService) CloseAll(ctx context.Context, connID domain.ConnectionID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.sessions {
+		if key.connID == connID {
+			delete(s.sessions, key)
+		}
+	}
+}
+
+func (s *NegentropyService) close(key negSessionKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, key)
+}
@@ -0,0 +1,258 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	"nostar/internal/relay/domain"
+	"nostar/internal/relay/usecase"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip77/negentropy"
+	"github.com/nbd-wtf/go-nostr/nip77/negentropy/storage/vector"
+)
+
+func negSince(ts int64) *int64 {
+	return &ts
+}
+
+func negTestEvents(from, to int) []domain.Event {
+	var events []domain.Event
+	for i := from; i < to; i++ {
+		events = append(events, domain.Event{
+			ID:        fmt.Sprintf("%064x", i),
+			PubKey:    "pub1",
+			CreatedAt: int64(1000 + i/3), // 同時刻のイベントも含める
+			Kind:      1,
+		})
+	}
+	return events
+}
+
+// reconcile runs a negentropy client against the service and returns (haves, haveNots) from the client's view.
+func reconcile(t *testing.T, svc *usecase.NegentropyService, connID domain.ConnectionID, filter domain.Filter, local []domain.Event) ([]string, []string) {
+	t.Helper()
+	ctx := context.Background()
+
+	vec := vector.New()
+	for _, evt := range local {
+		vec.Insert(nostr.Timestamp(evt.CreatedAt), evt.ID)
+	}
+	vec.Seal()
+	client := negentropy.New(vec, 0)
+
+	var haves, haveNots []string
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for id := range client.Haves {
+			haves = append(haves, id)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for id := range client.HaveNots {
+			haveNots = append(haveNots, id)
+		}
+	}()
+
+	out, err := svc.Open(ctx, usecase.NegOpenMessage{ConnectionID: connID, SubscriptionID: "neg", Filter: filter, Message: client.Start()})
+	for rounds := 0; ; rounds++ {
+		if err != nil {
+			t.Fatalf("negentropy failed: %v", err)
+		}
+		if rounds > 20 {
+			t.Fatal("negentropy did not converge")
+		}
+		next, err := client.Reconcile(out)
+		if err != nil {
+			t.Fatalf("client Reconcile() failed: %v", err)
+		}
+		if next == "" {
+			break
+		}
+		out, err = svc.Message(ctx, usecase.NegMessage{ConnectionID: connID, SubscriptionID: "neg", Message: next})
+	}
+	wg.Wait()
+
+	sort.Strings(haves)
+	sort.Strings(haveNots)
+	return haves, haveNots
+}
+
+func ids(events []domain.Event) []string {
+	var res []string
+	for _, evt := range events {
+		res = append(res, evt.ID)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestNegentropyService_Reconcile(t *testing.T) {
+	tests := []struct {
+		name         string
+		relay        []domain.Event
+		client       []domain.Event
+		filter       domain.Filter
+		wantHaves    []string
+		wantHaveNots []string
+	}{
+		{
+			name:         "same set",
+			relay:        negTestEvents(0, 100),
+			client:       negTestEvents(0, 100),
+			wantHaves:    nil,
+			wantHaveNots: nil,
+		},
+		{
+			name:         "overlapping sets",
+			relay:        negTestEvents(0, 80),
+			client:       negTestEvents(40, 120),
+			wantHaves:    ids(negTestEvents(80, 120)),
+			wantHaveNots: ids(negTestEvents(0, 40)),
+		},
+		{
+			name:         "empty relay",
+			client:       negTestEvents(0, 10),
+			wantHaves:    ids(negTestEvents(0, 10)),
+			wantHaveNots: nil,
+		},
+		{
+			// 範囲外のイベントはリレー側の集合に含まれない
+			name:         "filtered by since",
+			relay:        negTestEvents(0, 60),
+			client:       negTestEvents(30, 60),
+			filter:       domain.Filter{Since: negSince(1010)},
+			wantHaves:    nil,
+			wantHaveNots: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &sliceEventStore{events: tt.relay}
+			svc := usecase.NewNegentropyService(usecase.NewRelayService(store, domain.NewConnectionPool()), 0, 0)
+
+			haves, haveNots := reconcile(t, svc, domain.NewConnectionID(), tt.filter, tt.client)
+			if fmt.Sprint(haves) != fmt.Sprint(tt.wantHaves) {
+				t.Errorf("haves = %v, want %v", haves, tt.wantHaves)
+			}
+			if fmt.Sprint(haveNots) != fmt.Sprint(tt.wantHaveNots) {
+				t.Errorf("haveNots = %v, want %v", haveNots, tt.wantHaveNots)
+			}
+		})
+	}
+}
+
+func TestNegentropyService_PrivateMessages(t *testing.T) {
+	// 認証していない接続の集合に、DM・gift wrap の id を含めない
+	dm := domain.Event{ID: fmt.Sprintf("%064x", 1000), PubKey: "pub1", CreatedAt: 2000, Kind: domain.KindGiftWrap, Tags: [][]string{{"p", "pub2"}}}
+	store := &sliceEventStore{events: append(negTestEvents(0, 10), dm)}
+	svc := usecase.NewNegentropyService(usecase.NewRelayService(store, domain.NewConnectionPool()), 0, 0)
+
+	haves, haveNots := reconcile(t, svc, domain.NewConnectionID(), domain.Filter{}, nil)
+	if haves != nil {
+		t.Errorf("haves = %v, want none", haves)
+	}
+	if want := ids(negTestEvents(0, 10)); fmt.Sprint(haveNots) != fmt.Sprint(want) {
+		t.Errorf("haveNots = %v, want %v", haveNots, want)
+	}
+
+	vec := vector.New()
+	vec.Seal()
+	_, err := svc.Open(context.Background(), usecase.NegOpenMessage{
+		ConnectionID:   domain.NewConnectionID(),
+		SubscriptionID: "neg",
+		Filter:         domain.Filter{Kinds: []int{domain.KindGiftWrap}},
+		Message:        negentropy.New(vec, 0).Start(),
+	})
+	var rejectErr *domain.RejectError
+	if !errors.As(err, &rejectErr) || rejectErr.Prefix != domain.ReasonAuthRequired {
+		t.Errorf("Open(kinds [%d]) error = %v, want %s", domain.KindGiftWrap, err, domain.ReasonAuthRequired)
+	}
+}
+
+func TestNegentropyService_Errors(t *testing.T) {
+	ctx := context.Background()
+	store := &sliceEventStore{events: negTestEvents(0, 10)}
+	svc := usecase.NewNegentropyService(usecase.NewRelayService(store, domain.NewConnectionPool()), 5, 2)
+	connID := domain.NewConnectionID()
+
+	vec := vector.New()
+	vec.Seal()
+	initial := negentropy.New(vec, 0).Start()
+
+	assertReason := func(t *testing.T, err error, prefix string) {
+		t.Helper()
+		var rejectErr *domain.RejectError
+		if !errors.As(err, &rejectErr) || rejectErr.Prefix != prefix {
+			t.Errorf("err = %v, want prefix %q", err, prefix)
+		}
+	}
+
+	t.Run("too many records", func(t *testing.T) {
+		_, err := svc.Open(ctx, usecase.NegOpenMessage{ConnectionID: connID, SubscriptionID: "neg", Message: initial})
+		assertReason(t, err, domain.ReasonBlocked)
+	})
+
+	t.Run("unknown subscription", func(t *testing.T) {
+		_, err := svc.Message(ctx, usecase.NegMessage{ConnectionID: connID, SubscriptionID: "unknown", Message: initial})
+		assertReason(t, err, domain.ReasonClosed)
+	})
+
+	t.Run("closed session", func(t *testing.T) {
+		filter := domain.Filter{Limit: inttoPtr(3)}
+		if _, err := svc.Open(ctx, usecase.NegOpenMessage{ConnectionID: connID, SubscriptionID: "neg", Filter: filter, Message: initial}); err != nil {
+			t.Fatalf("Open() failed: %v", err)
+		}
+		svc.CloseAll(ctx, connID)
+		_, err := svc.Message(ctx, usecase.NegMessage{ConnectionID: connID, SubscriptionID: "neg", Message: initial})
+		assertReason(t, err, domain.ReasonClosed)
+	})
+
+	t.Run("too many sessions", func(t *testing.T) {
+		connID := domain.NewConnectionID()
+		filter := domain.Filter{Limit: inttoPtr(3)}
+		for _, subID := range []string{"neg1", "neg2"} {
+			if _, err := svc.Open(ctx, usecase.NegOpenMessage{ConnectionID: connID, SubscriptionID: subID, Filter: filter, Message: initial}); err != nil {
+				t.Fatalf("Open(%s) failed: %v", subID, err)
+			}
+		}
+		// 同じ subscription ID の置き換えは数えない
+		if _, err := svc.Open(ctx, usecase.NegOpenMessage{ConnectionID: connID, SubscriptionID: "neg1", Filter: filter, Message: initial}); err != nil {
+			t.Fatalf("Open(neg1) again failed: %v", err)
+		}
+		_, err := svc.Open(ctx, usecase.NegOpenMessage{ConnectionID: connID, SubscriptionID: "neg3", Filter: filter, Message: initial})
+		assertReason(t, err, domain.ReasonBlocked)
+	})
+
+	t.Run("query limits", func(t *testing.T) {
+		// REQ と同じ制限を適用する
+		svc := usecase.NewNegentropyService(usecase.NewRelayService(store, domain.NewConnectionPool(),
+			usecase.WithQueryLimits(domain.QueryLimits{PrefixMatch: true, MinPrefixLength: 8, RejectUnbounded: true}),
+		), 0, 0)
+		for name, tt := range map[string]struct {
+			filter domain.Filter
+			prefix string
+		}{
+			"short prefix":       {filter: domain.Filter{Authors: []string{"abc"}}, prefix: domain.ReasonInvalid},
+			"tag is not indexed": {filter: domain.Filter{Tags: map[string][]string{"emoji": {"cat"}}}, prefix: domain.ReasonInvalid},
+			"unbounded":          {filter: domain.Filter{Kinds: []int{1}}, prefix: domain.ReasonBlocked},
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := svc.Open(ctx, usecase.NegOpenMessage{ConnectionID: connID, SubscriptionID: "neg", Filter: tt.filter, Message: initial})
+				assertReason(t, err, tt.prefix)
+			})
+		}
+	})
+
+	t.Run("malformed message", func(t *testing.T) {
+		_, err := svc.Open(ctx, usecase.NegOpenMessage{ConnectionID: connID, SubscriptionID: "neg", Filter: domain.Filter{Limit: inttoPtr(3)}, Message: "zz"})
+		assertReason(t, err, domain.ReasonInvalid)
+	})
+}
@@ -2,7 +2,10 @@ package usecase
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"nostar/internal/infrastructure/memory"
 	"nostar/internal/relay"
@@ -17,14 +20,83 @@ type RelayService struct {
 	store    relay.EventStore
 	registry domain.SubscriptionRegistry
 	connPool *domain.ConnectionPool
+
+	policies domain.PolicyChain // イベント受け入れポリシー（空の場合はすべて受け入れる）
+	reports  *ReportService     // NIP-56 の報告による自動非表示（nil の場合は何もしない）
+	groups   *GroupService      // NIP-29 のグループ（nil の場合は h タグを確認しない）
+	signer   relay.EventSigner  // リレー自身の鍵（nil の場合は Publish できない）
+	limits   domain.QueryLimits // REQ のコスト制限（ゼロ値の場合は制限しない）
+
+	liveMu  sync.Mutex
+	buffers map[liveKey]*liveBuffer // 保存済みイベントを送信中のサブスクリプション（EOSE までライブイベントを溜める）
+
+	relayURL string // NIP-42 / NIP-62 の relay タグと比較する URL（空の場合はメッセージの RelayURL）
+	authMu   sync.RWMutex
+	authed   map[domain.ConnectionID]domain.PubKeySet // NIP-42 で認証済みの pubkey（1つの接続で複数認証できる）
+}
+
+// Option configures optional behaviour of RelayService.
+type Option func(*RelayService)
+
+// WithEventPolicies sets the ordered acceptance policies evaluated in HandleEvent.
+func WithEventPolicies(policies ...domain.EventPolicy) Option {
+	return func(s *RelayService) {
+		s.policies = policies
+	}
+}
+
+// WithReportService enables auto-hiding targets of stored NIP-56 reports.
+func WithReportService(reports *ReportService) Option {
+	return func(s *RelayService) {
+		s.reports = reports
+	}
+}
+
+// WithGroupService enables NIP-29 groups: membership checks for h-tagged events and publishing the group state.
+func WithGroupService(groups *GroupService) Option {
+	return func(s *RelayService) {
+		s.groups = groups
+	}
+}
+
+// WithSigner sets the relay's own key used by Publish (NIP-11 の self).
+func WithSigner(signer relay.EventSigner) Option {
+	return func(s *RelayService) {
+		s.signer = signer
+	}
+}
+
+// WithRelayURL sets the relay URL that NIP-42 AUTH events and NIP-62 requests to vanish must name (リバースプロキシ配下の場合に指定する).
+func WithRelayURL(url string) Option {
+	return func(s *RelayService) {
+		s.relayURL = url
+	}
 }
 
-func NewRelayService(store relay.EventStore, connPool *domain.ConnectionPool) *RelayService {
-	return &RelayService{
+// WithQueryLimits sets the cost limits applied to REQ filters.
+func WithQueryLimits(limits domain.QueryLimits) Option {
+	return func(s *RelayService) {
+		s.limits = limits
+	}
+}
+
+func NewRelayService(store relay.EventStore, connPool *domain.ConnectionPool, opts ...Option) *RelayService {
+	s := &RelayService{
 		store:    store,
 		registry: memory.NewMemorySubscriptionRegistry(),
 		connPool: connPool, // BroadcastToSubscribers などを行うために、サービスでもコネクションプールにアクセスする
+		buffers:  make(map[liveKey]*liveBuffer),
+		authed:   make(map[domain.ConnectionID]domain.PubKeySet),
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// QueryLimits returns the cost limits applied to REQ filters （NIP-11 の limitation で公開する）
+func (s *RelayService) QueryLimits() domain.QueryLimits {
+	return s.limits
 }
 
 // HandleEvent processes an EVENT message: validation, persistence, and fanout.
@@ -32,21 +104,99 @@ func (s *RelayService) HandleEvent(ctx context.Context, msg EventMessage) error
 	zap.S().Debugw("HandleEvent called", "event_id", msg.Event.ID, "kind", msg.Event.Kind)
 	// Validate event fields
 	if err := msg.Event.Validate(); err != nil {
-		return err
+		return domain.NewRejectError(domain.ReasonInvalid, "%s", err)
+	}
+
+	if msg.Event.Kind == domain.KindClientAuth {
+		// NIP-42: 認証イベントは保存・配信しない
+		return domain.NewRejectError(domain.ReasonInvalid, "kind %d must be sent with AUTH", domain.KindClientAuth)
+	}
+	if msg.Event.Kind == domain.KindVanish && !msg.Event.VanishesFrom(s.relayURLOr(msg.RelayURL)) {
+		// NIP-62: 他のリレー宛ての request to vanish は保存しない（保存すると作者のイベントを削除してしまう）
+		return domain.NewRejectError(domain.ReasonInvalid, "request to vanish is not addressed to this relay")
 	}
 
 	// Verify signature and ID
 	valid, err := msg.Event.CheckSignature()
 	if err != nil {
-		return err
+		return domain.NewRejectError(domain.ReasonInvalid, "%s", err)
 	}
 	if !valid {
-		return err
+		return domain.NewRejectError(domain.ReasonInvalid, "invalid signature")
+	}
+
+	// NIP-70: protected イベントは作者本人として認証した接続からのみ受け付ける
+	if msg.Event.IsProtected() && !msg.AllowProtected {
+		if msg.AuthedPubKey == "" {
+			return domain.NewRejectError(domain.ReasonAuthRequired, "this event may only be published by its author")
+		}
+		if msg.AuthedPubKey != msg.Event.PubKey {
+			return domain.NewRejectError(domain.ReasonRestricted, "this event may only be published by its author")
+		}
+	}
+
+	if s.groups != nil {
+		// NIP-29: グループのメンバー・ロールの確認
+		if err := s.groups.CheckWrite(msg.Event); err != nil {
+			return err
+		}
+	}
+
+	// Acceptance policies
+	decision, p := s.policies.Evaluate(ctx, domain.PolicyInput{
+		Event:        msg.Event,
+		ReceivedAt:   time.Now().Unix(),
+		SourceType:   msg.SourceType,
+		SourceInfo:   msg.SourceInfo,
+		AuthedPubKey: msg.AuthedPubKey,
+	})
+	switch decision.Action {
+	case domain.PolicyReject:
+		return &domain.RejectError{Prefix: decision.Prefix, Message: decision.Message}
+	case domain.PolicyShadowReject:
+		zap.S().Infow("event shadow-rejected", "event_id", msg.Event.ID, "policy", p.Name(), "reason", decision.Reason())
+		if msg.SourceType == domain.SourceRelay {
+			// Publish の呼び出し元（グループの状態など）には、保存されなかったことを返す
+			return &domain.RejectError{Prefix: decision.Prefix, Message: decision.Message}
+		}
+		// 送信者には成功したように見せる（保存・配信はしない）
+		return nil
 	}
 
 	// Save to store
 	if err := s.store.Save(ctx, msg.Event); err != nil {
-		return err
+		if errors.Is(err, domain.ErrDeleted) {
+			return domain.NewRejectError(domain.ReasonBlocked, "event was deleted by its author")
+		}
+		if errors.Is(err, domain.ErrHidden) {
+			// モデレーションで非表示の pubkey: 保存はするが配信しない（送信者には成功を返す）
+			zap.S().Infow("event from hidden pubkey", "event_id", msg.Event.ID, "pubkey", msg.Event.PubKey)
+			return nil
+		}
+		return err // domain.ErrDuplicate はそのまま返す（呼び出し側で OK true として扱う）
+	}
+
+	if s.reports != nil && msg.Event.Kind == domain.KindReport {
+		// 報告自体は保存できているので、失敗してもログに残すだけにする
+		if err := s.reports.HandleReport(ctx, msg.Event); err != nil {
+			zap.S().Errorw("failed to handle report", "event_id", msg.Event.ID, "error", err)
+		}
+	}
+
+	if s.groups != nil {
+		// イベントは保存できているので、グループの状態の更新に失敗してもログに残すだけにする
+		// 状態イベントを発行できなかった場合、グループの状態は変わらない
+		err := s.groups.Apply(ctx, msg.Event, func(state []domain.Event) error {
+			for _, evt := range state {
+				if _, err := s.Publish(ctx, evt); err != nil && !errors.Is(err, domain.ErrDuplicate) {
+					return fmt.Errorf("kind %d: %w", evt.Kind, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			zap.S().Errorw("failed to apply group event", "event_id", msg.Event.ID, "error", err)
+		}
 	}
 
 	// 関心のある subscribers （connectionID含む）を取得
@@ -59,13 +209,238 @@ func (s *RelayService) HandleEvent(ctx context.Context, msg EventMessage) error
 	return nil
 }
 
-// HandleReq processes a REQ: query stored events and start live subscription if available.
-func (s *RelayService) HandleReq(ctx context.Context, msg ReqMessage) ([]domain.Event, error) {
-	events, err := s.store.Query(ctx, msg.Subscription)
+// RelayPubKey returns the pubkey of the relay's own key (NIP-11 の self). 鍵がない場合は空
+func (s *RelayService) RelayPubKey() string {
+	if s.signer == nil {
+		return ""
+	}
+	return s.signer.PubKey()
+}
+
+// Publish signs evt with the relay's own key and handles it like an EVENT from a client (検証・ポリシー・保存・配信).
+// pubkey・id・sig は上書きする。created_at が 0 の場合は現在時刻にする
+// グループの状態（NIP-29）やお知らせなど、リレーが発行するイベントに使う
+// ポリシーが shadow-reject した場合も、保存されていないため *domain.RejectError を返す
+func (s *RelayService) Publish(ctx context.Context, evt domain.Event) (domain.Event, error) {
+	if s.signer == nil {
+		return domain.Event{}, errors.New("relay key is not configured")
+	}
+	if evt.CreatedAt == 0 {
+		evt.CreatedAt = time.Now().Unix()
+	}
+	if evt.Tags == nil {
+		evt.Tags = [][]string{}
+	}
+	signed, err := s.signer.Sign(evt)
+	if err != nil {
+		return domain.Event{}, err
+	}
+
+	err = s.HandleEvent(ctx, EventMessage{
+		Event:        signed,
+		SourceType:   domain.SourceRelay,
+		AuthedPubKey: signed.PubKey, // リレー自身として認証済みとみなす（NIP-70 の protected イベントも発行できる）
+	})
+	return signed, err
+}
+
+// visibleTo reports whether evt can be delivered to a connection authenticated (NIP-42) as authed.
+func (s *RelayService) visibleTo(evt domain.Event, authed domain.PubKeySet) bool {
+	if !evt.VisibleTo(authed) {
+		return false
+	}
+	return s.groups == nil || s.groups.VisibleTo(evt, authed)
+}
+
+// HandleReq processes a REQ: query stored events and pass them to send as they are read.
+// send がエラーを返すか ctx が終了（CLOSE・切断）すると、読み込みを中断する
+// 広すぎるフィルタやタイムアウトしたクエリは *domain.RejectError（CLOSED で返す）
+//
+// msg.ConnectionID がある場合は、検索の前にサブスクリプションを登録し、その間のライブイベントを溜めておく
+// （EOSE の後に RegisterSubscription で送信する。検索と登録の間のイベントを取りこぼさない）
+func (s *RelayService) HandleReq(ctx context.Context, msg ReqMessage, send func(domain.Event) error) error {
+	// NIP-17 / NIP-59: 非公開の kind は、作者・受信者として認証した接続にのみ送る
+	authed := s.AuthedPubKeys(msg.ConnectionID)
+	for _, f := range msg.Subscription.Filters {
+		if err := s.checkRead(f, authed); err != nil {
+			return err
+		}
+	}
+	sendVisible := send
+	send = func(evt domain.Event) error {
+		if !s.visibleTo(evt, authed) {
+			return nil
+		}
+		return sendVisible(evt)
+	}
+
+	// limit を適用したフィルタで検索する（ライブ配信には元のフィルタを使う）
+	planned := domain.Subscription{ID: msg.Subscription.ID, Filters: make([]domain.Filter, len(msg.Subscription.Filters))}
+	for i, f := range msg.Subscription.Filters {
+		p, err := s.limits.Plan(f)
+		if err != nil {
+			return err
+		}
+		planned.Filters[i] = p
+	}
+	planned.Filters = s.restrictPrivateKinds(planned.Filters, authed)
+
+	var buf *liveBuffer
+	if msg.ConnectionID != "" {
+		buf = s.startBuffering(msg)
+		sendStored := send
+		send = func(evt domain.Event) error {
+			buf.markSent(evt.ID)
+			return sendStored(evt)
+		}
+	}
+
+	queryCtx := ctx
+	if s.limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		queryCtx, cancel = context.WithTimeout(ctx, s.limits.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	rows := 0
+	err := s.store.QueryStream(queryCtx, planned, func(evt domain.Event) error {
+		rows++
+		return send(evt)
+	})
+	if elapsed := time.Since(start); s.limits.SlowQuery > 0 && elapsed >= s.limits.SlowQuery {
+		s.logSlowQuery(planned, elapsed, rows)
+	}
 	if err != nil {
-		return nil, err
+		if buf != nil {
+			s.stopBuffering(liveKey{msg.ConnectionID, msg.Subscription.ID}, buf)
+		}
+		// 呼び出し元のキャンセルはタイムアウトとして扱わない
+		if ctx.Err() == nil && errors.Is(queryCtx.Err(), context.DeadlineExceeded) {
+			return domain.NewRejectError(domain.ReasonError, "query timed out, narrow the filter")
+		}
+		return err
+	}
+	return nil
+}
+
+// checkRead は、非公開の kind（NIP-17 / NIP-59）を求めるフィルタに認証を、
+// 非公開のグループ（NIP-29）を求めるフィルタにメンバーとしての認証を要求する
+func (s *RelayService) checkRead(f domain.Filter, authed domain.PubKeySet) error {
+	if f.RequestsPrivateKinds() && len(authed) == 0 {
+		return domain.NewRejectError(domain.ReasonAuthRequired, "private messages are only sent to their authenticated author or recipients")
+	}
+	if s.groups != nil {
+		return s.groups.CheckRead(f, authed)
+	}
+	return nil
+}
+
+// restrictPrivateKinds は非公開の kind のフィルタを、認証済みの pubkey が作者・受信者のイベントだけを読むように分ける
+// （見えないイベントで limit が埋まらないように。配信前の VisibleTo の確認は残す）
+// p タグを索引付けしていない場合は受信者として引けないため、分けずに VisibleTo の確認だけにする
+func (s *RelayService) restrictPrivateKinds(filters []domain.Filter, authed domain.PubKeySet) []domain.Filter {
+	if !s.limits.IndexedTags.Contains("p") {
+		return filters
+	}
+	var res []domain.Filter
+	for _, f := range filters {
+		res = append(res, f.RestrictPrivateKinds(authed)...)
+	}
+	return res
+}
+
+// QueryRefs reads the (created_at, id) of the stored events matching filter for a NIP-77 NEG-OPEN.
+// REQ と同じ制限（前方一致・索引のないタグ・広すぎるフィルタ・タイムアウト）を適用し、拒否した場合は *domain.RejectError（NEG-ERR で返す）
+// 件数は NEG-OPEN の max_records で制限するため、filter の limit はそのまま使う
+func (s *RelayService) QueryRefs(ctx context.Context, connID domain.ConnectionID, subID string, filter domain.Filter, fn func(domain.Event) error) error {
+	// REQ と同じく、見えないイベントの id を集合に含めない
+	authed := s.AuthedPubKeys(connID)
+	if err := s.checkRead(filter, authed); err != nil {
+		return err
+	}
+	if _, err := s.limits.Plan(filter); err != nil {
+		return err
+	}
+	insertVisible := fn
+	fn = func(evt domain.Event) error {
+		if !s.visibleTo(evt, authed) {
+			return nil
+		}
+		return insertVisible(evt)
+	}
+
+	queryCtx := ctx
+	if s.limits.Timeout > 0 {
+		var cancel context.CancelFunc
+		queryCtx, cancel = context.WithTimeout(ctx, s.limits.Timeout)
+		defer cancel()
+	}
+
+	sub := domain.Subscription{ID: subID, Filters: s.restrictPrivateKinds([]domain.Filter{filter}, authed)}
+	var err error
+	if store, ok := s.store.(relay.EventRefStore); ok {
+		err = store.QueryRefs(queryCtx, sub, fn)
+	} else {
+		// EventRefStore でない場合はイベント全体を読む
+		err = s.store.QueryStream(queryCtx, sub, fn)
+	}
+	if err != nil && ctx.Err() == nil && errors.Is(queryCtx.Err(), context.DeadlineExceeded) {
+		return domain.NewRejectError(domain.ReasonError, "query timed out, narrow the filter")
+	}
+	return err
+}
+
+// logSlowQuery はクエリが遅かったフィルタを、どのインデックスで引いたかとともにログに出す
+func (s *RelayService) logSlowQuery(sub domain.Subscription, elapsed time.Duration, rows int) {
+	filters := make([]map[string]any, len(sub.Filters))
+	paths := make([]domain.QueryPath, len(sub.Filters))
+	for i, f := range sub.Filters {
+		filters[i] = f.Raw
+		paths[i] = f.QueryPath()
+	}
+	zap.S().Warnw("slow REQ query", "subscription_id", sub.ID, "elapsed", elapsed, "rows", rows, "paths", paths, "filters", filters)
+}
+
+// HandleAuth verifies a NIP-42 AUTH event and records its pubkey as authenticated on the connection.
+// 検証に失敗した場合は *domain.RejectError（OK false で返す）
+func (s *RelayService) HandleAuth(ctx context.Context, msg AuthMessage) error {
+	if err := domain.VerifyClientAuth(msg.Event, msg.Challenge, s.relayURLOr(msg.RelayURL), time.Now()); err != nil {
+		return domain.NewRejectError(domain.ReasonInvalid, "%s", err)
+	}
+
+	s.authMu.Lock()
+	defer s.authMu.Unlock()
+	// PubKeySet は変更しないため、追加した集合に差し替える
+	current := s.authed[msg.ConnectionID]
+	pubkeys := make([]string, 0, len(current)+1)
+	for pk := range current {
+		pubkeys = append(pubkeys, pk)
+	}
+	s.authed[msg.ConnectionID] = domain.NewPubKeySet(append(pubkeys, msg.Event.PubKey))
+	return nil
+}
+
+// relayURLOr returns the configured relay URL, or connURL (接続先の URL) if none is configured.
+func (s *RelayService) relayURLOr(connURL string) string {
+	if s.relayURL != "" {
+		return s.relayURL
 	}
-	return events, nil
+	return connURL
+}
+
+// AuthedPubKeys returns the pubkeys authenticated (NIP-42) on the connection.
+func (s *RelayService) AuthedPubKeys(connID domain.ConnectionID) domain.PubKeySet {
+	s.authMu.RLock()
+	defer s.authMu.RUnlock()
+	return s.authed[connID]
+}
+
+// ClearAuth forgets the authentication of a closed connection.
+func (s *RelayService) ClearAuth(connID domain.ConnectionID) {
+	s.authMu.Lock()
+	defer s.authMu.Unlock()
+	delete(s.authed, connID)
 }
 
 // HandleClose processes CLOSE; any subscription cleanup would happen here.
@@ -73,25 +448,111 @@ func (s *RelayService) HandleClose(ctx context.Context, msg CloseMessage) error
 	return nil
 }
 
+// RegisterSubscription starts live delivery for the subscription.
+// HandleReq で溜めたライブイベントがあれば、保存済みイベントと重複するものを除いて先に送信する
+// 溜めたイベントが上限を超えていた場合は登録を解除し、*domain.RejectError（CLOSED で返す）を返す
 // 既に subscriptionID が存在する場合は上書きする
 func (s *RelayService) RegisterSubscription(ctx context.Context, msg ReqMessage) error {
-	// まず既存のサブスクリプションを解除（存在しなくてもエラーにならない）
-	_ = s.registry.Unregister(msg.ConnectionID, msg.Subscription.ID)
+	key := liveKey{msg.ConnectionID, msg.Subscription.ID}
+	s.liveMu.Lock()
+	buf, buffering := s.buffers[key]
+	s.liveMu.Unlock()
 
-	return s.registry.Register(msg.ConnectionID, msg.Subscription)
+	if !buffering {
+		// まず既存のサブスクリプションを解除（存在しなくてもエラーにならない）
+		_ = s.registry.Unregister(msg.ConnectionID, msg.Subscription.ID)
+		return s.registry.Register(msg.ConnectionID, msg.Subscription)
+	}
+
+	overflow, err := buf.flush(func(evt domain.Event) error {
+		conn, exists := s.connPool.Get(msg.ConnectionID)
+		if !exists {
+			return nil
+		}
+		return conn.WriteJSON([]any{"EVENT", msg.Subscription.ID, evt})
+	})
+	// flush の後は直接送信されるため、バッファを外す
+	s.liveMu.Lock()
+	if s.buffers[key] == buf {
+		delete(s.buffers, key)
+	}
+	s.liveMu.Unlock()
+
+	if overflow {
+		_ = s.registry.Unregister(msg.ConnectionID, msg.Subscription.ID)
+		return domain.NewRejectError(domain.ReasonError, "too many new events while sending stored events, retry the REQ")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send buffered events to connection %s: %w", msg.ConnectionID, err)
+	}
+	return nil
 }
 
 func (s *RelayService) UnregisterSubscription(ctx context.Context, msg CloseMessage) error {
+	s.liveMu.Lock()
+	delete(s.buffers, liveKey{msg.ConnectionID, msg.SubscriptionID})
+	s.liveMu.Unlock()
 	return s.registry.Unregister(msg.ConnectionID, msg.SubscriptionID)
 }
 
 func (s *RelayService) UnregisterAllSubscriptions(ctx context.Context, connID domain.ConnectionID) error {
+	s.liveMu.Lock()
+	for key := range s.buffers {
+		if key.connID == connID {
+			delete(s.buffers, key)
+		}
+	}
+	s.liveMu.Unlock()
 	return s.registry.UnregisterAll(connID)
 }
 
+// startBuffering registers the subscription and buffers its live events until RegisterSubscription.
+// ブロードキャストがバッファを見つけられるように、バッファを置いてから登録する
+func (s *RelayService) startBuffering(msg ReqMessage) *liveBuffer {
+	buf := newLiveBuffer()
+	s.liveMu.Lock()
+	s.buffers[liveKey{msg.ConnectionID, msg.Subscription.ID}] = buf
+	s.liveMu.Unlock()
+
+	_ = s.registry.Unregister(msg.ConnectionID, msg.Subscription.ID)
+	_ = s.registry.Register(msg.ConnectionID, msg.Subscription)
+	return buf
+}
+
+// stopBuffering unregisters a subscription whose stored events could not be sent.
+func (s *RelayService) stopBuffering(key liveKey, buf *liveBuffer) {
+	s.liveMu.Lock()
+	current := s.buffers[key]
+	if current == buf {
+		delete(s.buffers, key)
+	}
+	s.liveMu.Unlock()
+
+	// 同じ ID の新しい REQ に置き換わっている場合は、そちらの登録を残す
+	if current == buf {
+		_ = s.registry.Unregister(key.connID, key.subID)
+	}
+}
+
+// bufferLive buffers evt if the subscription is still sending stored events.
+func (s *RelayService) bufferLive(sub domain.SubscriptionMatch, evt domain.Event) bool {
+	s.liveMu.Lock()
+	buf, ok := s.buffers[liveKey{sub.ConnectionID, sub.SubscriptionID}]
+	s.liveMu.Unlock()
+	return ok && buf.add(evt)
+}
+
 func (s *RelayService) BroadcastToSubscribers(ctx context.Context, evt domain.Event, subs []domain.SubscriptionMatch) error {
 	zap.S().Debugw("BroadcastToSubscribers called", "subscriber_count", len(subs))
 	for _, sub := range subs {
+		if !s.visibleTo(evt, s.AuthedPubKeys(sub.ConnectionID)) {
+			// 非公開の kind（NIP-17 / NIP-59）や非公開のグループのイベントは、認証した接続にのみ送る
+			continue
+		}
+		if s.bufferLive(sub, evt) {
+			// 保存済みイベントを送信中: EOSE の後に送る
+			continue
+		}
 		conn, exists := s.connPool.Get(sub.ConnectionID)
 		if !exists {
 			// 接続が存在しない場合はスキップ（切断済みの場合）
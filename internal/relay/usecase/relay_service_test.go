@@ -3,10 +3,14 @@ package usecase_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"nostar/internal/relay"
 	"nostar/internal/relay/domain"
+	"nostar/internal/relay/policy"
 	"nostar/internal/relay/usecase"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/nbd-wtf/go-nostr"
 )
@@ -33,6 +37,19 @@ func (m *mockEventStore) Query(ctx context.Context, sub domain.Subscription) ([]
 	return nil, nil
 }
 
+func (m *mockEventStore) QueryStream(ctx context.Context, sub domain.Subscription, fn func(domain.Event) error) error {
+	events, err := m.Query(ctx, sub)
+	if err != nil {
+		return err
+	}
+	for _, evt := range events {
+		if err := fn(evt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // createValidTestEvent creates a valid Nostr event for testing
 func createValidTestEvent(content string, kind int) domain.Event {
 	// Generate a test key pair
@@ -172,3 +189,666 @@ func TestRelayService_HandleEvent(t *testing.T) {
 		})
 	}
 }
+
+func TestRelayService_HandleEvent_Policies(t *testing.T) {
+	validEvent := createValidTestEvent("test content", 1)
+
+	tests := []struct {
+		name       string
+		policies   []domain.EventPolicy
+		wantPrefix string // 空の場合はエラーなしを期待する
+		wantSave   bool
+	}{
+		{
+			name:     "no policies",
+			wantSave: true,
+		},
+		{
+			name:     "accepted by all policies",
+			policies: []domain.EventPolicy{policy.NewKindFilter([]int{1}, nil), policy.NewMaxTags(10)},
+			wantSave: true,
+		},
+		{
+			name:       "rejected",
+			policies:   []domain.EventPolicy{policy.NewKindFilter(nil, []int{1})},
+			wantPrefix: domain.ReasonBlocked,
+			wantSave:   false,
+		},
+		{
+			name:     "shadow-rejected looks successful but is not saved",
+			policies: []domain.EventPolicy{policy.NewShadow(policy.NewKindFilter(nil, []int{1}))},
+			wantSave: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &mockEventStore{}
+			s := usecase.NewRelayService(store, domain.NewConnectionPool(), usecase.WithEventPolicies(tt.policies...))
+
+			err := s.HandleEvent(context.Background(), usecase.EventMessage{Event: validEvent})
+			if tt.wantPrefix == "" {
+				if err != nil {
+					t.Fatalf("HandleEvent() failed: %v", err)
+				}
+			} else {
+				var rejectErr *domain.RejectError
+				if !errors.As(err, &rejectErr) || rejectErr.Prefix != tt.wantPrefix {
+					t.Fatalf("HandleEvent() error = %v, want %s: RejectError", err, tt.wantPrefix)
+				}
+			}
+
+			if gotSave := store.saveCalls == 1; gotSave != tt.wantSave {
+				t.Errorf("Save called %d times, wantSave %v", store.saveCalls, tt.wantSave)
+			}
+		})
+	}
+}
+
+// recordingConnection records messages written by BroadcastToSubscribers.
+type recordingConnection struct {
+	id       domain.ConnectionID
+	messages []any
+}
+
+func (c *recordingConnection) ID() domain.ConnectionID { return c.id }
+func (c *recordingConnection) WriteJSON(v interface{}) error {
+	c.messages = append(c.messages, v)
+	return nil
+}
+func (c *recordingConnection) Close() error { return nil }
+
+func TestRelayService_HandleEvent_Broadcast(t *testing.T) {
+	validEvent := createValidTestEvent("test content", 1)
+
+	tests := []struct {
+		name          string
+		saveErr       error
+		wantErr       error
+		wantBroadcast bool
+	}{
+		{
+			name:          "saved events are delivered",
+			wantBroadcast: true,
+		},
+		{
+			name:          "duplicate is not delivered",
+			saveErr:       domain.ErrDuplicate,
+			wantErr:       domain.ErrDuplicate,
+			wantBroadcast: false,
+		},
+		{
+			// モデレーションで非表示の pubkey は、成功を返すが配信しない
+			name:          "hidden is not delivered",
+			saveErr:       domain.ErrHidden,
+			wantBroadcast: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := &mockEventStore{saveFunc: func(ctx context.Context, evt domain.Event) error { return tt.saveErr }}
+			pool := domain.NewConnectionPool()
+			conn := &recordingConnection{id: domain.NewConnectionID()}
+			pool.Add(conn)
+
+			s := usecase.NewRelayService(store, pool)
+			err := s.RegisterSubscription(ctx, usecase.ReqMessage{
+				ConnectionID: conn.id,
+				Subscription: domain.Subscription{ID: "sub", Filters: []domain.Filter{{}}},
+			})
+			if err != nil {
+				t.Fatalf("RegisterSubscription() failed: %v", err)
+			}
+
+			if err := s.HandleEvent(ctx, usecase.EventMessage{Event: validEvent}); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("HandleEvent() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := len(conn.messages) > 0; got != tt.wantBroadcast {
+				t.Errorf("broadcast = %v (%d messages), want %v", got, len(conn.messages), tt.wantBroadcast)
+			}
+		})
+	}
+}
+
+func TestRelayService_HandleReq_QueryLimits(t *testing.T) {
+	limits := domain.QueryLimits{DefaultLimit: 100, MaxLimit: 500, RejectUnbounded: true, Timeout: 50 * time.Millisecond}
+
+	tests := []struct {
+		name       string
+		filter     domain.Filter
+		slow       bool // ストアがタイムアウトまで返らない
+		wantLimit  int
+		wantPrefix string // CLOSED の prefix（空の場合は成功）
+	}{
+		{
+			name:      "default limit is applied",
+			filter:    domain.Filter{Authors: []string{strings.Repeat("a", 64)}},
+			wantLimit: 100,
+		},
+		{
+			name:      "limit is capped",
+			filter:    domain.Filter{Authors: []string{strings.Repeat("a", 64)}, Limit: inttoPtr(10000)},
+			wantLimit: 500,
+		},
+		{
+			name:       "unbounded filter is closed",
+			filter:     domain.Filter{Kinds: []int{1}},
+			wantPrefix: domain.ReasonBlocked,
+		},
+		{
+			name:       "timeout is closed",
+			filter:     domain.Filter{Authors: []string{strings.Repeat("a", 64)}},
+			slow:       true,
+			wantPrefix: domain.ReasonError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var queried []domain.Filter
+			store := &mockEventStore{queryFunc: func(ctx context.Context, sub domain.Subscription) ([]domain.Event, error) {
+				queried = sub.Filters
+				if tt.slow {
+					<-ctx.Done()
+					return nil, ctx.Err()
+				}
+				return nil, nil
+			}}
+			s := usecase.NewRelayService(store, domain.NewConnectionPool(), usecase.WithQueryLimits(limits))
+
+			sub := domain.Subscription{ID: "sub", Filters: []domain.Filter{tt.filter}}
+			err := s.HandleReq(context.Background(), usecase.ReqMessage{Subscription: sub}, func(domain.Event) error { return nil })
+
+			if tt.wantPrefix != "" {
+				var rejectErr *domain.RejectError
+				if !errors.As(err, &rejectErr) || rejectErr.Prefix != tt.wantPrefix {
+					t.Fatalf("HandleReq() error = %v, want %s", err, tt.wantPrefix)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("HandleReq() failed: %v", err)
+			}
+			if len(queried) != 1 || queried[0].Limit == nil || *queried[0].Limit != tt.wantLimit {
+				t.Fatalf("queried filters = %+v, want limit %d", queried, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestRelayService_HandleReq_Stream(t *testing.T) {
+	events := []domain.Event{
+		createValidTestEvent("first", 1),
+		createValidTestEvent("second", 1),
+		createValidTestEvent("third", 1),
+	}
+	errStop := errors.New("stop")
+
+	tests := []struct {
+		name     string
+		stopAt   int  // この件数を送ったところで send がエラーを返す（0 の場合は返さない）
+		cancel   bool // 1件目を送った後に ctx をキャンセルする
+		wantSent int
+		wantErr  error
+	}{
+		{
+			name:     "all events are sent in order",
+			wantSent: 3,
+		},
+		{
+			name:     "send error stops the stream",
+			stopAt:   2,
+			wantSent: 2,
+			wantErr:  errStop,
+		},
+		{
+			name:     "cancel stops the stream",
+			cancel:   true,
+			wantSent: 1,
+			wantErr:  context.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &sliceStreamStore{events: events}
+			s := usecase.NewRelayService(store, domain.NewConnectionPool(), usecase.WithQueryLimits(domain.QueryLimits{Timeout: time.Second}))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var sent []domain.Event
+			sub := domain.Subscription{ID: "sub", Filters: []domain.Filter{{Kinds: []int{1}}}}
+			err := s.HandleReq(ctx, usecase.ReqMessage{Subscription: sub}, func(evt domain.Event) error {
+				sent = append(sent, evt)
+				if tt.cancel {
+					cancel()
+				}
+				if len(sent) == tt.stopAt {
+					return errStop
+				}
+				return nil
+			})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("HandleReq() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(sent) != tt.wantSent {
+				t.Fatalf("sent %d events, want %d", len(sent), tt.wantSent)
+			}
+			for i, evt := range sent {
+				if evt.ID != events[i].ID {
+					t.Errorf("sent[%d] = %s, want %s", i, evt.ID, events[i].ID)
+				}
+			}
+		})
+	}
+}
+
+// sliceStreamStore は QueryStream で events を順に渡し、ctx の終了で中断する
+type sliceStreamStore struct {
+	mockEventStore
+	events []domain.Event
+}
+
+func (m *sliceStreamStore) QueryStream(ctx context.Context, sub domain.Subscription, fn func(domain.Event) error) error {
+	for _, evt := range m.events {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(evt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestRelayService_HandleReq_BuffersLiveEvents(t *testing.T) {
+	ctx := context.Background()
+	stored := createValidTestEvent("stored", 1)
+	both := createValidTestEvent("stored and live", 1) // 検索結果にもライブにも現れる
+	during := createValidTestEvent("during query", 1)
+	after := createValidTestEvent("after EOSE", 1)
+
+	pool := domain.NewConnectionPool()
+	conn := &recordingConnection{id: domain.NewConnectionID()}
+	pool.Add(conn)
+
+	var s *usecase.RelayService
+	store := &mockEventStore{queryFunc: func(ctx context.Context, sub domain.Subscription) ([]domain.Event, error) {
+		// 検索中に保存・配信されたイベント
+		for _, evt := range []domain.Event{both, during} {
+			if err := s.HandleEvent(ctx, usecase.EventMessage{Event: evt}); err != nil {
+				t.Fatalf("HandleEvent() failed: %v", err)
+			}
+		}
+		return []domain.Event{stored, both}, nil
+	}}
+	s = usecase.NewRelayService(store, pool)
+
+	msg := usecase.ReqMessage{
+		ConnectionID: conn.id,
+		Subscription: domain.Subscription{ID: "sub", Filters: []domain.Filter{{}}},
+	}
+	var sent []string
+	err := s.HandleReq(ctx, msg, func(evt domain.Event) error {
+		sent = append(sent, evt.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("HandleReq() failed: %v", err)
+	}
+	if len(conn.messages) != 0 {
+		t.Fatalf("live events were sent before EOSE: %v", conn.messages)
+	}
+	if len(sent) != 2 {
+		t.Fatalf("sent %d stored events, want 2", len(sent))
+	}
+
+	if err := s.RegisterSubscription(ctx, msg); err != nil {
+		t.Fatalf("RegisterSubscription() failed: %v", err)
+	}
+	if err := s.HandleEvent(ctx, usecase.EventMessage{Event: after}); err != nil {
+		t.Fatalf("HandleEvent() failed: %v", err)
+	}
+
+	// 検索結果と重複するイベントは除き、溜めたイベントの後にライブのイベントが届く
+	want := []string{during.ID, after.ID}
+	if len(conn.messages) != len(want) {
+		t.Fatalf("live messages = %v, want %d events", conn.messages, len(want))
+	}
+	for i, m := range conn.messages {
+		evt := m.([]any)[2].(domain.Event)
+		if evt.ID != want[i] {
+			t.Errorf("live[%d] = %s, want %s", i, evt.ID, want[i])
+		}
+	}
+}
+
+// signTestEvent signs an event with the given key, kind and tags.
+func signTestEvent(t *testing.T, sk string, kind int, tags nostr.Tags) domain.Event {
+	t.Helper()
+	evt := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      kind,
+		Tags:      tags,
+	}
+	if err := evt.Sign(sk); err != nil {
+		t.Fatal(err)
+	}
+	domainTags := make([][]string, 0, len(evt.Tags))
+	for _, tag := range evt.Tags {
+		domainTags = append(domainTags, tag)
+	}
+	return domain.Event{
+		ID:        evt.ID,
+		PubKey:    evt.PubKey,
+		Signature: evt.Sig,
+		CreatedAt: int64(evt.CreatedAt),
+		Kind:      evt.Kind,
+		Tags:      domainTags,
+	}
+}
+
+func TestRelayService_HandleAuth(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	const relayURL = "wss://relay.example.com"
+
+	tests := []struct {
+		name       string
+		challenge  string
+		wantAuthed bool
+	}{
+		{name: "valid", challenge: "c1", wantAuthed: true},
+		{name: "challenge mismatch", challenge: "other", wantAuthed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := usecase.NewRelayService(&mockEventStore{}, domain.NewConnectionPool(), usecase.WithRelayURL(relayURL))
+			connID := domain.NewConnectionID()
+			evt := signTestEvent(t, sk, domain.KindClientAuth, nostr.Tags{{"relay", relayURL}, {"challenge", "c1"}})
+
+			err := s.HandleAuth(context.Background(), usecase.AuthMessage{ConnectionID: connID, Event: evt, Challenge: tt.challenge})
+			if tt.wantAuthed && err != nil {
+				t.Fatalf("HandleAuth() failed: %v", err)
+			}
+			var rejectErr *domain.RejectError
+			if !tt.wantAuthed && (!errors.As(err, &rejectErr) || rejectErr.Prefix != domain.ReasonInvalid) {
+				t.Fatalf("HandleAuth() error = %v, want %s", err, domain.ReasonInvalid)
+			}
+			if got := s.AuthedPubKeys(connID).Contains(pk); got != tt.wantAuthed {
+				t.Errorf("authed = %v, want %v", got, tt.wantAuthed)
+			}
+
+			s.ClearAuth(connID)
+			if s.AuthedPubKeys(connID).Contains(pk) {
+				t.Error("auth is kept after ClearAuth")
+			}
+		})
+	}
+}
+
+func TestRelayService_PrivateMessages(t *testing.T) {
+	ctx := context.Background()
+	const relayURL = "wss://relay.example.com"
+	recipientSK := nostr.GeneratePrivateKey()
+	recipient, _ := nostr.GetPublicKey(recipientSK)
+	other, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+
+	wrapSK := nostr.GeneratePrivateKey()
+	toRecipient := signTestEvent(t, wrapSK, domain.KindGiftWrap, nostr.Tags{{"p", recipient}})
+	toOther := signTestEvent(t, wrapSK, domain.KindGiftWrap, nostr.Tags{{"p", other}})
+
+	pool := domain.NewConnectionPool()
+	conn := &recordingConnection{id: domain.NewConnectionID()}
+	pool.Add(conn)
+	store := &mockEventStore{queryFunc: func(ctx context.Context, sub domain.Subscription) ([]domain.Event, error) {
+		return []domain.Event{toRecipient, toOther}, nil
+	}}
+	s := usecase.NewRelayService(store, pool, usecase.WithRelayURL(relayURL))
+
+	msg := usecase.ReqMessage{
+		ConnectionID: conn.id,
+		Subscription: domain.Subscription{ID: "sub", Filters: []domain.Filter{{Kinds: []int{domain.KindGiftWrap}}}},
+	}
+	send := func(got *[]string) func(domain.Event) error {
+		return func(evt domain.Event) error {
+			*got = append(*got, evt.ID)
+			return nil
+		}
+	}
+
+	// 認証前は auth-required で閉じる
+	var sent []string
+	err := s.HandleReq(ctx, msg, send(&sent))
+	var rejectErr *domain.RejectError
+	if !errors.As(err, &rejectErr) || rejectErr.Prefix != domain.ReasonAuthRequired {
+		t.Fatalf("HandleReq() error = %v, want %s", err, domain.ReasonAuthRequired)
+	}
+
+	authEvt := signTestEvent(t, recipientSK, domain.KindClientAuth, nostr.Tags{{"relay", relayURL}, {"challenge", "c1"}})
+	if err := s.HandleAuth(ctx, usecase.AuthMessage{ConnectionID: conn.id, Event: authEvt, Challenge: "c1"}); err != nil {
+		t.Fatalf("HandleAuth() failed: %v", err)
+	}
+
+	// 保存済みのイベントは宛先の分だけ届く
+	sent = nil
+	if err := s.HandleReq(ctx, msg, send(&sent)); err != nil {
+		t.Fatalf("HandleReq() failed: %v", err)
+	}
+	if len(sent) != 1 || sent[0] != toRecipient.ID {
+		t.Fatalf("sent = %v, want [%s]", sent, toRecipient.ID)
+	}
+	if err := s.RegisterSubscription(ctx, msg); err != nil {
+		t.Fatalf("RegisterSubscription() failed: %v", err)
+	}
+
+	// ライブのイベントも宛先の分だけ届く
+	liveToRecipient := signTestEvent(t, wrapSK, domain.KindGiftWrap, nostr.Tags{{"p", recipient}})
+	liveToOther := signTestEvent(t, wrapSK, domain.KindGiftWrap, nostr.Tags{{"p", other}})
+	for _, evt := range []domain.Event{liveToOther, liveToRecipient} {
+		if err := s.HandleEvent(ctx, usecase.EventMessage{Event: evt}); err != nil {
+			t.Fatalf("HandleEvent() failed: %v", err)
+		}
+	}
+	if len(conn.messages) != 1 || conn.messages[0].([]any)[2].(domain.Event).ID != liveToRecipient.ID {
+		t.Fatalf("live messages = %v, want only %s", conn.messages, liveToRecipient.ID)
+	}
+
+	// AUTH イベントは EVENT として受け付けない
+	if err := s.HandleEvent(ctx, usecase.EventMessage{Event: authEvt}); !errors.As(err, &rejectErr) {
+		t.Fatalf("HandleEvent(kind %d) error = %v, want a rejection", domain.KindClientAuth, err)
+	}
+}
+
+func TestRelayService_PrivateMessages_Limit(t *testing.T) {
+	// 他人宛ての新しい DM で limit が埋まっても、自分宛ての DM が届く
+	ctx := context.Background()
+	const relayURL = "wss://relay.example.com"
+	recipientSK := nostr.GeneratePrivateKey()
+	recipient, _ := nostr.GetPublicKey(recipientSK)
+
+	events := []domain.Event{{ID: "mine", PubKey: "sender", CreatedAt: 1000, Kind: domain.KindGiftWrap, Tags: [][]string{{"p", recipient}}}}
+	for i := 0; i < 5; i++ {
+		events = append(events, domain.Event{ID: fmt.Sprintf("other%d", i), PubKey: "sender", CreatedAt: int64(2000 + i), Kind: domain.KindGiftWrap, Tags: [][]string{{"p", "other"}}})
+	}
+	s := usecase.NewRelayService(&sliceEventStore{events: events}, domain.NewConnectionPool(), usecase.WithRelayURL(relayURL))
+
+	connID := domain.NewConnectionID()
+	authEvt := signTestEvent(t, recipientSK, domain.KindClientAuth, nostr.Tags{{"relay", relayURL}, {"challenge", "c1"}})
+	if err := s.HandleAuth(ctx, usecase.AuthMessage{ConnectionID: connID, Event: authEvt, Challenge: "c1"}); err != nil {
+		t.Fatalf("HandleAuth() failed: %v", err)
+	}
+
+	var sent []string
+	msg := usecase.ReqMessage{
+		ConnectionID: connID,
+		Subscription: domain.Subscription{ID: "sub", Filters: []domain.Filter{{Kinds: []int{domain.KindGiftWrap}, Limit: inttoPtr(1)}}},
+	}
+	err := s.HandleReq(ctx, msg, func(evt domain.Event) error {
+		sent = append(sent, evt.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("HandleReq() failed: %v", err)
+	}
+	if len(sent) != 1 || sent[0] != "mine" {
+		t.Errorf("sent = %v, want [mine]", sent)
+	}
+}
+
+func TestRelayService_HandleEvent_Protected(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	author, _ := nostr.GetPublicKey(sk)
+	other, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	protected := signTestEvent(t, sk, 1, nostr.Tags{{"-"}})
+
+	tests := []struct {
+		name           string
+		authedPubKey   string
+		allowProtected bool
+		wantPrefix     string // 拒否の prefix（空の場合は受け付ける）
+	}{
+		{name: "unauthenticated", wantPrefix: domain.ReasonAuthRequired},
+		{name: "authenticated as another pubkey", authedPubKey: other, wantPrefix: domain.ReasonRestricted},
+		{name: "authenticated as the author", authedPubKey: author},
+		{name: "allowed by the administrator", allowProtected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &mockEventStore{}
+			s := usecase.NewRelayService(store, domain.NewConnectionPool())
+
+			err := s.HandleEvent(context.Background(), usecase.EventMessage{
+				Event:          protected,
+				AuthedPubKey:   tt.authedPubKey,
+				AllowProtected: tt.allowProtected,
+			})
+			if tt.wantPrefix == "" {
+				if err != nil {
+					t.Fatalf("HandleEvent() failed: %v", err)
+				}
+				if store.saveCalls != 1 {
+					t.Errorf("Save called %d times, want 1", store.saveCalls)
+				}
+				return
+			}
+			var rejectErr *domain.RejectError
+			if !errors.As(err, &rejectErr) || rejectErr.Prefix != tt.wantPrefix {
+				t.Fatalf("HandleEvent() error = %v, want %s", err, tt.wantPrefix)
+			}
+			if store.saveCalls != 0 {
+				t.Errorf("Save called %d times, want 0", store.saveCalls)
+			}
+		})
+	}
+}
+
+func TestRelayService_HandleEvent_Vanish(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+
+	tests := []struct {
+		name      string
+		relayURL  string // WithRelayURL
+		connURL   string // EventMessage.RelayURL
+		relayTag  string
+		wantSaved bool
+	}{
+		{name: "this relay", relayURL: "wss://relay.example.com", relayTag: "wss://relay.example.com/", wantSaved: true},
+		{name: "connected URL", connURL: "ws://relay.example.com", relayTag: "wss://relay.example.com", wantSaved: true},
+		{name: "all relays", relayTag: domain.AllRelays, wantSaved: true},
+		{name: "another relay", relayURL: "wss://relay.example.com", connURL: "wss://other.example.com", relayTag: "wss://other.example.com", wantSaved: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &mockEventStore{}
+			s := usecase.NewRelayService(store, domain.NewConnectionPool(), usecase.WithRelayURL(tt.relayURL))
+			evt := signTestEvent(t, sk, domain.KindVanish, nostr.Tags{{"relay", tt.relayTag}})
+
+			err := s.HandleEvent(context.Background(), usecase.EventMessage{Event: evt, RelayURL: tt.connURL})
+			if tt.wantSaved {
+				if err != nil || store.saveCalls != 1 {
+					t.Fatalf("HandleEvent() error = %v, Save called %d times, want saved", err, store.saveCalls)
+				}
+				return
+			}
+			var rejectErr *domain.RejectError
+			if !errors.As(err, &rejectErr) || rejectErr.Prefix != domain.ReasonInvalid {
+				t.Fatalf("HandleEvent() error = %v, want %s", err, domain.ReasonInvalid)
+			}
+			if store.saveCalls != 0 {
+				t.Errorf("Save called %d times, want 0", store.saveCalls)
+			}
+		})
+	}
+}
+
+func TestRelayService_Publish(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("signed with the relay key and delivered", func(t *testing.T) {
+		store := &mockEventStore{}
+		pool := domain.NewConnectionPool()
+		conn := &recordingConnection{id: domain.NewConnectionID()}
+		pool.Add(conn)
+		signer := newTestSigner()
+		s := usecase.NewRelayService(store, pool, usecase.WithSigner(signer))
+		if got := s.RelayPubKey(); got != signer.PubKey() {
+			t.Errorf("RelayPubKey() = %q, want %q", got, signer.PubKey())
+		}
+		err := s.RegisterSubscription(ctx, usecase.ReqMessage{
+			ConnectionID: conn.id,
+			Subscription: domain.Subscription{ID: "sub", Filters: []domain.Filter{{}}},
+		})
+		if err != nil {
+			t.Fatalf("RegisterSubscription() failed: %v", err)
+		}
+
+		// protected イベントもリレー自身として発行できる
+		evt, err := s.Publish(ctx, domain.Event{Kind: 1, Content: "announcement", Tags: [][]string{{"-"}}})
+		if err != nil {
+			t.Fatalf("Publish() failed: %v", err)
+		}
+		if evt.PubKey != signer.PubKey() || evt.CreatedAt == 0 {
+			t.Errorf("Publish() = pubkey %q, created_at %d, want the relay pubkey and the current time", evt.PubKey, evt.CreatedAt)
+		}
+		if ok, err := evt.CheckSignature(); !ok || err != nil {
+			t.Errorf("CheckSignature() = %v, %v, want a valid signature", ok, err)
+		}
+		if store.saveCalls != 1 {
+			t.Errorf("Save called %d times, want 1", store.saveCalls)
+		}
+		if len(conn.messages) != 1 {
+			t.Errorf("broadcast %d messages, want 1", len(conn.messages))
+		}
+	})
+
+	t.Run("shadow-rejected by a policy", func(t *testing.T) {
+		// 保存されていないのに成功を返すと、呼び出し元が状態を保存したと誤解する
+		store := &mockEventStore{}
+		s := usecase.NewRelayService(store, domain.NewConnectionPool(),
+			usecase.WithSigner(newTestSigner()),
+			usecase.WithEventPolicies(policy.NewShadow(policy.NewKindFilter(nil, []int{1}))),
+		)
+		_, err := s.Publish(ctx, domain.Event{Kind: 1})
+		var rejectErr *domain.RejectError
+		if !errors.As(err, &rejectErr) {
+			t.Errorf("Publish() error = %v, want a RejectError", err)
+		}
+		if store.saveCalls != 0 {
+			t.Errorf("Save called %d times, want 0", store.saveCalls)
+		}
+	})
+
+	t.Run("without the relay key", func(t *testing.T) {
+		store := &mockEventStore{}
+		s := usecase.NewRelayService(store, domain.NewConnectionPool())
+		if got := s.RelayPubKey(); got != "" {
+			t.Errorf("RelayPubKey() = %q, want empty", got)
+		}
+		if _, err := s.Publish(ctx, domain.Event{Kind: 1}); err == nil {
+			t.Error("Publish() succeeded without the relay key")
+		}
+		if store.saveCalls != 0 {
+			t.Errorf("Save called %d times, want 0", store.saveCalls)
+		}
+	})
+}
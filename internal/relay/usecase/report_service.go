@@ -0,0 +1,93 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"nostar/internal/relay"
+	"nostar/internal/relay/domain"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultReportQueueLimit はモデレーションキューを表示する件数のデフォルト
+	defaultReportQueueLimit = 50
+	// reportAutoHideActor は自動で非表示にしたときに監査ログに記録する操作者
+	reportAutoHideActor = "auto:reports"
+)
+
+// ReportService builds the moderation queue from NIP-56 reports and optionally auto-hides reported targets.
+type ReportService struct {
+	store      relay.ReportStore
+	moderation *ModerationService
+	trusted    []string // 信頼できる報告者（hex, ソート済み）
+	threshold  int      // 自動で非表示にする信頼できる報告者の数（0 は無効）
+}
+
+func NewReportService(store relay.ReportStore, moderation *ModerationService, trusted domain.PubKeySet, autoHideThreshold int) *ReportService {
+	pubkeys := make([]string, 0, len(trusted))
+	for pk := range trusted {
+		pubkeys = append(pubkeys, pk)
+	}
+	sort.Strings(pubkeys)
+
+	return &ReportService{
+		store:      store,
+		moderation: moderation,
+		trusted:    pubkeys,
+		threshold:  autoHideThreshold,
+	}
+}
+
+// Queue returns the reported events / pubkeys, most reported by trusted reporters first.
+// includeHidden が false の場合、既に非表示にした対象は含めない
+func (s *ReportService) Queue(ctx context.Context, includeHidden bool, limit int) ([]domain.ReportQueueItem, error) {
+	if limit <= 0 {
+		limit = defaultReportQueueLimit
+	}
+	return s.store.ReportQueue(ctx, domain.ReportQueueOptions{
+		TrustedReporters: s.trusted,
+		IncludeHidden:    includeHidden,
+		Limit:            limit,
+	})
+}
+
+// HandleReport hides the targets of a stored report once the number of trusted reporters reaches the threshold.
+// 同時に届いた報告や trusted_reporters の変更で閾値を飛び越えても非表示にする
+// 既に非表示にした・管理者が再表示した（監査ログに記録がある）対象は、後続の報告で再び非表示にしない
+func (s *ReportService) HandleReport(ctx context.Context, evt domain.Event) error {
+	if s.threshold <= 0 || !s.isTrusted(evt.PubKey) {
+		return nil
+	}
+
+	for _, r := range evt.Reports() {
+		n, err := s.store.CountReporters(ctx, r.Target, r.Value, s.trusted)
+		if err != nil {
+			return err
+		}
+		if n < s.threshold {
+			continue
+		}
+		moderated, err := s.moderation.Moderated(ctx, r.Target, r.Value)
+		if err != nil {
+			return err
+		}
+		if moderated {
+			continue
+		}
+
+		reason := fmt.Sprintf("reported by %d trusted reporters", n)
+		if _, err := s.moderation.Hide(ctx, string(r.Target), r.Value, reason, reportAutoHideActor); err != nil {
+			return err
+		}
+		zap.S().Infow("auto-hidden by reports", "target", r.Target, "value", r.Value, "reporters", n)
+	}
+	return nil
+}
+
+func (s *ReportService) isTrusted(pubkey string) bool {
+	i := sort.SearchStrings(s.trusted, pubkey)
+	return i < len(s.trusted) && s.trusted[i] == pubkey
+}
@@ -0,0 +1,121 @@
+package usecase_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"nostar/internal/relay/domain"
+	"nostar/internal/relay/usecase"
+)
+
+// sliceReportStore is an in-memory ReportStore fed with the reports of saved events.
+type sliceReportStore struct {
+	reports   []domain.Report
+	queueOpts domain.ReportQueueOptions
+}
+
+func (m *sliceReportStore) ReportQueue(ctx context.Context, opts domain.ReportQueueOptions) ([]domain.ReportQueueItem, error) {
+	m.queueOpts = opts
+	return nil, nil
+}
+
+func (m *sliceReportStore) CountReporters(ctx context.Context, target domain.HideTarget, value string, reporters []string) (int, error) {
+	set := domain.NewPubKeySet(reporters)
+	seen := map[string]bool{}
+	for _, r := range m.reports {
+		if r.Target == target && r.Value == value && set.Contains(r.Reporter) {
+			seen[r.Reporter] = true
+		}
+	}
+	return len(seen), nil
+}
+
+func TestReportService_HandleReport(t *testing.T) {
+	trusted1 := "1111111111111111111111111111111111111111111111111111111111111111"
+	trusted2 := "2222222222222222222222222222222222222222222222222222222222222222"
+	trusted3 := "3333333333333333333333333333333333333333333333333333333333333333"
+	untrusted := "4444444444444444444444444444444444444444444444444444444444444444"
+	target := "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+
+	report := func(reporter string) domain.Event {
+		return domain.Event{
+			ID:     reporter, // テストでは一意であればよい
+			PubKey: reporter,
+			Kind:   domain.KindReport,
+			Tags:   [][]string{{"p", target, "spam"}},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		threshold int
+		indexed   []string // HandleReport を通さずに索引付けされた報告（import など）
+		unhidden  bool     // 管理者が再表示済み
+		reporters []string
+		wantHides int
+	}{
+		{name: "hidden when the threshold is reached", threshold: 2, reporters: []string{trusted1, trusted2}, wantHides: 1},
+		{name: "untrusted reporters are not counted", threshold: 2, reporters: []string{trusted1, untrusted}, wantHides: 0},
+		{name: "same reporter is counted once", threshold: 2, reporters: []string{trusted1, trusted1}, wantHides: 0},
+		// 非表示にした後の報告では再び非表示にしない
+		{name: "hidden only once", threshold: 2, reporters: []string{trusted1, trusted2, trusted3}, wantHides: 1},
+		{name: "disabled", threshold: 0, reporters: []string{trusted1, trusted2, trusted3}, wantHides: 0},
+		// import した報告などで閾値を飛び越えても非表示にする
+		{name: "hidden when the threshold is passed", threshold: 2, indexed: []string{trusted1, trusted2}, reporters: []string{trusted3}, wantHides: 1},
+		{name: "not hidden again after unhide", threshold: 2, unhidden: true, reporters: []string{trusted1, trusted2, trusted3}, wantHides: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &sliceReportStore{}
+			modStore := &recordingModerationStore{}
+			if tt.unhidden {
+				modStore.unhides = []string{target}
+			}
+			svc := usecase.NewReportService(store, usecase.NewModerationService(modStore),
+				domain.NewPubKeySet([]string{trusted1, trusted2, trusted3}), tt.threshold)
+			for _, reporter := range tt.indexed {
+				evt := report(reporter)
+				store.reports = append(store.reports, evt.Reports()...)
+			}
+
+			for _, reporter := range tt.reporters {
+				evt := report(reporter)
+				store.reports = append(store.reports, evt.Reports()...) // EventStore.Save による索引付け
+				if err := svc.HandleReport(context.Background(), evt); err != nil {
+					t.Fatalf("HandleReport() failed: %v", err)
+				}
+			}
+
+			if len(modStore.hides) != tt.wantHides {
+				t.Fatalf("hides = %+v, want %d", modStore.hides, tt.wantHides)
+			}
+			for _, h := range modStore.hides {
+				if h.Target != domain.HidePubKey || h.Value != target || h.Actor != "auto:reports" {
+					t.Errorf("hide = %+v, want pubkey %s by auto:reports", h, target)
+				}
+			}
+		})
+	}
+}
+
+func TestReportService_Queue(t *testing.T) {
+	trusted := []string{
+		"2222222222222222222222222222222222222222222222222222222222222222",
+		"1111111111111111111111111111111111111111111111111111111111111111",
+	}
+	store := &sliceReportStore{}
+	svc := usecase.NewReportService(store, nil, domain.NewPubKeySet(trusted), 0)
+
+	if _, err := svc.Queue(context.Background(), true, 0); err != nil {
+		t.Fatalf("Queue() failed: %v", err)
+	}
+	want := domain.ReportQueueOptions{
+		TrustedReporters: []string{trusted[1], trusted[0]},
+		IncludeHidden:    true,
+		Limit:            50,
+	}
+	if !reflect.DeepEqual(store.queueOpts, want) {
+		t.Errorf("ReportQueue() options = %+v, want %+v", store.queueOpts, want)
+	}
+}
@@ -0,0 +1,259 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"nostar/internal/relay"
+	"nostar/internal/relay/domain"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultSyncPageSize は upstream に1回の REQ で要求する件数
+	defaultSyncPageSize = 500
+	// maxSyncSecondLimit は、1秒に1ページを超えるイベントがある場合に、その秒を取り直す REQ の limit の上限
+	maxSyncSecondLimit = 100000
+)
+
+// SyncService backfills this relay from other relays.
+// 取得したイベントは RelayService.HandleEvent を通すので、署名検証とポリシーが適用される
+type SyncService struct {
+	relay       *RelayService
+	dial        relay.UpstreamDialer
+	checkpoints relay.SyncCheckpointStore
+}
+
+func NewSyncService(relaySvc *RelayService, dial relay.UpstreamDialer, checkpoints relay.SyncCheckpointStore) *SyncService {
+	return &SyncService{
+		relay:       relaySvc,
+		dial:        dial,
+		checkpoints: checkpoints,
+	}
+}
+
+// SyncOptions controls Sync.
+type SyncOptions struct {
+	PageSize       int             // 1回の REQ の limit
+	Full           bool            // チェックポイントを無視して最初から同期する
+	AllowProtected bool            // NIP-70 の protected イベントも取り込む（既定では作者の認証がないため拒否する）
+	Progress       func(SyncStats) // ページごとに呼ばれる（nil 可）
+}
+
+// SyncStats is the running result of Sync.
+type SyncStats struct {
+	Fetched    int   // upstream から受け取ったイベント数
+	Stored     int   // 新たに保存したイベント数
+	Duplicates int   // 既に持っていたイベント数
+	Rejected   int   // 検証・ポリシーで拒否したイベント数
+	Newest     int64 // 保存した（または既に持っていた）中で最も新しい created_at。現在時刻を超えない
+}
+
+// Sync fetches events matching filter from the upstream relay, newest first, paginating with until.
+// 前回の同期が完了していれば、そのチェックポイント（最新の created_at）以降だけを取得する
+// チェックポイントは全ページを取り終えた場合にのみ更新する
+func (s *SyncService) Sync(ctx context.Context, url string, filter domain.Filter, opts SyncOptions) (SyncStats, error) {
+	var stats SyncStats
+
+	pageSize := opts.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultSyncPageSize
+	}
+
+	filterKey, err := syncFilterKey(filter)
+	if err != nil {
+		return stats, err
+	}
+
+	since := filter.Since
+	if !opts.Full {
+		checkpoint, err := s.checkpoints.GetCheckpoint(ctx, url, filterKey)
+		switch {
+		case err == nil:
+			// 同時刻のイベントを取りこぼさないよう、チェックポイント自体も含める
+			if since == nil || checkpoint > *since {
+				since = &checkpoint
+			}
+			stats.Newest = checkpoint
+		case errors.Is(err, domain.ErrNotFound):
+		default:
+			return stats, err
+		}
+	}
+
+	up, err := s.dial(ctx, url)
+	if err != nil {
+		return stats, fmt.Errorf("failed to connect to %s: %w", url, err)
+	}
+	defer up.Close()
+
+	until := filter.Until
+	seen := make(map[string]struct{}) // ページ境界（until と同時刻）で既に処理したイベント
+	maxPage := 0                      // これまでのページの最大件数（upstream が limit を丸める場合はその上限）
+	for {
+		f := filter
+		f.Since = since
+		f.Until = until
+		f.Limit = &pageSize
+
+		events, err := up.Query(ctx, f)
+		if err != nil {
+			return stats, fmt.Errorf("failed to query %s: %w", url, err)
+		}
+		if len(events) == 0 {
+			break
+		}
+
+		oldest := events[0].CreatedAt
+		for _, evt := range events {
+			oldest = min(oldest, evt.CreatedAt)
+		}
+		for _, evt := range events {
+			if _, ok := seen[evt.ID]; ok {
+				continue
+			}
+			if err := s.store(ctx, url, evt, opts, &stats); err != nil {
+				return stats, err
+			}
+		}
+		if until == nil || oldest < *until {
+			clear(seen)
+		}
+		for _, evt := range events {
+			if evt.CreatedAt == oldest {
+				seen[evt.ID] = struct{}{}
+			}
+		}
+		if opts.Progress != nil {
+			opts.Progress(stats)
+		}
+
+		// 次のページは oldest 以前。同時刻のイベントは重複して返ってくるが、seen で読み飛ばす
+		// limit を upstream 側で小さく丸められても取りこぼさないよう、空ページが返るまで続ける
+		next := oldest
+		if until != nil && next >= *until {
+			// ページがすべて until と同時刻で、件数が上限に達している場合は、その秒にまだ残りがある
+			if len(events) >= pageSize || (maxPage > 0 && len(events) >= maxPage) {
+				if err := s.syncSecond(ctx, up, url, filter, since, *until, len(events), seen, opts, &stats); err != nil {
+					return stats, err
+				}
+			}
+			next = *until - 1
+		}
+		maxPage = max(maxPage, len(events))
+		if next <= 0 || (since != nil && next < *since) {
+			break
+		}
+		until = &next
+	}
+
+	if stats.Newest > 0 {
+		if err := s.checkpoints.SaveCheckpoint(ctx, url, filterKey, stats.Newest); err != nil {
+			return stats, fmt.Errorf("failed to save checkpoint: %w", err)
+		}
+	}
+	return stats, nil
+}
+
+// syncSecond は1秒に1ページを超えるイベントがある場合に、limit を増やして until = t で取り直し、その秒の残りを保存する
+// NIP-01 には同時刻のイベントをページングする方法がないため、upstream が limit を丸めて取り切れない場合はエラーにする
+// （チェックポイントを更新せず、取りこぼしを黙って残さない）
+func (s *SyncService) syncSecond(ctx context.Context, up relay.Upstream, url string, filter domain.Filter, since *int64, t int64, got int, seen map[string]struct{}, opts SyncOptions, stats *SyncStats) error {
+	f := filter
+	f.Since, f.Until = since, &t
+	for limit := got * 2; limit <= maxSyncSecondLimit; limit *= 2 {
+		zap.S().Infow("sync refetching a second with more events than a page", "url", url, "created_at", t, "limit", limit)
+		f.Limit = &limit
+		events, err := up.Query(ctx, f)
+		if err != nil {
+			return fmt.Errorf("failed to query %s: %w", url, err)
+		}
+		older := false // t より古いイベントが含まれていれば、t のイベントはすべて返っている
+		for _, evt := range events {
+			if evt.CreatedAt < t {
+				older = true
+				continue
+			}
+			if _, ok := seen[evt.ID]; ok {
+				continue
+			}
+			seen[evt.ID] = struct{}{}
+			if err := s.store(ctx, url, evt, opts, stats); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case older || (len(events) < limit && len(events) > got):
+			return nil
+		case len(events) <= got:
+			// limit を増やしても件数が増えない。より古いイベントがあれば、upstream が limit を丸めている
+			next := t - 1
+			one := 1
+			probe := filter
+			probe.Since, probe.Until, probe.Limit = since, &next, &one
+			rest, err := up.Query(ctx, probe)
+			if err != nil {
+				return fmt.Errorf("failed to query %s: %w", url, err)
+			}
+			if len(rest) > 0 {
+				return fmt.Errorf("%s returns at most %d events per REQ, but has more at created_at %d; narrow the filter (e.g. by authors or kinds)", url, len(events), t)
+			}
+			return nil
+		}
+		got = len(events)
+	}
+	return fmt.Errorf("%s has more than %d events at created_at %d; narrow the filter (e.g. by authors or kinds)", url, maxSyncSecondLimit, t)
+}
+
+func (s *SyncService) store(ctx context.Context, url string, evt domain.Event, opts SyncOptions, stats *SyncStats) error {
+	stats.Fetched++
+
+	err := s.relay.HandleEvent(ctx, EventMessage{
+		Event:          evt,
+		SourceType:     domain.SourceSync,
+		SourceInfo:     url,
+		AllowProtected: opts.AllowProtected,
+	})
+
+	var rejectErr *domain.RejectError
+	switch {
+	case err == nil:
+		stats.Stored++
+	case errors.Is(err, domain.ErrDuplicate):
+		stats.Duplicates++
+	case errors.As(err, &rejectErr):
+		zap.S().Debugw("sync event rejected", "url", url, "event_id", evt.ID, "reason", rejectErr.Error())
+		stats.Rejected++
+		// チェックポイントは次回の since になるため、拒否したイベント（未来の created_at など）では進めない
+		return nil
+	default:
+		return fmt.Errorf("failed to store event %s: %w", evt.ID, err)
+	}
+	stats.Newest = max(stats.Newest, min(evt.CreatedAt, time.Now().Unix()))
+	return nil
+}
+
+// syncFilterKey は、チェックポイントを区別するためのフィルタの正規化表現
+// since / until / limit はページングで変わるため含めない
+func syncFilterKey(filter domain.Filter) (string, error) {
+	b, err := json.Marshal(struct {
+		IDs     []string            `json:"ids,omitempty"`
+		Authors []string            `json:"authors,omitempty"`
+		Kinds   []int               `json:"kinds,omitempty"`
+		Tags    map[string][]string `json:"tags,omitempty"`
+	}{
+		IDs:     filter.IDs,
+		Authors: filter.Authors,
+		Kinds:   filter.Kinds,
+		Tags:    filter.Tags,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal filter: %w", err)
+	}
+	return string(b), nil
+}
@@ -0,0 +1,348 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"nostar/internal/relay"
+	"nostar/internal/relay/domain"
+	"nostar/internal/relay/policy"
+	"nostar/internal/relay/usecase"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// fakeUpstream はフィルタ・並び順・limit を upstream relay と同様に扱う
+type fakeUpstream struct {
+	events   []domain.Event
+	maxLimit int // upstream 側で limit を丸める場合の上限（0 は無制限）
+	queries  []domain.Filter
+}
+
+func (u *fakeUpstream) Query(ctx context.Context, filter domain.Filter) ([]domain.Event, error) {
+	u.queries = append(u.queries, filter)
+	var matched []domain.Event
+	for _, evt := range u.events {
+		if filter.Matches(evt) {
+			matched = append(matched, evt)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].CreatedAt > matched[j].CreatedAt
+	})
+	limit := len(matched)
+	if filter.Limit != nil {
+		limit = min(limit, *filter.Limit)
+	}
+	if u.maxLimit > 0 {
+		limit = min(limit, u.maxLimit)
+	}
+	return matched[:limit], nil
+}
+
+func (u *fakeUpstream) Close() error { return nil }
+
+func (u *fakeUpstream) dialer() relay.UpstreamDialer {
+	return func(ctx context.Context, url string) (relay.Upstream, error) {
+		return u, nil
+	}
+}
+
+type mapCheckpointStore map[string]int64
+
+func (m mapCheckpointStore) GetCheckpoint(ctx context.Context, relayURL, filterKey string) (int64, error) {
+	v, ok := m[relayURL+" "+filterKey]
+	if !ok {
+		return 0, domain.ErrNotFound
+	}
+	return v, nil
+}
+
+func (m mapCheckpointStore) SaveCheckpoint(ctx context.Context, relayURL, filterKey string, createdAt int64) error {
+	m[relayURL+" "+filterKey] = createdAt
+	return nil
+}
+
+// signedEvents は created_at の異なる署名済みイベントを作る
+func signedEvents(t *testing.T, createdAts ...int64) []domain.Event {
+	t.Helper()
+	sk := nostr.GeneratePrivateKey()
+	events := make([]domain.Event, 0, len(createdAts))
+	for i, ts := range createdAts {
+		evt := nostr.Event{CreatedAt: nostr.Timestamp(ts), Kind: 1, Tags: nostr.Tags{}, Content: fmt.Sprintf("sync %d", i)}
+		if err := evt.Sign(sk); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, domain.Event{
+			ID:        evt.ID,
+			PubKey:    evt.PubKey,
+			Signature: evt.Sig,
+			CreatedAt: int64(evt.CreatedAt),
+			Kind:      evt.Kind,
+			Tags:      [][]string{},
+			Content:   evt.Content,
+		})
+	}
+	return events
+}
+
+func newTestSyncService(store relay.EventStore, up *fakeUpstream, checkpoints relay.SyncCheckpointStore) *usecase.SyncService {
+	relaySvc := usecase.NewRelayService(store, domain.NewConnectionPool())
+	return usecase.NewSyncService(relaySvc, up.dialer(), checkpoints)
+}
+
+func TestSyncService_Sync(t *testing.T) {
+	tests := []struct {
+		name     string
+		pageSize int
+		maxLimit int
+		want     usecase.SyncStats
+	}{
+		{
+			name:     "single page",
+			pageSize: 100,
+			want:     usecase.SyncStats{Fetched: 7, Stored: 7, Newest: 1005},
+		},
+		{
+			// 同時刻のイベントはページ境界で重複して返るが、数えない
+			name:     "paginated with until",
+			pageSize: 2,
+			want:     usecase.SyncStats{Fetched: 7, Stored: 7, Newest: 1005},
+		},
+		{
+			name:     "upstream caps limit",
+			pageSize: 100,
+			maxLimit: 3,
+			want:     usecase.SyncStats{Fetched: 7, Stored: 7, Newest: 1005},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up := &fakeUpstream{events: signedEvents(t, 1000, 1001, 1002, 1002, 1003, 1004, 1005), maxLimit: tt.maxLimit}
+			store := &sliceEventStore{}
+			svc := newTestSyncService(store, up, mapCheckpointStore{})
+
+			got, err := svc.Sync(context.Background(), "wss://upstream", domain.Filter{}, usecase.SyncOptions{PageSize: tt.pageSize})
+			if err != nil {
+				t.Fatalf("Sync() failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Sync() = %+v, want %+v", got, tt.want)
+			}
+			if len(store.events) != len(up.events) {
+				t.Errorf("stored %d events, want %d", len(store.events), len(up.events))
+			}
+		})
+	}
+}
+
+func TestSyncService_Sync_Checkpoint(t *testing.T) {
+	events := signedEvents(t, 1000, 1001, 1002, 1003)
+	up := &fakeUpstream{events: events[:2]}
+	store := &sliceEventStore{}
+	checkpoints := mapCheckpointStore{}
+	svc := newTestSyncService(store, up, checkpoints)
+	ctx := context.Background()
+
+	if _, err := svc.Sync(ctx, "wss://upstream", domain.Filter{}, usecase.SyncOptions{}); err != nil {
+		t.Fatalf("Sync() failed: %v", err)
+	}
+
+	// 2回目はチェックポイント（1001）以降だけを取得する
+	up.events = events
+	up.queries = nil
+	got, err := svc.Sync(ctx, "wss://upstream", domain.Filter{}, usecase.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() failed: %v", err)
+	}
+	want := usecase.SyncStats{Fetched: 3, Stored: 2, Duplicates: 1, Newest: 1003}
+	if got != want {
+		t.Errorf("Sync() = %+v, want %+v", got, want)
+	}
+	if since := up.queries[0].Since; since == nil || *since != 1001 {
+		t.Errorf("first query since = %v, want 1001", since)
+	}
+
+	// 別のフィルタはチェックポイントを共有しない
+	up.queries = nil
+	if _, err := svc.Sync(ctx, "wss://upstream", domain.Filter{Kinds: []int{1}}, usecase.SyncOptions{}); err != nil {
+		t.Fatalf("Sync() failed: %v", err)
+	}
+	if since := up.queries[0].Since; since != nil {
+		t.Errorf("query since with another filter = %v, want nil", *since)
+	}
+
+	// --full はチェックポイントを無視する
+	got, err = svc.Sync(ctx, "wss://upstream", domain.Filter{}, usecase.SyncOptions{Full: true})
+	if err != nil {
+		t.Fatalf("Sync() failed: %v", err)
+	}
+	want = usecase.SyncStats{Fetched: 4, Duplicates: 4, Newest: 1003}
+	if got != want {
+		t.Errorf("Sync(Full) = %+v, want %+v", got, want)
+	}
+}
+
+func TestSyncService_Sync_Rejected(t *testing.T) {
+	events := signedEvents(t, 1000, 1001)
+	events[0].Content = "tampered"
+	up := &fakeUpstream{events: events}
+	store := &sliceEventStore{}
+	checkpoints := mapCheckpointStore{}
+	svc := newTestSyncService(store, up, checkpoints)
+
+	got, err := svc.Sync(context.Background(), "wss://upstream", domain.Filter{}, usecase.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync() failed: %v", err)
+	}
+	want := usecase.SyncStats{Fetched: 2, Stored: 1, Rejected: 1, Newest: 1001}
+	if got != want {
+		t.Errorf("Sync() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSyncService_Sync_CheckpointIgnoresRejectedAndFuture(t *testing.T) {
+	// 拒否したイベントや未来の created_at でチェックポイントを進めると、以後の差分同期で取りこぼす
+	future := time.Now().Add(365 * 24 * time.Hour).Unix()
+
+	tests := []struct {
+		name     string
+		policies []domain.EventPolicy
+		check    func(t *testing.T, checkpoint int64, before, after int64)
+	}{
+		{
+			name:     "future event rejected by policy",
+			policies: []domain.EventPolicy{policy.NewCreatedAtWindow(time.Hour, 0, false)},
+			check: func(t *testing.T, checkpoint, before, after int64) {
+				if checkpoint != 1000 {
+					t.Errorf("checkpoint = %d, want 1000", checkpoint)
+				}
+			},
+		},
+		{
+			name: "accepted future event is clamped to now",
+			check: func(t *testing.T, checkpoint, before, after int64) {
+				if checkpoint < before || checkpoint > after {
+					t.Errorf("checkpoint = %d, want the current time (%d-%d)", checkpoint, before, after)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := signedEvents(t, 1000, 2000, future)
+			events[1].Content = "tampered"
+			up := &fakeUpstream{events: events}
+			checkpoints := mapCheckpointStore{}
+			relaySvc := usecase.NewRelayService(&sliceEventStore{}, domain.NewConnectionPool(), usecase.WithEventPolicies(tt.policies...))
+			svc := usecase.NewSyncService(relaySvc, up.dialer(), checkpoints)
+
+			before := time.Now().Unix()
+			if _, err := svc.Sync(context.Background(), "wss://upstream", domain.Filter{}, usecase.SyncOptions{}); err != nil {
+				t.Fatalf("Sync() failed: %v", err)
+			}
+			after := time.Now().Unix()
+
+			if len(checkpoints) != 1 {
+				t.Fatalf("checkpoints = %v, want one", checkpoints)
+			}
+			for _, checkpoint := range checkpoints {
+				tt.check(t, checkpoint, before, after)
+			}
+		})
+	}
+}
+
+func TestSyncService_Sync_Protected(t *testing.T) {
+	protected := signTestEvent(t, nostr.GeneratePrivateKey(), 1, nostr.Tags{{"-"}})
+
+	tests := []struct {
+		name           string
+		allowProtected bool
+		want           usecase.SyncStats
+	}{
+		{
+			name: "protected events are rejected by default",
+			want: usecase.SyncStats{Fetched: 1, Rejected: 1}, // 拒否したイベントではチェックポイントを進めない
+		},
+		{
+			name:           "allow protected",
+			allowProtected: true,
+			want:           usecase.SyncStats{Fetched: 1, Stored: 1, Newest: protected.CreatedAt},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newTestSyncService(&sliceEventStore{}, &fakeUpstream{events: []domain.Event{protected}}, mapCheckpointStore{})
+
+			got, err := svc.Sync(context.Background(), "wss://upstream", domain.Filter{}, usecase.SyncOptions{AllowProtected: tt.allowProtected})
+			if err != nil {
+				t.Fatalf("Sync() failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Sync() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncService_Sync_ManyInOneSecond(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxLimit int
+		wantErr  bool
+	}{
+		// limit を増やしてその秒を取り直す
+		{name: "refetched with a larger limit"},
+		// upstream が limit を丸めるため取り切れない場合は、黙って読み飛ばさずにエラーにする
+		{name: "upstream caps limit", maxLimit: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up := &fakeUpstream{events: signedEvents(t, 1000, 1002, 1002, 1002, 1002, 1002, 1003), maxLimit: tt.maxLimit}
+			store := &sliceEventStore{}
+			checkpoints := mapCheckpointStore{}
+			svc := newTestSyncService(store, up, checkpoints)
+
+			got, err := svc.Sync(context.Background(), "wss://upstream", domain.Filter{}, usecase.SyncOptions{PageSize: 2})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Sync() succeeded unexpectedly")
+				}
+				if len(checkpoints) != 0 {
+					t.Errorf("checkpoint saved after failure: %v", checkpoints)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Sync() failed: %v", err)
+			}
+			want := usecase.SyncStats{Fetched: 7, Stored: 7, Newest: 1003}
+			if got != want {
+				t.Errorf("Sync() = %+v, want %+v", got, want)
+			}
+			if len(store.events) != len(up.events) {
+				t.Errorf("stored %d events, want %d", len(store.events), len(up.events))
+			}
+		})
+	}
+}
+
+func TestSyncService_Sync_StoreError(t *testing.T) {
+	events := signedEvents(t, 1000)
+	up := &fakeUpstream{events: events}
+	store := &sliceEventStore{saveErr: map[string]error{events[0].ID: errors.New("database error")}}
+	checkpoints := mapCheckpointStore{}
+	svc := newTestSyncService(store, up, checkpoints)
+
+	if _, err := svc.Sync(context.Background(), "wss://upstream", domain.Filter{}, usecase.SyncOptions{}); err == nil {
+		t.Fatal("Sync() succeeded unexpectedly")
+	}
+	if len(checkpoints) != 0 {
+		t.Errorf("checkpoint saved after failure: %v", checkpoints)
+	}
+}
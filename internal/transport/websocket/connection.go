@@ -1,16 +1,26 @@
 package websocket
 
 import (
+	"sync"
+
 	"nostar/internal/relay/domain"
 
 	"github.com/gorilla/websocket"
 )
 
+// WebSocketConnection は gorilla/websocket の接続をラップする
+// 読み込みループ・REQ の送信・ブロードキャストが同時に書き込むため、書き込みは mu で直列化する
 type WebSocketConnection struct {
 	id   domain.ConnectionID
 	conn *websocket.Conn
+	mu   sync.Mutex
 }
 
-func (c *WebSocketConnection) ID() domain.ConnectionID       { return c.id }
-func (c *WebSocketConnection) WriteJSON(v interface{}) error { return c.conn.WriteJSON(v) }
-func (c *WebSocketConnection) Close() error                  { return c.conn.Close() }
+func (c *WebSocketConnection) ID() domain.ConnectionID { return c.id }
+func (c *WebSocketConnection) Close() error            { return c.conn.Close() }
+
+func (c *WebSocketConnection) WriteJSON(v interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteJSON(v)
+}
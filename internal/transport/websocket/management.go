@@ -0,0 +1,242 @@
+package websocket
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"nostar/internal/relay/domain"
+
+	"github.com/nbd-wtf/go-nostr/nip86"
+	"go.uber.org/zap"
+)
+
+// NIP-86 の Content-Type
+const managementContentType = "application/nostr+json+rpc"
+
+// isManagementRequest reports whether r is a NIP-86 request. charset などのパラメータは無視する
+func isManagementRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return r.Method == http.MethodPost && err == nil && mediaType == managementContentType
+}
+
+// maxManagementBodySize は管理 API のリクエストボディの上限
+const maxManagementBodySize = 1 << 20
+
+// supportedManagementMethods は supportedmethods で返すメソッド一覧
+var supportedManagementMethods = []string{
+	"supportedmethods",
+	"banpubkey",
+	"allowpubkey",
+	"listbannedpubkeys",
+	"banevent",
+	"allowevent",
+	"listbannedevents",
+	"changerelayname",
+	"changerelaydescription",
+	"blockip",
+	"unblockip",
+	"listblockedips",
+	"listeventsneedingmoderation",
+	"listreports",
+}
+
+// managementQueueLimit は管理 API で返すモデレーションキューの最大件数
+const managementQueueLimit = 500
+
+// listReports is the nostar-specific "listreports" method.
+// listeventsneedingmoderation と異なり、pubkey への報告も含めて返す
+type listReports struct{}
+
+func (listReports) MethodName() string { return "listreports" }
+
+// reportQueueItem is one entry of the "listreports" result.
+type reportQueueItem struct {
+	Target           string   `json:"target"`
+	Value            string   `json:"value"`
+	TrustedReporters int      `json:"trusted_reporters"`
+	Reporters        int      `json:"reporters"`
+	Types            []string `json:"types"`
+	LastReportedAt   int64    `json:"last_reported_at"`
+	Hidden           bool     `json:"hidden"`
+}
+
+// handleManagement handles NIP-86 relay management requests (JSON-RPC over HTTP, NIP-98 auth).
+func (s *Server) handleManagement(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxManagementBodySize))
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	pubkey, err := verifyHTTPAuthHeader(r, s.managementURL(r), body)
+	if err != nil {
+		zap.S().Infow("management auth failed", "remote_addr", r.RemoteAddr, "error", err)
+		writeManagementResponse(w, http.StatusUnauthorized, nip86.Response{Error: "unauthorized: " + err.Error()})
+		return
+	}
+	if !s.management.IsAdmin(pubkey) {
+		zap.S().Infow("management request from non-admin", "pubkey", pubkey)
+		writeManagementResponse(w, http.StatusUnauthorized, nip86.Response{Error: "unauthorized: not an admin"})
+		return
+	}
+
+	var req nip86.Request
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeManagementResponse(w, http.StatusBadRequest, nip86.Response{Error: "invalid request"})
+		return
+	}
+	var params nip86.MethodParams
+	if req.Method == "listreports" {
+		params = listReports{} // nip86.DecodeRequest は独自メソッドを扱えない
+	} else {
+		params, err = nip86.DecodeRequest(req)
+	}
+	if err != nil {
+		writeManagementResponse(w, http.StatusOK, nip86.Response{Error: err.Error()})
+		return
+	}
+
+	result, err := s.callManagement(r, params)
+	if err != nil {
+		zap.S().Errorw("management method failed", "method", req.Method, "error", err)
+		writeManagementResponse(w, http.StatusOK, nip86.Response{Error: "internal error"})
+		return
+	}
+	zap.S().Infow("management method called", "method", req.Method, "admin", pubkey)
+	writeManagementResponse(w, http.StatusOK, nip86.Response{Result: result})
+}
+
+func (s *Server) callManagement(r *http.Request, params nip86.MethodParams) (any, error) {
+	ctx := r.Context()
+	mgmt := s.management
+
+	switch p := params.(type) {
+	case nip86.SupportedMethods:
+		return supportedManagementMethods, nil
+
+	case nip86.BanPubKey:
+		return true, mgmt.Ban(ctx, domain.BanPubKey, p.PubKey, p.Reason)
+	case nip86.AllowPubKey:
+		// nostar では banpubkey の解除として扱う
+		return true, mgmt.Unban(ctx, domain.BanPubKey, p.PubKey)
+	case nip86.ListBannedPubKeys:
+		res := []nip86.PubKeyReason{}
+		for _, ban := range mgmt.ListBans(domain.BanPubKey) {
+			res = append(res, nip86.PubKeyReason{PubKey: ban.Value, Reason: ban.Reason})
+		}
+		return res, nil
+
+	case nip86.BanEvent:
+		return true, mgmt.Ban(ctx, domain.BanEvent, p.ID, p.Reason)
+	case nip86.AllowEvent:
+		return true, mgmt.Unban(ctx, domain.BanEvent, p.ID)
+	case nip86.ListBannedEvents:
+		res := []nip86.IDReason{}
+		for _, ban := range mgmt.ListBans(domain.BanEvent) {
+			res = append(res, nip86.IDReason{ID: ban.Value, Reason: ban.Reason})
+		}
+		return res, nil
+
+	case nip86.ChangeRelayName:
+		return true, mgmt.SetRelaySetting(ctx, domain.SettingRelayName, p.Name)
+	case nip86.ChangeRelayDescription:
+		return true, mgmt.SetRelaySetting(ctx, domain.SettingRelayDescription, p.Description)
+
+	case nip86.BlockIP:
+		return true, mgmt.Ban(ctx, domain.BanIP, p.IP.String(), p.Reason)
+	case nip86.UnblockIP:
+		return true, mgmt.Unban(ctx, domain.BanIP, p.IP.String())
+	case nip86.ListBlockedIPs:
+		res := []nip86.IPReason{}
+		for _, ban := range mgmt.ListBans(domain.BanIP) {
+			res = append(res, nip86.IPReason{IP: ban.Value, Reason: ban.Reason})
+		}
+		return res, nil
+
+	case nip86.ListEventsNeedingModeration:
+		items, err := s.reports.Queue(ctx, false, managementQueueLimit)
+		if err != nil {
+			return nil, err
+		}
+		res := []nip86.IDReason{}
+		for _, item := range items {
+			if item.Target != domain.HideEvent {
+				continue
+			}
+			reason := fmt.Sprintf("reported by %d trusted / %d reporters", item.TrustedReporters, item.Reporters)
+			if len(item.Types) > 0 {
+				reason = strings.Join(item.Types, ", ") + ": " + reason
+			}
+			res = append(res, nip86.IDReason{ID: item.Value, Reason: reason})
+		}
+		return res, nil
+	case listReports:
+		items, err := s.reports.Queue(ctx, true, managementQueueLimit)
+		if err != nil {
+			return nil, err
+		}
+		res := []reportQueueItem{}
+		for _, item := range items {
+			res = append(res, reportQueueItem{
+				Target:           string(item.Target),
+				Value:            item.Value,
+				TrustedReporters: item.TrustedReporters,
+				Reporters:        item.Reporters,
+				Types:            item.Types,
+				LastReportedAt:   item.LastReportedAt.Unix(),
+				Hidden:           item.Hidden,
+			})
+		}
+		return res, nil
+	}
+
+	return nil, fmt.Errorf("method %s is not supported", params.MethodName())
+}
+
+// managementURL returns the URL that the NIP-98 "u" tag must match.
+// config で指定されていない場合は、リクエストの Host から組み立てる
+func (s *Server) managementURL(r *http.Request) string {
+	if s.managementBaseURL != "" {
+		return s.managementBaseURL
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.Path
+}
+
+// verifyHTTPAuthHeader verifies "Authorization: Nostr <base64 event>" (NIP-98) and returns the pubkey.
+func verifyHTTPAuthHeader(r *http.Request, url string, body []byte) (string, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Nostr") {
+		return "", errors.New("missing Nostr authorization header")
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(token))
+	if err != nil {
+		return "", errors.New("invalid base64 in authorization header")
+	}
+	var evt domain.Event
+	if err := json.Unmarshal(b, &evt); err != nil {
+		return "", errors.New("invalid event in authorization header")
+	}
+	if err := domain.VerifyHTTPAuth(evt, url, r.Method, body, time.Now()); err != nil {
+		return "", err
+	}
+	return evt.PubKey, nil
+}
+
+func writeManagementResponse(w http.ResponseWriter, status int, res nip86.Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		zap.S().Errorw("failed to encode management response", "error", err)
+	}
+}
@@ -0,0 +1,32 @@
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsManagementRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		want        bool
+	}{
+		{name: "exact", method: http.MethodPost, contentType: "application/nostr+json+rpc", want: true},
+		{name: "with charset", method: http.MethodPost, contentType: "application/nostr+json+rpc; charset=utf-8", want: true},
+		{name: "upper case", method: http.MethodPost, contentType: "Application/Nostr+JSON+RPC", want: true},
+		{name: "other type", method: http.MethodPost, contentType: "application/json"},
+		{name: "invalid", method: http.MethodPost, contentType: "application/nostr+json+rpc; ="},
+		{name: "GET", method: http.MethodGet, contentType: "application/nostr+json+rpc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/", nil)
+			r.Header.Set("Content-Type", tt.contentType)
+			if got := isManagementRequest(r); got != tt.want {
+				t.Errorf("isManagementRequest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
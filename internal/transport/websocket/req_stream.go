@@ -0,0 +1,77 @@
+package websocket
+
+import (
+	"context"
+	"sync"
+)
+
+// reqStreams tracks the REQs of one connection that are still sending stored events.
+// CLOSE・同じ subscription ID の REQ・切断時に、実行中の送信をキャンセルして終了を待つ
+type reqStreams struct {
+	mu      sync.Mutex
+	streams map[string]*reqStream
+}
+
+type reqStream struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newReqStreams() *reqStreams {
+	return &reqStreams{
+		streams: make(map[string]*reqStream),
+	}
+}
+
+// start stops the stream with the same subscription ID and runs fn in a new goroutine.
+// fn の ctx は stop / stopAll または parent の終了でキャンセルされる
+func (r *reqStreams) start(parent context.Context, subID string, fn func(ctx context.Context)) {
+	r.stop(subID)
+
+	ctx, cancel := context.WithCancel(parent)
+	stream := &reqStream{cancel: cancel, done: make(chan struct{})}
+
+	r.mu.Lock()
+	r.streams[subID] = stream
+	r.mu.Unlock()
+
+	go func() {
+		defer func() {
+			cancel()
+			r.mu.Lock()
+			if r.streams[subID] == stream {
+				delete(r.streams, subID)
+			}
+			r.mu.Unlock()
+			close(stream.done)
+		}()
+		fn(ctx)
+	}()
+}
+
+// stop cancels the stream of subID and waits for it to finish (no-op if it is not running).
+func (r *reqStreams) stop(subID string) {
+	r.mu.Lock()
+	stream, ok := r.streams[subID]
+	r.mu.Unlock()
+	if !ok {
+		return
+	}
+	stream.cancel()
+	<-stream.done
+}
+
+// stopAll cancels every running stream and waits for them to finish.
+func (r *reqStreams) stopAll() {
+	r.mu.Lock()
+	streams := make([]*reqStream, 0, len(r.streams))
+	for _, stream := range r.streams {
+		streams = append(streams, stream)
+	}
+	r.mu.Unlock()
+
+	for _, stream := range streams {
+		stream.cancel()
+		<-stream.done
+	}
+}
@@ -0,0 +1,81 @@
+package websocket
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReqStreams(t *testing.T) {
+	tests := []struct {
+		name string
+		stop func(r *reqStreams, parentCancel context.CancelFunc)
+	}{
+		{
+			name: "stop cancels the stream",
+			stop: func(r *reqStreams, _ context.CancelFunc) { r.stop("sub") },
+		},
+		{
+			name: "stopAll cancels the stream",
+			stop: func(r *reqStreams, _ context.CancelFunc) { r.stopAll() },
+		},
+		{
+			name: "start with the same id replaces the stream",
+			stop: func(r *reqStreams, _ context.CancelFunc) {
+				r.start(context.Background(), "sub", func(context.Context) {})
+			},
+		},
+		{
+			name: "parent cancel cancels the stream",
+			stop: func(r *reqStreams, parentCancel context.CancelFunc) { parentCancel() },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newReqStreams()
+			parent, parentCancel := context.WithCancel(context.Background())
+			defer parentCancel()
+
+			started := make(chan struct{})
+			finished := make(chan struct{})
+			r.start(parent, "sub", func(ctx context.Context) {
+				close(started)
+				<-ctx.Done()
+				close(finished)
+			})
+			<-started
+
+			tt.stop(r, parentCancel)
+
+			select {
+			case <-finished:
+			case <-time.After(time.Second):
+				t.Fatal("stream was not canceled")
+			}
+		})
+	}
+}
+
+func TestReqStreams_RemoveFinished(t *testing.T) {
+	r := newReqStreams()
+	done := make(chan struct{})
+	r.start(context.Background(), "sub", func(context.Context) { close(done) })
+	<-done
+
+	// 終了した送信は stop しても待たない
+	r.stopAll()
+	r.stop("sub")
+	deadline := time.Now().Add(time.Second)
+	for {
+		r.mu.Lock()
+		n := len(r.streams)
+		r.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d streams remain after finishing", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
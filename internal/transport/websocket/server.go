@@ -2,9 +2,13 @@ package websocket
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"net"
 	"net/http"
+	"time"
 
 	"nostar/internal/config"
 	"nostar/internal/relay/domain"
@@ -14,6 +18,9 @@ import (
 	"go.uber.org/zap"
 )
 
+// shutdownTimeout は、終了時に処理中の HTTP リクエストを待つ時間
+const shutdownTimeout = 10 * time.Second
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -29,14 +36,25 @@ type Server struct {
 	relay          *usecase.RelayService
 	connectionPool *domain.ConnectionPool
 	relayInfo      *config.RelayInfoConfig
+	identities     *usecase.IdentityService   // NIP-05
+	negentropy     *usecase.NegentropyService // NIP-77
+	management     *usecase.ManagementService // NIP-86
+	reports        *usecase.ReportService     // NIP-56 のモデレーションキュー（管理 API）
+
+	managementBaseURL string // NIP-98 の u タグと比較する URL（空の場合はリクエストから組み立てる）
 }
 
-func NewServer(addr string, relay *usecase.RelayService, connPool *domain.ConnectionPool, relayInfo *config.RelayInfoConfig) *Server {
+func NewServer(addr string, relay *usecase.RelayService, connPool *domain.ConnectionPool, relayInfo *config.RelayInfoConfig, identities *usecase.IdentityService, negentropy *usecase.NegentropyService, management *usecase.ManagementService, reports *usecase.ReportService, managementURL string) *Server {
 	return &Server{
-		addr:           addr,
-		relay:          relay,
-		connectionPool: connPool,
-		relayInfo:      relayInfo,
+		addr:              addr,
+		relay:             relay,
+		connectionPool:    connPool,
+		relayInfo:         relayInfo,
+		identities:        identities,
+		negentropy:        negentropy,
+		management:        management,
+		reports:           reports,
+		managementBaseURL: managementURL,
 	}
 }
 
@@ -50,10 +68,12 @@ func (s *Server) Run(ctx context.Context) error {
 		Handler: mux,
 	}
 
-	// Note: proper graceful shutdown should hook into ctx.Done().
+	// ctx が終了したら新しい接続の受け付けを止める（ctx は終了済みなので、別の期限で待つ）
 	go func() {
 		<-ctx.Done()
-		_ = srv.Shutdown(ctx)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
 	}()
 
 	zap.S().Infow("starting websocket listener", "addr", s.addr)
@@ -68,9 +88,34 @@ func (s *Server) Run(ctx context.Context) error {
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// NIP-11: Relay Information Document
-	if r.Method == "GET" && (r.URL.Path == "/.well-known/nostr.json") {
-		s.handleRelayInfo(w, r)
+	if r.URL.Path == "/.well-known/nostr.json" {
+		switch {
+		case r.Method == http.MethodOptions:
+			// CORS preflight
+			setCORSHeaders(w)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case r.Method == "GET" && r.URL.Query().Has("name"):
+			// NIP-05: DNS-based internet identifiers
+			s.handleNIP05(w, r)
+			return
+		case r.Method == "GET":
+			// NIP-11: Relay Information Document
+			s.handleRelayInfo(w, r)
+			return
+		}
+	}
+
+	// NIP-86: Relay Management API
+	if isManagementRequest(r) {
+		s.handleManagement(w, r)
+		return
+	}
+
+	sourceIP, sourceType := remoteSource(r)
+	if s.management.IsBanned(domain.BanIP, sourceIP) {
+		zap.S().Infow("connection from blocked ip", "ip", sourceIP)
+		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
 
@@ -91,20 +136,38 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		conn: c,
 	}
 
+	// 接続ごとの ctx（切断時にキャンセルし、実行中の REQ を中断する）
+	ctx, cancel := context.WithCancel(r.Context())
+	streams := newReqStreams()
+
 	// ConnectionPool に追加
 	s.connectionPool.Add(wsConn)
 	defer func() {
+		cancel()
+		streams.stopAll()
 		// 接続切断時にすべてのサブスクリプションを解除
 		if err := s.relay.UnregisterAllSubscriptions(context.Background(), connID); err != nil {
 			zap.S().Errorw("failed to unregister all subscriptions", "connID", connID, "error", err)
 		}
+		s.negentropy.CloseAll(context.Background(), connID)
+		s.relay.ClearAuth(connID)
 		s.connectionPool.Remove(connID)
 	}()
 
 	zap.S().Debugw("added to connection pool", "id", connID, "num", s.connectionPool.GetSize())
 
+	// NIP-42: 接続ごとのチャレンジを送る（非公開の kind を読むには AUTH が必要）
+	challenge, err := newAuthChallenge()
+	if err != nil {
+		zap.S().Errorw("failed to create auth challenge", zap.Error(err))
+		return
+	}
+	if err := wsConn.WriteJSON([]string{"AUTH", challenge}); err != nil {
+		zap.S().Errorw("write AUTH failed", zap.Error(err))
+		return
+	}
+
 	for {
-		ctx := r.Context()
 		_, data, err := c.ReadMessage()
 		if err != nil {
 			var ce *websocket.CloseError
@@ -121,7 +184,7 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		var wire WireMessage
 		if err := json.Unmarshal(data, &wire); err != nil {
 			zap.S().Debugw("unknown data", zap.String("data", string(data)), zap.Error(err))
-			if err := c.WriteJSON([]string{"NOTICE", "invalid JSON: cannot parse message"}); err != nil {
+			if err := wsConn.WriteJSON([]string{"NOTICE", "invalid JSON: cannot parse message"}); err != nil {
 				zap.S().Errorw("write notice failed", zap.Error(err))
 				return
 			}
@@ -131,14 +194,38 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		case "EVENT":
 			var evt domain.Event
 			if err := json.Unmarshal(wire.Event, &evt); err != nil {
-				c.WriteJSON([]string{"NOTICE", "invalid JSON: cannot parse message"})
+				wsConn.WriteJSON([]string{"NOTICE", "invalid JSON: cannot parse message"})
 				continue
 			}
 			zap.S().Debugw("received EVENT", zap.Int("kind", evt.Kind))
 
-			if err := s.relay.HandleEvent(ctx, usecase.EventMessage{Event: evt}); err != nil {
-				zap.S().Errorw("handle EVENT failed", zap.Error(err))
-				if writeErr := c.WriteJSON([]any{"OK", evt.ID, false, "internal error"}); writeErr != nil {
+			eventMsg := usecase.EventMessage{
+				Event:        evt,
+				SourceType:   sourceType,
+				SourceInfo:   sourceIP,
+				AuthedPubKey: authedPubKeyFor(s.relay.AuthedPubKeys(connID), evt.PubKey),
+				RelayURL:     relayURL(r),
+			}
+			err := s.relay.HandleEvent(ctx, eventMsg)
+			if errors.Is(err, domain.ErrDuplicate) {
+				// NIP-01: 既に持っているイベントは OK true で返す
+				if err := wsConn.WriteJSON([]any{"OK", evt.ID, true, "duplicate: already have this event"}); err != nil {
+					zap.S().Errorw("write EVENT OK failed", zap.Error(err))
+					return
+				}
+				continue
+			}
+			if err != nil {
+				reason := "internal error"
+				var rejectErr *domain.RejectError
+				if errors.As(err, &rejectErr) {
+					// ポリシーによる拒否は、理由をそのままクライアントに返す
+					zap.S().Infow("EVENT rejected", "event_id", evt.ID, "reason", rejectErr.Error())
+					reason = rejectErr.Error()
+				} else {
+					zap.S().Errorw("handle EVENT failed", zap.Error(err))
+				}
+				if writeErr := wsConn.WriteJSON([]any{"OK", evt.ID, false, reason}); writeErr != nil {
 					// クライアントに EVENT 登録に失敗したことを通知
 					zap.S().Errorw("write EVENT OK failed", zap.Error(writeErr))
 					return
@@ -146,41 +233,61 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 
-			if err := c.WriteJSON([]any{"OK", evt.ID, true, ""}); err != nil {
+			if err := wsConn.WriteJSON([]any{"OK", evt.ID, true, ""}); err != nil {
 				zap.S().Errorw("write EVENT OK failed", zap.Error(err))
 				return
 			}
 
-		case "REQ":
-			zap.S().Debugw("received REQ")
-			// wire.SubscriptionID, wire.Filters を使って Subscription を組み立てる
-
-			// フィルタ部分だけ軽くパースする（1つ目だけを採用）
-			// TODO: 複数フィルターに対応
-			var f struct {
-				Authors []string `json:"authors"`
-				Kinds   []int    `json:"kinds"`
-				Since   *int64   `json:"since"`
-				Until   *int64   `json:"until"`
-				Limit   *int     `json:"limit"`
+		case "AUTH":
+			var evt domain.Event
+			if err := json.Unmarshal(wire.Event, &evt); err != nil {
+				wsConn.WriteJSON([]string{"NOTICE", "invalid JSON: cannot parse message"})
+				continue
 			}
+			zap.S().Debugw("received AUTH", "connID", connID, "pubkey", evt.PubKey)
 
-			if len(wire.Filters) > 0 {
-				// TODO: 複数フィルターに対応
-				if err := json.Unmarshal(wire.Filters[0], &f); err != nil {
-					zap.S().Debugw("invalid REQ filter", "data", string(wire.Filters[0]), zap.Error(err))
-					if err := c.WriteJSON([]string{"NOTICE", "invalid REQ filter"}); err != nil {
-						zap.S().Errorw("write notice failed", zap.Error(err))
-						return
-					}
-					continue // コネクションは継続
+			ok, reason := true, ""
+			err := s.relay.HandleAuth(ctx, usecase.AuthMessage{
+				ConnectionID: connID,
+				Event:        evt,
+				Challenge:    challenge,
+				RelayURL:     relayURL(r),
+			})
+			if err != nil {
+				ok, reason = false, "error: internal error"
+				var rejectErr *domain.RejectError
+				if errors.As(err, &rejectErr) {
+					zap.S().Infow("AUTH rejected", "connID", connID, "reason", rejectErr.Error())
+					reason = rejectErr.Error()
+				} else {
+					zap.S().Errorw("handle AUTH failed", zap.Error(err))
 				}
 			}
+			if err := wsConn.WriteJSON([]any{"OK", evt.ID, ok, reason}); err != nil {
+				zap.S().Errorw("write AUTH OK failed", zap.Error(err))
+				return
+			}
+
+		case "REQ":
+			zap.S().Debugw("received REQ")
+
+			// 同じ subscription ID の REQ は置き換える（不正な REQ の場合も、実行中の送信を止め、ライブ配信も解除する）
+			streams.stop(wire.SubscriptionID)
+			if err := s.relay.UnregisterSubscription(ctx, usecase.CloseMessage{ConnectionID: connID, SubscriptionID: wire.SubscriptionID}); err != nil {
+				zap.S().Errorw("delete subscription failed", zap.Error(err))
+			}
 
+			// wire.SubscriptionID, wire.Filters を使って Subscription を組み立てる
+			// フィルタが1つでも不正な場合は、検索せずに CLOSED で理由を返す
 			filters, err := domain.NewFiltersFromRaw(wire.Filters)
+			if err == nil && len(filters) == 0 {
+				err = errors.New("REQ needs at least one filter")
+			}
 			if err != nil {
-				if err := c.WriteJSON([]string{"NOTICE", "invalid REQ filter"}); err != nil {
-					zap.S().Errorw("failed to parse filters", zap.Error(err))
+				reason := domain.NewRejectError(domain.ReasonInvalid, "%s", err).Error()
+				zap.S().Debugw("invalid REQ filter", "subscriptionID", wire.SubscriptionID, "reason", reason)
+				if err := wsConn.WriteJSON([]string{"CLOSED", wire.SubscriptionID, reason}); err != nil {
+					zap.S().Errorw("write CLOSED failed", zap.Error(err))
 					return
 				}
 				continue // コネクションは継続
@@ -191,45 +298,10 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				Filters: filters,
 			}
 
-			var events []domain.Event
-			if events, err = s.relay.HandleReq(ctx, usecase.ReqMessage{Subscription: sub}); err != nil {
-				zap.S().Errorw("handle REQ failed", zap.Error(err))
-				if err := c.WriteJSON([]string{"NOTICE", "internal error on REQ"}); err != nil {
-					zap.S().Errorw("write notice failed", zap.Error(err))
-					return
-				}
-				continue
-			}
-
-			// 取得したイベントをクライアントに送信
-			for _, evt := range events {
-				if err := c.WriteJSON([]any{"EVENT", wire.SubscriptionID, evt}); err != nil {
-					zap.S().Errorw("write event failed", zap.Error(err))
-					return
-				}
-			}
-
-			// この REQ に対する過去イベントの送信終了
-			if err := c.WriteJSON([]any{"EOSE", wire.SubscriptionID}); err != nil {
-				zap.S().Errorw("write EOSE failed", zap.Error(err))
-				return
-			}
-
-			// この時点からライブ配信開始
-			reqMsg := usecase.ReqMessage{
-				Subscription: sub,
-				ConnectionID: connID,
-			}
-
-			// SubscriptionRegistry に登録
-			if err := s.relay.RegisterSubscription(ctx, reqMsg); err != nil {
-				zap.S().Errorw("failed to error subscription", zap.Error(err))
-				if err := c.WriteJSON([]string{"NOTICE", "internal error on REQ (subscription)"}); err != nil {
-					zap.S().Errorw("write subscription failed", zap.Error(err))
-				}
-				return
-			}
-			zap.S().Infow("register subscription", "connID", connID, "subscriptionID", sub.ID)
+			// 保存済みイベントは読み込みながら送信する（その間も CLOSE などを受け付ける）
+			streams.start(ctx, sub.ID, func(ctx context.Context) {
+				s.streamReq(ctx, wsConn, sub)
+			})
 
 		case "CLOSE":
 			zap.S().Debugw("received CLOSE", "connID", connID, "subscriberID", wire.SubscriptionID)
@@ -239,6 +311,9 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				// CLOSE は ack 不要なので、エラーでもクライアントには特に何も返さない
 			}
 
+			// 送信中の REQ を中断してから解除する
+			streams.stop(wire.SubscriptionID)
+
 			closeMsg := usecase.CloseMessage{
 				ConnectionID:   connID,
 				SubscriptionID: wire.SubscriptionID,
@@ -248,21 +323,180 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				zap.S().Errorw("delete subscription failed", zap.Error(err))
 				// ユーザに通知しなくていい
 			}
+
+		case "NEG-OPEN":
+			zap.S().Debugw("received NEG-OPEN", "connID", connID, "subscriptionID", wire.SubscriptionID)
+
+			filters, err := domain.NewFiltersFromRaw(wire.Filters)
+			if err == nil && len(filters) == 0 {
+				err = errors.New("NEG-OPEN needs a filter")
+			}
+			if err != nil {
+				reason := domain.NewRejectError(domain.ReasonInvalid, "%s", err).Error()
+				zap.S().Debugw("invalid NEG-OPEN filter", "subscriptionID", wire.SubscriptionID, "reason", reason)
+				if err := wsConn.WriteJSON([]string{"NEG-ERR", wire.SubscriptionID, reason}); err != nil {
+					zap.S().Errorw("write NEG-ERR failed", zap.Error(err))
+					return
+				}
+				continue
+			}
+
+			out, err := s.negentropy.Open(ctx, usecase.NegOpenMessage{
+				ConnectionID:   connID,
+				SubscriptionID: wire.SubscriptionID,
+				Filter:         filters[0],
+				Message:        wire.NegMessage,
+			})
+			if !s.writeNegResult(wsConn, wire.SubscriptionID, out, err) {
+				return
+			}
+
+		case "NEG-MSG":
+			out, err := s.negentropy.Message(ctx, usecase.NegMessage{
+				ConnectionID:   connID,
+				SubscriptionID: wire.SubscriptionID,
+				Message:        wire.NegMessage,
+			})
+			if !s.writeNegResult(wsConn, wire.SubscriptionID, out, err) {
+				return
+			}
+
+		case "NEG-CLOSE":
+			zap.S().Debugw("received NEG-CLOSE", "connID", connID, "subscriptionID", wire.SubscriptionID)
+			s.negentropy.Close(ctx, usecase.NegCloseMessage{
+				ConnectionID:   connID,
+				SubscriptionID: wire.SubscriptionID,
+			})
+		}
+	}
+
+}
+
+// streamReq sends the stored events of a REQ, EOSE, and then starts the live subscription.
+// ctx がキャンセルされた場合（CLOSE・切断）は、EOSE を送らずにライブ配信も開始しない
+func (s *Server) streamReq(ctx context.Context, c *WebSocketConnection, sub domain.Subscription) {
+	// 検索の前にサブスクリプションを登録し、検索中のライブイベントは EOSE の後に送る
+	reqMsg := usecase.ReqMessage{
+		Subscription: sub,
+		ConnectionID: c.ID(),
+	}
+	err := s.relay.HandleReq(ctx, reqMsg, func(evt domain.Event) error {
+		return c.WriteJSON([]any{"EVENT", sub.ID, evt})
+	})
+	if ctx.Err() != nil {
+		zap.S().Debugw("REQ canceled", "connID", c.ID(), "subscriptionID", sub.ID)
+		return
+	}
+	if err != nil {
+		var rejectErr *domain.RejectError
+		if errors.As(err, &rejectErr) {
+			// 広すぎるフィルタ・タイムアウト: サブスクリプションを開始せずに CLOSED で返す
+			zap.S().Infow("REQ closed", "subscriptionID", sub.ID, "reason", rejectErr.Error())
+			if err := c.WriteJSON([]string{"CLOSED", sub.ID, rejectErr.Error()}); err != nil {
+				zap.S().Errorw("write CLOSED failed", zap.Error(err))
+			}
+			return
+		}
+		// 書き込みの失敗は読み込みループ側でも検出されるため、ここではログのみ
+		zap.S().Errorw("handle REQ failed", zap.Error(err))
+		if err := c.WriteJSON([]string{"NOTICE", "internal error on REQ"}); err != nil {
+			zap.S().Errorw("write notice failed", zap.Error(err))
+		}
+		return
+	}
+
+	// この REQ に対する過去イベントの送信終了
+	if err := c.WriteJSON([]any{"EOSE", sub.ID}); err != nil {
+		zap.S().Errorw("write EOSE failed", zap.Error(err))
+		return
+	}
+
+	// この時点からライブ配信開始（溜めていたライブイベントを送信する）
+	if err := s.relay.RegisterSubscription(ctx, reqMsg); err != nil {
+		reason := "error: internal error on REQ (subscription)"
+		var rejectErr *domain.RejectError
+		if errors.As(err, &rejectErr) {
+			zap.S().Infow("REQ closed", "subscriptionID", sub.ID, "reason", rejectErr.Error())
+			reason = rejectErr.Error()
+		} else {
+			zap.S().Errorw("failed to register subscription", zap.Error(err))
+		}
+		if err := c.WriteJSON([]string{"CLOSED", sub.ID, reason}); err != nil {
+			zap.S().Errorw("write CLOSED failed", zap.Error(err))
 		}
+		return
+	}
+	zap.S().Infow("start live subscription", "connID", c.ID(), "subscriptionID", sub.ID)
+}
+
+// newAuthChallenge returns a random NIP-42 challenge.
+func newAuthChallenge() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// relayURL returns the WebSocket URL the client connected to (NIP-42 / NIP-62 の relay タグと比較する).
+func relayURL(r *http.Request) string {
+	scheme := "ws"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "wss"
 	}
+	return scheme + "://" + r.Host + r.URL.Path
+}
 
+// authedPubKeyFor returns the authenticated pubkey passed to event policies: the author if authenticated, otherwise any.
+func authedPubKeyFor(authed domain.PubKeySet, author string) string {
+	if authed.Contains(author) {
+		return author
+	}
+	for pk := range authed {
+		return pk
+	}
+	return ""
+}
+
+// writeNegResult writes NEG-MSG, or NEG-ERR if err is not nil.
+// 書き込みに失敗した場合（接続を閉じるべき場合）は false を返す
+func (s *Server) writeNegResult(c *WebSocketConnection, subID, out string, err error) bool {
+	msg := []string{"NEG-MSG", subID, out}
+	if err != nil {
+		reason := "error: internal error"
+		var rejectErr *domain.RejectError
+		if errors.As(err, &rejectErr) {
+			reason = rejectErr.Error()
+		} else {
+			zap.S().Errorw("negentropy failed", "subscriptionID", subID, zap.Error(err))
+		}
+		msg = []string{"NEG-ERR", subID, reason}
+	}
+	if err := c.WriteJSON(msg); err != nil {
+		zap.S().Errorw("write negentropy message failed", zap.Error(err))
+		return false
+	}
+	return true
 }
 
 // handleRelayInfo handles NIP-11 Relay Information Document requests
 func (s *Server) handleRelayInfo(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	setCORSHeaders(w)
+
+	// NIP-86 で変更された項目は config より優先する
+	name := s.relayInfo.Name
+	if v, ok := s.management.RelaySetting(domain.SettingRelayName); ok {
+		name = v
+	}
+	description := s.relayInfo.Description
+	if v, ok := s.management.RelaySetting(domain.SettingRelayDescription); ok {
+		description = v
+	}
 
 	relayInfo := map[string]interface{}{
-		"name":        s.relayInfo.Name,
-		"description": s.relayInfo.Description,
+		"name":        name,
+		"description": description,
 		"software":    s.relayInfo.Software,
 		"version":     s.relayInfo.Version,
 		// "limitation": map[string]interface{}{
@@ -278,9 +512,27 @@ func (s *Server) handleRelayInfo(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Optional fields
+	limitation := map[string]interface{}{}
+	if s.relayInfo.Limitation.CreatedAtLowerLimit > 0 {
+		limitation["created_at_lower_limit"] = s.relayInfo.Limitation.CreatedAtLowerLimit
+	}
+	if s.relayInfo.Limitation.CreatedAtUpperLimit > 0 {
+		limitation["created_at_upper_limit"] = s.relayInfo.Limitation.CreatedAtUpperLimit
+	}
+	if limits := s.relay.QueryLimits(); limits.PrefixMatch {
+		// ids / authors の前方一致に使える最短の長さ
+		limitation["min_prefix"] = limits.MinPrefixLength
+	}
+	if len(limitation) > 0 {
+		relayInfo["limitation"] = limitation
+	}
 	if s.relayInfo.Pubkey != "" {
 		relayInfo["pubkey"] = s.relayInfo.Pubkey
 	}
+	if pk := s.relay.RelayPubKey(); pk != "" {
+		// リレー自身の鍵（リレーが発行するイベントの pubkey）
+		relayInfo["self"] = pk
+	}
 	if s.relayInfo.Contact != "" {
 		relayInfo["contact"] = s.relayInfo.Contact
 	}
@@ -296,6 +548,8 @@ func (s *Server) handleRelayInfo(w http.ResponseWriter, r *http.Request) {
 	// if len(s.relayInfo.Tags.List) > 0 {
 	// 	relayInfo["tags"] = s.relayInfo.Tags.List
 	// }
+	// タグフィルタで検索できるタグ名（リレー独自の項目）
+	relayInfo["indexed_tags"] = s.relay.QueryLimits().IndexedTags.Names()
 	if s.relayInfo.PostingPolicy != "" {
 		relayInfo["posting_policy"] = s.relayInfo.PostingPolicy
 	}
@@ -306,3 +560,68 @@ func (s *Server) handleRelayInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// handleNIP05 handles NIP-05 lookups: GET /.well-known/nostr.json?name=<local-part>
+func (s *Server) handleNIP05(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	setCORSHeaders(w) // NIP-05: JavaScript アプリから参照できるように必須
+
+	// 見つからない場合も、空の names を返す
+	res := struct {
+		Names  map[string]string   `json:"names"`
+		Relays map[string][]string `json:"relays,omitempty"`
+	}{
+		Names: map[string]string{},
+	}
+
+	name := domain.NormalizeIdentityName(r.URL.Query().Get("name"))
+	if err := domain.ValidateIdentityName(name); err != nil {
+		zap.S().Debugw("invalid nip05 name", "name", name, "error", err)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	identity, err := s.identities.Lookup(r.Context(), name)
+	switch {
+	case err == nil:
+		res.Names[identity.Name] = identity.PubKey
+		if len(identity.Relays) > 0 {
+			res.Relays = map[string][]string{identity.PubKey: identity.Relays}
+		}
+	case errors.Is(err, domain.ErrNotFound):
+		zap.S().Debugw("nip05 name not found", "name", name)
+	default:
+		zap.S().Errorw("failed to lookup nip05 identity", "name", name, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		zap.S().Errorw("failed to encode nip05 response", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+}
+
+// setCORSHeaders sets headers required for browser clients (NIP-05 / NIP-11)
+func setCORSHeaders(w http.ResponseWriter) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+}
+
+// remoteSource returns the client IP address and its source type (IP4 / IP6).
+func remoteSource(r *http.Request) (string, string) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return host, ""
+	}
+	if ip.To4() != nil {
+		return ip.String(), domain.SourceIP4
+	}
+	return ip.String(), domain.SourceIP6
+}
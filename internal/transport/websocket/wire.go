@@ -10,6 +10,7 @@ type WireMessage struct {
 	SubscriptionID string
 	Event          json.RawMessage
 	Filters        []json.RawMessage
+	NegMessage     string // NIP-77: NEG-OPEN / NEG-MSG の hex メッセージ
 }
 
 func (w *WireMessage) UnmarshalJSON(data []byte) error {
@@ -22,7 +23,7 @@ func (w *WireMessage) UnmarshalJSON(data []byte) error {
 		return fmt.Errorf("empty wire message: %s", string(data))
 	}
 
-	// 0 番目: "EVENT" / "REQ" / "CLOSE"
+	// 0 番目: "EVENT" / "AUTH" / "REQ" / "CLOSE" / "NEG-OPEN" / "NEG-MSG" / "NEG-CLOSE"
 	if err := json.Unmarshal(arr[0], &w.Type); err != nil {
 		return fmt.Errorf("invalid type: %w", err)
 	}
@@ -35,6 +36,13 @@ func (w *WireMessage) UnmarshalJSON(data []byte) error {
 		}
 		w.Event = arr[1]
 
+	case "AUTH":
+		// ["AUTH", <event>]（NIP-42）
+		if len(arr) != 2 {
+			return fmt.Errorf("invalid AUTH message: %s", string(data))
+		}
+		w.Event = arr[1]
+
 	case "REQ":
 		// ["REQ", <subscription_id>, <filter>, <filter>...]
 		if len(arr) < 3 {
@@ -54,6 +62,40 @@ func (w *WireMessage) UnmarshalJSON(data []byte) error {
 			return fmt.Errorf("invalid CLOSE subscription id: %w", err)
 		}
 
+	case "NEG-OPEN":
+		// ["NEG-OPEN", <subscription_id>, <filter>, <initial_message>]
+		if len(arr) != 4 {
+			return fmt.Errorf("invalid NEG-OPEN message: %s", string(data))
+		}
+		if err := json.Unmarshal(arr[1], &w.SubscriptionID); err != nil {
+			return fmt.Errorf("invalid NEG-OPEN subscription id: %w", err)
+		}
+		w.Filters = arr[2:3]
+		if err := json.Unmarshal(arr[3], &w.NegMessage); err != nil {
+			return fmt.Errorf("invalid NEG-OPEN message: %w", err)
+		}
+
+	case "NEG-MSG":
+		// ["NEG-MSG", <subscription_id>, <message>]
+		if len(arr) != 3 {
+			return fmt.Errorf("invalid NEG-MSG message: %s", string(data))
+		}
+		if err := json.Unmarshal(arr[1], &w.SubscriptionID); err != nil {
+			return fmt.Errorf("invalid NEG-MSG subscription id: %w", err)
+		}
+		if err := json.Unmarshal(arr[2], &w.NegMessage); err != nil {
+			return fmt.Errorf("invalid NEG-MSG message: %w", err)
+		}
+
+	case "NEG-CLOSE":
+		// ["NEG-CLOSE", <subscription_id>]
+		if len(arr) != 2 {
+			return fmt.Errorf("invalid NEG-CLOSE message: %s", string(data))
+		}
+		if err := json.Unmarshal(arr[1], &w.SubscriptionID); err != nil {
+			return fmt.Errorf("invalid NEG-CLOSE subscription id: %w", err)
+		}
+
 	default:
 		return fmt.Errorf("unknown wire message type: %q", w.Type)
 	}